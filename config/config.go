@@ -8,6 +8,23 @@ type Config struct {
 	DBDriver      string `mapstructure:"DB_DRIVER"`
 	DBSource      string `mapstructure:"DB_SOURCE"`
 	ServerAddress string `mapstructure:"SERVER_ADDRESS"`
+	// ServiceChargePercent is added to every sale, e.g. "5" for restaurants.
+	// Leave empty for no service charge.
+	ServiceChargePercent string `mapstructure:"SERVICE_CHARGE_PERCENT"`
+
+	// StoreTimezone is an IANA name such as "Asia/Jakarta" (the default).
+	// BusinessDayCutoff ("HH:MM") moves the end of the business day past
+	// midnight for late-night stores; reports group sales by business day.
+	StoreTimezone     string `mapstructure:"STORE_TIMEZONE"`
+	BusinessDayCutoff string `mapstructure:"BUSINESS_DAY_CUTOFF"`
+
+	// Receipt header and footer. ReceiptTemplate is an optional path to a
+	// text/template file replacing the built-in receipt layout.
+	StoreName       string `mapstructure:"STORE_NAME"`
+	StoreAddress    string `mapstructure:"STORE_ADDRESS"`
+	StorePhone      string `mapstructure:"STORE_PHONE"`
+	ReceiptFooter   string `mapstructure:"RECEIPT_FOOTER"`
+	ReceiptTemplate string `mapstructure:"RECEIPT_TEMPLATE"`
 }
 
 func LoadConfig(path string) (config Config, err error) {
@@ -20,6 +37,14 @@ func LoadConfig(path string) (config Config, err error) {
 	viper.BindEnv("DB_DRIVER")
 	viper.BindEnv("DB_SOURCE")
 	viper.BindEnv("SERVER_ADDRESS")
+	viper.BindEnv("SERVICE_CHARGE_PERCENT")
+	viper.BindEnv("STORE_TIMEZONE")
+	viper.BindEnv("BUSINESS_DAY_CUTOFF")
+	viper.BindEnv("STORE_NAME")
+	viper.BindEnv("STORE_ADDRESS")
+	viper.BindEnv("STORE_PHONE")
+	viper.BindEnv("RECEIPT_FOOTER")
+	viper.BindEnv("RECEIPT_TEMPLATE")
 
 	err = viper.ReadInConfig()
 	if err != nil {
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"encoding/json"
+	"kasir-api/internal/model"
+	"kasir-api/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type CustomerHandler struct {
+	service service.CustomerService
+}
+
+func NewCustomerHandler(service service.CustomerService) *CustomerHandler {
+	return &CustomerHandler{service: service}
+}
+
+func (h *CustomerHandler) HandleCustomers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == http.MethodGet {
+		customers, err := h.service.GetAll()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": customers})
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var customer model.Customer
+		if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request body"})
+			return
+		}
+
+		createdCustomer, err := h.service.Create(customer)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Customer created successfully", "data": createdCustomer})
+		return
+	}
+
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+}
+
+func (h *CustomerHandler) HandleCustomerByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	path := strings.TrimPrefix(r.URL.Path, "/customers/")
+	id, err := strconv.Atoi(strings.Split(path, "/")[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid customer ID"})
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		customer, err := h.service.GetByID(id)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Customer not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": customer})
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var customer model.Customer
+		if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request body"})
+			return
+		}
+
+		updatedCustomer, err := h.service.Update(id, customer)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Customer updated successfully", "data": updatedCustomer})
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := h.service.Delete(id); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Customer deleted successfully"})
+		return
+	}
+
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+}
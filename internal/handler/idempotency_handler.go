@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"kasir-api/internal/model"
+	"kasir-api/internal/service"
+	"log"
+	"net/http"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+type IdempotencyHandler struct {
+	service service.IdempotencyService
+}
+
+func NewIdempotencyHandler(service service.IdempotencyService) *IdempotencyHandler {
+	return &IdempotencyHandler{service: service}
+}
+
+// Wrap makes POST requests carrying an Idempotency-Key header safe to retry.
+// The first response for a key is stored and replayed for repeats with the
+// same body; a repeat with a different body is rejected.
+func (h *IdempotencyHandler) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request body"})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+		record, created, err := h.service.Begin(r.Context(), key, hex.EncodeToString(sum[:]))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			switch {
+			case errors.Is(err, model.ErrIdempotencyKeyReused):
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			case errors.Is(err, model.ErrIdempotencyInProgress):
+				w.WriteHeader(http.StatusConflict)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+
+		if !created {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(record.StatusCode)
+			w.Write(record.ResponseBody)
+			return
+		}
+
+		// The key is released unless a response is stored for it, so the
+		// client can retry after a server error, a panic in next or a failure
+		// to store the response. The release must happen even if the client
+		// has gone away.
+		ctx := context.WithoutCancel(r.Context())
+		stored := false
+		defer func() {
+			if stored {
+				return
+			}
+			if err := h.service.Release(ctx, key, record.Token); err != nil {
+				log.Printf("failed to release idempotency key %q: %v", key, err)
+			}
+		}()
+
+		rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next(rec, r)
+
+		// Server errors are not stored so the client can retry with the same key
+		if rec.statusCode >= http.StatusInternalServerError {
+			return
+		}
+		if err := h.service.Complete(ctx, key, record.Token, rec.statusCode, rec.body.Bytes()); err != nil {
+			log.Printf("failed to store idempotent response for key %q: %v", key, err)
+			return
+		}
+		stored = true
+	}
+}
+
+// responseRecorder passes the response through while keeping a copy of the
+// status code and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
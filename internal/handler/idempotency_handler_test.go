@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kasir-api/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// memoryIdempotencyService mirrors the service rules without a database.
+type memoryIdempotencyService struct {
+	records     map[string]model.IdempotencyRecord
+	completeErr error
+	tokens      int
+}
+
+func (s *memoryIdempotencyService) Begin(ctx context.Context, key, requestHash string) (model.IdempotencyRecord, bool, error) {
+	record, ok := s.records[key]
+	if !ok {
+		s.tokens++
+		s.records[key] = model.IdempotencyRecord{Key: key, Token: fmt.Sprint(s.tokens), RequestHash: requestHash}
+		return s.records[key], true, nil
+	}
+	if record.RequestHash != requestHash {
+		return model.IdempotencyRecord{}, false, model.ErrIdempotencyKeyReused
+	}
+	if record.StatusCode == 0 {
+		return model.IdempotencyRecord{}, false, model.ErrIdempotencyInProgress
+	}
+	return record, false, nil
+}
+
+func (s *memoryIdempotencyService) Complete(ctx context.Context, key, token string, statusCode int, body []byte) error {
+	if s.completeErr != nil {
+		return s.completeErr
+	}
+	record := s.records[key]
+	if record.Token != token {
+		return model.ErrIdempotencyKeyTakenOver
+	}
+	record.StatusCode = statusCode
+	record.ResponseBody = body
+	s.records[key] = record
+	return nil
+}
+
+func (s *memoryIdempotencyService) Release(ctx context.Context, key, token string) error {
+	if s.records[key].Token == token {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+func TestIdempotencyWrapReplaysFirstResponse(t *testing.T) {
+	h := NewIdempotencyHandler(&memoryIdempotencyService{records: map[string]model.IdempotencyRecord{}})
+
+	calls := 0
+	next := h.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"success":true}`))
+	})
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "till-1-0001")
+		rec := httptest.NewRecorder()
+		next(rec, req)
+		return rec
+	}
+
+	first := send(`{"items":[{"product_id":1,"quantity":1}]}`)
+	second := send(`{"items":[{"product_id":1,"quantity":1}]}`)
+
+	if calls != 1 {
+		t.Errorf("Expected handler to run once, ran %d times", calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("Expected replayed response %d %s, got %d %s", first.Code, first.Body, second.Code, second.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected Idempotent-Replayed header on replay")
+	}
+
+	different := send(`{"items":[{"product_id":2,"quantity":1}]}`)
+	if different.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for reused key with different body, got %d", different.Code)
+	}
+	if calls != 1 {
+		t.Errorf("Expected handler not to run for a reused key, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyWrapReleasesKeyOnServerError(t *testing.T) {
+	h := NewIdempotencyHandler(&memoryIdempotencyService{records: map[string]model.IdempotencyRecord{}})
+
+	calls := 0
+	next := h.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "till-1-0002")
+		next(httptest.NewRecorder(), req)
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected retry after a server error to run the handler again, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyWrapReleasesKeyOnPanic(t *testing.T) {
+	service := &memoryIdempotencyService{records: map[string]model.IdempotencyRecord{}}
+	h := NewIdempotencyHandler(service)
+	next := h.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected the panic to reach the server")
+			}
+		}()
+		req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "till-1-0003")
+		next(httptest.NewRecorder(), req)
+	}()
+
+	if _, ok := service.records["till-1-0003"]; ok {
+		t.Error("Expected the key to be released after a panic")
+	}
+}
+
+func TestIdempotencyWrapReleasesKeyWhenStoreFails(t *testing.T) {
+	service := &memoryIdempotencyService{records: map[string]model.IdempotencyRecord{}, completeErr: errors.New("connection reset")}
+	h := NewIdempotencyHandler(service)
+	next := h.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(`{}`))
+	req.Header.Set("Idempotency-Key", "till-1-0004")
+	next(httptest.NewRecorder(), req)
+
+	if _, ok := service.records["till-1-0004"]; ok {
+		t.Error("Expected the key to be released when the response could not be stored")
+	}
+}
+
+func TestIdempotencyWrapLeavesKeyTakenOverByRetry(t *testing.T) {
+	service := &memoryIdempotencyService{records: map[string]model.IdempotencyRecord{}}
+	h := NewIdempotencyHandler(service)
+	next := h.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		// A retry takes over the reservation while this request is slow
+		service.records["till-1-0005"] = model.IdempotencyRecord{Key: "till-1-0005", Token: "retry"}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(`{}`))
+	req.Header.Set("Idempotency-Key", "till-1-0005")
+	next(httptest.NewRecorder(), req)
+
+	record, ok := service.records["till-1-0005"]
+	if !ok || record.Token != "retry" || record.StatusCode != 0 {
+		t.Errorf("Expected the retry's reservation to be left alone, got %+v (present %v)", record, ok)
+	}
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"kasir-api/internal/model"
 	"kasir-api/internal/service"
 	"net/http"
@@ -11,10 +12,11 @@ import (
 
 type ProductHandler struct {
 	service service.ProductService
+	stock   service.StockService
 }
 
-func NewProductHandler(service service.ProductService) *ProductHandler {
-	return &ProductHandler{service: service}
+func NewProductHandler(service service.ProductService, stock service.StockService) *ProductHandler {
+	return &ProductHandler{service: service, stock: stock}
 }
 
 func (h *ProductHandler) HandleProducts(w http.ResponseWriter, r *http.Request) {
@@ -50,7 +52,11 @@ func (h *ProductHandler) HandleProducts(w http.ResponseWriter, r *http.Request)
 
 		createdProduct, err := h.service.Create(product)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			if errors.Is(err, model.ErrDuplicateSKU) || errors.Is(err, model.ErrDuplicateBarcode) || errors.Is(err, model.ErrDuplicateVariant) {
+				w.WriteHeader(http.StatusConflict)
+			} else {
+				w.WriteHeader(http.StatusBadRequest)
+			}
 			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
 			return
 		}
@@ -79,6 +85,11 @@ func (h *ProductHandler) HandleProductByID(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if len(idCursor) > 1 && idCursor[1] == "stock-movements" {
+		h.handleStockMovements(w, r, id)
+		return
+	}
+
 	if r.Method == http.MethodGet {
 		product, err := h.service.GetByID(id)
 		if err != nil {
@@ -100,7 +111,11 @@ func (h *ProductHandler) HandleProductByID(w http.ResponseWriter, r *http.Reques
 
 		updatedProduct, err := h.service.Update(id, product)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			if errors.Is(err, model.ErrDuplicateSKU) || errors.Is(err, model.ErrDuplicateBarcode) || errors.Is(err, model.ErrDuplicateVariant) {
+				w.WriteHeader(http.StatusConflict)
+			} else {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
 			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
 			return
 		}
@@ -111,7 +126,11 @@ func (h *ProductHandler) HandleProductByID(w http.ResponseWriter, r *http.Reques
 
 	if r.Method == http.MethodDelete {
 		if err := h.service.Delete(id); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			if errors.Is(err, model.ErrComponentInUse) || errors.Is(err, model.ErrProductHasVariants) {
+				w.WriteHeader(http.StatusConflict)
+			} else {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
 			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
 			return
 		}
@@ -122,3 +141,152 @@ func (h *ProductHandler) HandleProductByID(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusMethodNotAllowed)
 	json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
 }
+
+// handleStockMovements lists a product's stock ledger (GET) or records a
+// manual adjustment or transfer (POST).
+func (h *ProductHandler) handleStockMovements(w http.ResponseWriter, r *http.Request, id int) {
+	switch r.Method {
+	case http.MethodGet:
+		movements, err := h.stock.GetMovements(r.Context(), id)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": movements})
+	case http.MethodPost:
+		var movement model.StockMovement
+		if err := json.NewDecoder(r.Body).Decode(&movement); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request body"})
+			return
+		}
+
+		recorded, err := h.stock.RecordMovement(r.Context(), id, movement)
+		if err != nil {
+			var stockErr *model.InsufficientStockError
+			if errors.As(err, &stockErr) {
+				w.WriteHeader(http.StatusConflict)
+			} else {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Stock movement recorded successfully", "data": recorded})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+	}
+}
+
+// CheckStock recomputes stock from the ledger and lists the products whose
+// stock does not match.
+func (h *ProductHandler) CheckStock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+		return
+	}
+
+	discrepancies, err := h.stock.CheckConsistency(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": map[string]interface{}{
+		"consistent":    len(discrepancies) == 0,
+		"discrepancies": discrepancies,
+	}})
+}
+
+// GetByBarcode looks up the product for a scanned barcode at
+// /products/barcode/{code}, along with the pack when the barcode is printed on
+// one.
+func (h *ProductHandler) GetByBarcode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+		return
+	}
+
+	code := strings.TrimPrefix(r.URL.Path, "/products/barcode/")
+	if _, err := model.NormalizeBarcode(code); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	match, err := h.service.GetByBarcode(code)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Product not found"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": match})
+}
+
+// GetLowStock lists the products at or below their reorder point.
+func (h *ProductHandler) GetLowStock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+		return
+	}
+
+	products, err := h.service.GetLowStock()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	if products == nil {
+		products = []model.Product{}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": products})
+}
+
+// GetReorderSuggestions proposes what to order from each supplier. ?days sets
+// the sales window used to judge demand and ?cover_days how long the order
+// should last.
+func (h *ProductHandler) GetReorderSuggestions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+		return
+	}
+
+	var days, coverDays int
+	var err error
+	if v := r.URL.Query().Get("days"); v != "" {
+		days, err = strconv.Atoi(v)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "invalid days"})
+			return
+		}
+	}
+	if v := r.URL.Query().Get("cover_days"); v != "" {
+		coverDays, err = strconv.Atoi(v)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "invalid cover_days"})
+			return
+		}
+	}
+
+	report, err := h.stock.SuggestReorders(r.Context(), days, coverDays)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": report})
+}
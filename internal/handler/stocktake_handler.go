@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"kasir-api/internal/model"
+	"kasir-api/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type StocktakeHandler struct {
+	service service.StocktakeService
+}
+
+func NewStocktakeHandler(service service.StocktakeService) *StocktakeHandler {
+	return &StocktakeHandler{service: service}
+}
+
+func (h *StocktakeHandler) HandleStocktakes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == http.MethodGet {
+		stocktakes, err := h.service.GetAll(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": stocktakes})
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req model.StartStocktakeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request body"})
+			return
+		}
+
+		stocktake, err := h.service.Start(r.Context(), req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Stocktake started successfully", "data": stocktake})
+		return
+	}
+
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+}
+
+func (h *StocktakeHandler) HandleStocktakeByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	path := strings.TrimPrefix(r.URL.Path, "/stocktakes/")
+	idCursor := strings.Split(path, "/")
+	id, err := strconv.Atoi(idCursor[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid stocktake ID"})
+		return
+	}
+
+	action := ""
+	if len(idCursor) > 1 {
+		action = idCursor[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		report, err := h.service.GetReport(r.Context(), id)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Stocktake not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": report})
+
+	case action == "counts" && r.Method == http.MethodPost:
+		var req model.SubmitCountsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request body"})
+			return
+		}
+
+		report, err := h.service.SubmitCounts(r.Context(), id, req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Counts saved successfully", "data": report})
+
+	case action == "post" && r.Method == http.MethodPost:
+		report, err := h.service.Post(r.Context(), id)
+		if err != nil {
+			var stockErr *model.InsufficientStockError
+			if errors.As(err, &stockErr) {
+				w.WriteHeader(http.StatusConflict)
+			} else {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Stocktake posted successfully", "data": report})
+
+	case action == "cancel" && r.Method == http.MethodPost:
+		if err := h.service.Cancel(r.Context(), id); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Stocktake cancelled successfully"})
+
+	case action == "" || action == "counts" || action == "post" || action == "cancel":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Not found"})
+	}
+}
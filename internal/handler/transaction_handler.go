@@ -1,18 +1,33 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"kasir-api/internal/model"
+	"kasir-api/internal/receipt"
 	"kasir-api/internal/service"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type TransactionHandler struct {
-	service service.TransactionService
+	service  service.TransactionService
+	receipts *receipt.Renderer
 }
 
-func NewTransactionHandler(service service.TransactionService) *TransactionHandler {
-	return &TransactionHandler{service: service}
+func NewTransactionHandler(service service.TransactionService, receipts *receipt.Renderer) *TransactionHandler {
+	return &TransactionHandler{service: service, receipts: receipts}
+}
+
+func (h *TransactionHandler) HandleTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		h.GetTransactions(w, r)
+		return
+	}
+	h.CreateTransaction(w, r)
 }
 
 func (h *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.Request) {
@@ -32,6 +47,12 @@ func (h *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.Re
 
 	transaction, err := h.service.CreateTransaction(r.Context(), req)
 	if err != nil {
+		var stockErr *model.InsufficientStockError
+		if errors.As(err, &stockErr) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
 		return
@@ -58,3 +79,367 @@ func (h *TransactionHandler) GetDailyReport(w http.ResponseWriter, r *http.Reque
 
 	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": report})
 }
+
+func (h *TransactionHandler) GetTaxReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+		return
+	}
+
+	from, to, err := parseDateRange(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	report, err := h.service.GetTaxReport(r.Context(), from, to)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidReportRange) || errors.Is(err, model.ErrInvalidReportGrouping) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": report})
+}
+
+func (h *TransactionHandler) GetSalesReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+		return
+	}
+
+	from, to, err := parseDateRange(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	report, err := h.service.GetSalesReport(r.Context(), from, to, r.URL.Query().Get("group_by"))
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidReportRange) || errors.Is(err, model.ErrInvalidReportGrouping) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": report})
+}
+
+func (h *TransactionHandler) GetProfitReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+		return
+	}
+
+	from, to, err := parseDateRange(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	report, err := h.service.GetProfitReport(r.Context(), from, to, r.URL.Query().Get("group_by"))
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidReportRange) || errors.Is(err, model.ErrInvalidReportGrouping) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": report})
+}
+
+func (h *TransactionHandler) GetProductSalesReport(w http.ResponseWriter, r *http.Request) {
+	h.getSalesBreakdown(w, r, h.service.GetProductSalesReport)
+}
+
+func (h *TransactionHandler) GetCategorySalesReport(w http.ResponseWriter, r *http.Request) {
+	h.getSalesBreakdown(w, r, h.service.GetCategorySalesReport)
+}
+
+// getSalesBreakdown serves a ranking report for ?from=&to=&limit=.
+func (h *TransactionHandler) getSalesBreakdown(w http.ResponseWriter, r *http.Request, report func(ctx context.Context, from, to time.Time, limit int) (model.SalesBreakdown, error)) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+		return
+	}
+
+	from, to, err := parseDateRange(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	var limit int
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "invalid limit"})
+			return
+		}
+	}
+
+	breakdown, err := report(r.Context(), from, to, limit)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidReportRange) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": breakdown})
+}
+
+func (h *TransactionHandler) GetTransactions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	filter, err := parseTransactionFilter(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	transactions, pagination, err := h.service.GetTransactions(r.Context(), filter)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": transactions, "pagination": pagination})
+}
+
+func (h *TransactionHandler) HandleTransactionByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	path := strings.TrimPrefix(r.URL.Path, "/transactions/")
+	idCursor := strings.Split(path, "/")
+	id, err := strconv.Atoi(idCursor[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid transaction ID"})
+		return
+	}
+
+	if len(idCursor) > 1 && idCursor[1] != "" {
+		h.handleTransactionAction(w, r, id, idCursor[1])
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		transaction, err := h.service.GetTransactionByID(r.Context(), id)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Transaction not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": transaction})
+		return
+	}
+
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+}
+
+func (h *TransactionHandler) handleTransactionAction(w http.ResponseWriter, r *http.Request, id int, action string) {
+	switch {
+	case action == "void" && r.Method == http.MethodPost:
+		var req model.RefundRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request body"})
+			return
+		}
+
+		refund, err := h.service.VoidTransaction(r.Context(), id, req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Transaction voided successfully", "data": refund})
+
+	case action == "refunds" && r.Method == http.MethodPost:
+		var req model.RefundRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request body"})
+			return
+		}
+
+		refund, err := h.service.RefundTransaction(r.Context(), id, req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Refund created successfully", "data": refund})
+
+	case action == "refunds" && r.Method == http.MethodGet:
+		refunds, err := h.service.GetRefunds(r.Context(), id)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": refunds})
+
+	case action == "receipt" && r.Method == http.MethodGet:
+		h.getReceipt(w, r, id)
+
+	case action == "void" || action == "refunds" || action == "receipt":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Not found"})
+	}
+}
+
+// getReceipt renders a receipt for printing. The format query parameter is
+// text (default), escpos or pdf, and width is the paper size: 58 (default) or
+// 80 mm.
+func (h *TransactionHandler) getReceipt(w http.ResponseWriter, r *http.Request, id int) {
+	width := receipt.Width58mm
+	switch r.URL.Query().Get("width") {
+	case "", "58":
+	case "80":
+		width = receipt.Width80mm
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "width must be 58 or 80"})
+		return
+	}
+
+	transaction, err := h.service.GetTransactionByID(r.Context(), id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Transaction not found"})
+		return
+	}
+
+	body, contentType, err := h.receipts.Render(transaction, r.URL.Query().Get("format"), width)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
+
+// parseTransactionFilter reads the history filters from the query string.
+// Dates are business dates in the YYYY-MM-DD format and "to" is inclusive of
+// the whole day.
+func parseTransactionFilter(r *http.Request) (model.TransactionFilter, error) {
+	q := r.URL.Query()
+	var filter model.TransactionFilter
+
+	if v := q.Get("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return filter, errors.New("invalid from date, expected YYYY-MM-DD")
+		}
+		filter.From = &from
+	}
+	if v := q.Get("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return filter, errors.New("invalid to date, expected YYYY-MM-DD")
+		}
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+	if v := q.Get("min_amount"); v != "" {
+		amount, err := model.ParseMoney(v)
+		if err != nil {
+			return filter, errors.New("invalid min_amount")
+		}
+		filter.MinAmount = &amount
+	}
+	if v := q.Get("max_amount"); v != "" {
+		amount, err := model.ParseMoney(v)
+		if err != nil {
+			return filter, errors.New("invalid max_amount")
+		}
+		filter.MaxAmount = &amount
+	}
+	if v := q.Get("product_id"); v != "" {
+		productID, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, errors.New("invalid product_id")
+		}
+		filter.ProductID = productID
+	}
+	if v := q.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, errors.New("invalid page")
+		}
+		filter.Page = page
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, errors.New("invalid limit")
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// parseDateRange reads the "from" and "to" query parameters (YYYY-MM-DD) for
+// reports. The returned to is the day after "to", making it exclusive. Missing
+// dates are returned as zero and default to the current business day in the
+// service, which knows the store's timezone.
+func parseDateRange(r *http.Request) (time.Time, time.Time, error) {
+	q := r.URL.Query()
+
+	var from, to time.Time
+	if v := q.Get("from"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid from date, expected YYYY-MM-DD")
+		}
+		from = parsed
+	}
+	if v := q.Get("to"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid to date, expected YYYY-MM-DD")
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
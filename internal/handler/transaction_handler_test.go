@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kasir-api/internal/model"
+	"kasir-api/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// reportService fails every report with err.
+type reportService struct {
+	service.TransactionService
+	err error
+}
+
+func (s reportService) GetTaxReport(ctx context.Context, from, to time.Time) ([]model.TaxReport, error) {
+	return nil, s.err
+}
+
+func (s reportService) GetSalesReport(ctx context.Context, from, to time.Time, groupBy string) (model.SalesReport, error) {
+	return model.SalesReport{}, s.err
+}
+
+func (s reportService) GetProfitReport(ctx context.Context, from, to time.Time, groupBy string) (model.ProfitReport, error) {
+	return model.ProfitReport{}, s.err
+}
+
+func TestGetReportStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"report", nil, http.StatusOK},
+		{"range reversed", fmt.Errorf("%w: from must be before to", model.ErrInvalidReportRange), http.StatusBadRequest},
+		{"bad grouping", fmt.Errorf("%w: year", model.ErrInvalidReportGrouping), http.StatusBadRequest},
+		{"database down", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		h := NewTransactionHandler(reportService{err: tt.err}, nil)
+		reports := map[string]http.HandlerFunc{
+			"tax":    h.GetTaxReport,
+			"sales":  h.GetSalesReport,
+			"profit": h.GetProfitReport,
+		}
+		for report, serve := range reports {
+			rec := httptest.NewRecorder()
+			serve(rec, httptest.NewRequest(http.MethodGet, "/reports?from=2026-03-01&to=2026-03-08", nil))
+			if rec.Code != tt.status {
+				t.Errorf("%s report, %s: expected %d, got %d", report, tt.name, tt.status, rec.Code)
+			}
+		}
+	}
+}
+
+func TestGetSalesBreakdownStatus(t *testing.T) {
+	h := &TransactionHandler{}
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"report", nil, http.StatusOK},
+		{"range too long", fmt.Errorf("%w: reports are limited to 1830 days", model.ErrInvalidReportRange), http.StatusBadRequest},
+		{"database down", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		report := func(ctx context.Context, from, to time.Time, limit int) (model.SalesBreakdown, error) {
+			return model.SalesBreakdown{}, tt.err
+		}
+		rec := httptest.NewRecorder()
+		h.getSalesBreakdown(rec, httptest.NewRequest(http.MethodGet, "/reports/products?from=2026-03-01&to=2026-03-08", nil), report)
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.status, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// NormalizeBarcode checks a scanned or typed barcode and returns the form it
+// is stored and looked up in. Barcodes are GS1 numbers: EAN-8, UPC-A, EAN-13
+// or GTIN-14, including the in-store EAN-13 codes with prefixes 20 to 29,
+// and the last digit must be the correct check digit. A UPC-A code is
+// returned as its EAN-13 equivalent, with a leading zero, so either form
+// finds the same product.
+func NormalizeBarcode(code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return "", errors.New("barcode is required")
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return "", fmt.Errorf("invalid barcode %s: only digits are allowed", code)
+		}
+	}
+
+	switch len(code) {
+	case 8, 13, 14:
+	case 12:
+		code = "0" + code
+	default:
+		return "", fmt.Errorf("invalid barcode %s: must be 8, 12, 13 or 14 digits", code)
+	}
+
+	last := len(code) - 1
+	if want := CheckDigit(code[:last]); code[last] != want {
+		return "", fmt.Errorf("invalid barcode %s: check digit should be %c", code, want)
+	}
+	return code, nil
+}
+
+// CheckDigit computes the GS1 check digit for the given digits: weighting
+// them 3 and 1 alternately from the right, it is what brings the sum up to a
+// multiple of ten.
+func CheckDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// ScaleBarcode is an in-store EAN-13 label printed by a scale. Its 13 digits
+// are a two-digit prefix from 20 to 29, a five-digit item code, a five-digit
+// value and the check digit. With prefixes 20 to 24 the value is the weight
+// in thousandths of the product's unit (grams for a product sold by the kg);
+// with 25 to 29 it is the price in whole Rupiah.
+//
+// ItemCode is the label with its value zeroed and the check digit redone,
+// which is the barcode the product is registered under.
+type ScaleBarcode struct {
+	ItemCode string
+	Quantity Quantity
+	Price    Money
+}
+
+// ParseScaleBarcode reads a normalized barcode as a scale label. It reports
+// false for anything outside the 20 to 29 in-store range.
+func ParseScaleBarcode(code string) (ScaleBarcode, bool) {
+	if len(code) != 13 || code[0] != '2' {
+		return ScaleBarcode{}, false
+	}
+
+	value := 0
+	for _, c := range code[7:12] {
+		value = value*10 + int(c-'0')
+	}
+	base := code[:7] + "00000"
+	label := ScaleBarcode{ItemCode: base + string(CheckDigit(base))}
+	if code[1] <= '4' {
+		label.Quantity = Quantity(value)
+	} else {
+		label.Price = Money(value) * 100
+	}
+	return label, true
+}
+
+// QuantityAt returns how much the label is for at the given unit price. A
+// weight label carries the quantity itself; for a price label it is the price
+// divided by the unit price, to the nearest thousandth of a unit.
+func (b ScaleBarcode) QuantityAt(unitPrice Money) Quantity {
+	if b.Price == 0 {
+		return b.Quantity
+	}
+	if unitPrice <= 0 {
+		return 0
+	}
+	return Quantity(b.Price.MulDiv(QuantityScale, int(unitPrice)))
+}
@@ -0,0 +1,84 @@
+package model
+
+import "testing"
+
+func TestNormalizeBarcode(t *testing.T) {
+	tests := map[string]string{
+		"8992761136017":   "8992761136017",
+		" 8992761136017 ": "8992761136017",
+		"036000291452":    "0036000291452",
+		"96385074":        "96385074",
+		"2000001000014":   "2000001000014",
+		"10012345678902":  "10012345678902",
+	}
+	for code, expected := range tests {
+		got, err := NormalizeBarcode(code)
+		if err != nil {
+			t.Errorf("NormalizeBarcode(%q): unexpected error: %v", code, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("NormalizeBarcode(%q) = %s, expected %s", code, got, expected)
+		}
+	}
+}
+
+func TestNormalizeBarcodeInvalid(t *testing.T) {
+	tests := map[string]string{
+		"":               "barcode is required",
+		"8992761136015":  "invalid barcode 8992761136015: check digit should be 7",
+		"036000291453":   "invalid barcode 0036000291453: check digit should be 2",
+		"89927611360":    "invalid barcode 89927611360: must be 8, 12, 13 or 14 digits",
+		"ABC1234567890":  "invalid barcode ABC1234567890: only digits are allowed",
+		"899276113601-4": "invalid barcode 899276113601-4: only digits are allowed",
+	}
+	for code, want := range tests {
+		if _, err := NormalizeBarcode(code); err == nil || err.Error() != want {
+			t.Errorf("NormalizeBarcode(%q): expected %q, got %v", code, want, err)
+		}
+	}
+}
+
+func TestCheckDigit(t *testing.T) {
+	if got := CheckDigit("899276113601"); got != '7' {
+		t.Errorf("CheckDigit = %c, expected 7", got)
+	}
+	if got := CheckDigit("9638507"); got != '4' {
+		t.Errorf("CheckDigit = %c, expected 4", got)
+	}
+}
+
+func TestParseScaleBarcode(t *testing.T) {
+	// Prefix 20: 1.250 kg of item 12345
+	label, ok := ParseScaleBarcode("2012345012509")
+	if !ok {
+		t.Fatal("Expected a scale barcode")
+	}
+	if label.ItemCode != "2012345000001" || label.Quantity != 1250 || label.Price != 0 {
+		t.Errorf("Unexpected weight label: %+v", label)
+	}
+	if got := label.QuantityAt(3250000); got != 1250 {
+		t.Errorf("QuantityAt = %s, expected 1.25", got)
+	}
+
+	// Prefix 26: Rp 12.345 of item 00420, sold at Rp 30.000/kg
+	label, ok = ParseScaleBarcode("2600420123457")
+	if !ok {
+		t.Fatal("Expected a scale barcode")
+	}
+	if label.ItemCode != "2600420000000" || label.Price != 1234500 || label.Quantity != 0 {
+		t.Errorf("Unexpected price label: %+v", label)
+	}
+	if got := label.QuantityAt(3000000); got != 412 {
+		t.Errorf("QuantityAt = %s, expected 0.412", got)
+	}
+	if got := label.QuantityAt(0); got != 0 {
+		t.Errorf("QuantityAt with no price = %s, expected 0", got)
+	}
+
+	for _, code := range []string{"8992761136017", "96385074", "10012345678902"} {
+		if _, ok := ParseScaleBarcode(code); ok {
+			t.Errorf("ParseScaleBarcode(%q): expected not a scale barcode", code)
+		}
+	}
+}
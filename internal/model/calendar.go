@@ -0,0 +1,75 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultTimezone is used when no store timezone is configured.
+const DefaultTimezone = "Asia/Jakarta"
+
+// BusinessCalendar maps instants to the store's business days. A business day
+// starts Cutoff minutes after local midnight, so with a 04:00 cutoff a sale at
+// 02:00 on the 2nd belongs to the 1st.
+//
+// Business dates are represented as midnight UTC on that date, the same way
+// time.Parse("2006-01-02", ...) returns them.
+type BusinessCalendar struct {
+	Location *time.Location
+	Cutoff   int
+}
+
+// NewBusinessCalendar loads timezone (an IANA name, DefaultTimezone when
+// empty) and cutoff ("HH:MM", midnight when empty).
+func NewBusinessCalendar(timezone, cutoff string) (BusinessCalendar, error) {
+	if timezone == "" {
+		timezone = DefaultTimezone
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return BusinessCalendar{}, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+
+	calendar := BusinessCalendar{Location: location}
+	if cutoff != "" {
+		t, err := time.Parse("15:04", cutoff)
+		if err != nil {
+			return BusinessCalendar{}, fmt.Errorf("invalid business day cutoff %q, expected HH:MM", cutoff)
+		}
+		calendar.Cutoff = t.Hour()*60 + t.Minute()
+	}
+	return calendar, nil
+}
+
+func (c BusinessCalendar) location() *time.Location {
+	if c.Location == nil {
+		return time.UTC
+	}
+	return c.Location
+}
+
+// DateOf returns the business date t falls on.
+func (c BusinessCalendar) DateOf(t time.Time) time.Time {
+	local := t.In(c.location())
+	// Shift the wall clock back by the cutoff and take the calendar date
+	shifted := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute()-c.Cutoff, 0, 0, time.UTC)
+	return time.Date(shifted.Year(), shifted.Month(), shifted.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Start returns the instant the business day on date begins.
+func (c BusinessCalendar) Start(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, c.Cutoff, 0, 0, c.location())
+}
+
+// TimezoneName and CutoffInterval are the query parameters for bucketing a
+// timestamptz column by business date in SQL:
+//
+//	((created_at AT TIME ZONE $tz) - $cutoff::interval)::date
+func (c BusinessCalendar) TimezoneName() string {
+	return c.location().String()
+}
+
+// CutoffInterval is the cutoff as a Postgres interval, e.g. "240 minutes".
+func (c BusinessCalendar) CutoffInterval() string {
+	return fmt.Sprintf("%d minutes", c.Cutoff)
+}
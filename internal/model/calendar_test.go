@@ -0,0 +1,89 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func mustCalendar(t *testing.T, timezone, cutoff string) BusinessCalendar {
+	t.Helper()
+	c, err := NewBusinessCalendar(timezone, cutoff)
+	if err != nil {
+		t.Fatalf("NewBusinessCalendar(%q, %q): %v", timezone, cutoff, err)
+	}
+	return c
+}
+
+func TestBusinessCalendarDateOf(t *testing.T) {
+	jakarta := mustCalendar(t, "Asia/Jakarta", "")
+	cafe := mustCalendar(t, "Asia/Jakarta", "04:00")
+
+	tests := []struct {
+		name     string
+		calendar BusinessCalendar
+		at       time.Time
+		want     string
+	}{
+		// 23:30 WIB on the 1st is still 16:30 UTC on the 1st
+		{"before midnight", jakarta, time.Date(2026, 3, 1, 16, 30, 0, 0, time.UTC), "2026-03-01"},
+		// 00:30 WIB on the 2nd is 17:30 UTC on the 1st
+		{"after midnight", jakarta, time.Date(2026, 3, 1, 17, 30, 0, 0, time.UTC), "2026-03-02"},
+		{"exactly midnight", jakarta, time.Date(2026, 3, 1, 17, 0, 0, 0, time.UTC), "2026-03-02"},
+		// 02:00 WIB on the 2nd belongs to the 1st with a 04:00 cutoff
+		{"after midnight before cutoff", cafe, time.Date(2026, 3, 1, 19, 0, 0, 0, time.UTC), "2026-03-01"},
+		{"one minute before cutoff", cafe, time.Date(2026, 3, 1, 20, 59, 0, 0, time.UTC), "2026-03-01"},
+		{"exactly cutoff", cafe, time.Date(2026, 3, 1, 21, 0, 0, 0, time.UTC), "2026-03-02"},
+		{"month boundary before cutoff", cafe, time.Date(2026, 2, 28, 20, 0, 0, 0, time.UTC), "2026-02-28"},
+		{"year boundary", jakarta, time.Date(2025, 12, 31, 17, 0, 0, 0, time.UTC), "2026-01-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.calendar.DateOf(tt.at).Format("2006-01-02"); got != tt.want {
+				t.Errorf("DateOf(%s) = %s, want %s", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBusinessCalendarStart(t *testing.T) {
+	date := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	start := mustCalendar(t, "Asia/Jakarta", "").Start(date)
+	if want := time.Date(2026, 3, 1, 17, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Errorf("Start = %s, want %s", start.UTC(), want)
+	}
+
+	start = mustCalendar(t, "Asia/Jakarta", "04:00").Start(date)
+	if want := time.Date(2026, 3, 1, 21, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Errorf("Start with cutoff = %s, want %s", start.UTC(), want)
+	}
+}
+
+func TestBusinessCalendarStartRoundTrip(t *testing.T) {
+	c := mustCalendar(t, "Asia/Jakarta", "04:00")
+	date := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	start := c.Start(date)
+
+	if got := c.DateOf(start); !got.Equal(date) {
+		t.Errorf("DateOf(Start) = %s, want %s", got, date)
+	}
+	if got := c.DateOf(start.Add(-time.Second)); !got.Equal(date.AddDate(0, 0, -1)) {
+		t.Errorf("DateOf(Start - 1s) = %s, want previous day", got)
+	}
+}
+
+func TestNewBusinessCalendarInvalid(t *testing.T) {
+	if _, err := NewBusinessCalendar("Mars/Olympus", ""); err == nil {
+		t.Error("expected error for unknown timezone")
+	}
+	if _, err := NewBusinessCalendar("", "25:00"); err == nil {
+		t.Error("expected error for invalid cutoff")
+	}
+	c, err := NewBusinessCalendar("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.TimezoneName() != DefaultTimezone || c.CutoffInterval() != "0 minutes" {
+		t.Errorf("defaults = %s %s", c.TimezoneName(), c.CutoffInterval())
+	}
+}
@@ -0,0 +1,32 @@
+package model
+
+// Price levels. Retail is what walk-in customers pay; registered customers can
+// be put on the member or reseller level to get that level's prices.
+const (
+	PriceLevelRetail   = "retail"
+	PriceLevelMember   = "member"
+	PriceLevelReseller = "reseller"
+)
+
+// PriceLevels lists every accepted price level.
+var PriceLevels = []string{PriceLevelRetail, PriceLevelMember, PriceLevelReseller}
+
+// IsPriceLevel reports whether level is one of PriceLevels.
+func IsPriceLevel(level string) bool {
+	for _, l := range PriceLevels {
+		if level == l {
+			return true
+		}
+	}
+	return false
+}
+
+// Customer is a registered buyer such as a member or a reseller. PriceLevel
+// decides which of a product's price tiers they are offered.
+type Customer struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Phone      string `json:"phone"`
+	Email      string `json:"email"`
+	PriceLevel string `json:"price_level"`
+}
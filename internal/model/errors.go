@@ -0,0 +1,64 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// InsufficientStockError is returned when a sale asks for more units than the
+// product has on hand at the moment the stock row is locked.
+type InsufficientStockError struct {
+	ProductID   int
+	ProductName string
+	Requested   Quantity
+	Available   Quantity
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for product: %s (requested %s, available %s)", e.ProductName, e.Requested, e.Available)
+}
+
+var (
+	// ErrIdempotencyKeyReused is returned when a key is replayed with a
+	// different request body.
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+	// ErrIdempotencyInProgress is returned when a retry arrives while the
+	// original request is still being processed.
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is still being processed")
+	// ErrIdempotencyKeyTakenOver is returned when a request completes after
+	// its stale reservation was taken over by a retry.
+	ErrIdempotencyKeyTakenOver = errors.New("idempotency key was taken over by a retry")
+
+	// ErrDuplicateSKU is returned when a product is saved with a SKU another
+	// product already has.
+	ErrDuplicateSKU = errors.New("sku is already used by another product")
+	// ErrDuplicateBarcode is returned when a product is saved with a barcode
+	// another product already has.
+	ErrDuplicateBarcode = errors.New("barcode is already used by another product")
+	// ErrDuplicateVariant is returned when a variant is saved with the same
+	// attributes as another variant of its parent.
+	ErrDuplicateVariant = errors.New("a variant with these attributes already exists")
+
+	// ErrInvalidReportRange is returned when a report is asked for a range
+	// that is empty, reversed or too long.
+	ErrInvalidReportRange = errors.New("invalid report range")
+	// ErrInvalidReportGrouping is returned when a report is asked to group
+	// by something it does not support.
+	ErrInvalidReportGrouping = errors.New("invalid group_by")
+
+	// ErrCompositeStock is returned when stock is moved for a composite
+	// product, whose stock is worked out from its components.
+	ErrCompositeStock = errors.New("a composite product has no stock of its own; move its components instead")
+	// ErrComponentInUse is returned when deleting a product that is still a
+	// component of a composite product.
+	ErrComponentInUse = errors.New("product is a component of a composite product")
+	// ErrProductHasVariants is returned when deleting a product whose
+	// variants have not been deleted.
+	ErrProductHasVariants = errors.New("product has variants; delete them first")
+	// ErrVariantParentStock is returned when a product holding stock is given
+	// variant attributes. A parent's stock is kept by its variants.
+	ErrVariantParentStock = errors.New("a product with variants cannot hold stock")
+	// ErrVariantParentComponent is returned when a component of a composite
+	// product is given variant attributes.
+	ErrVariantParentComponent = errors.New("a component of a composite product cannot have variants")
+)
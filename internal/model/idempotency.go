@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// IdempotencyRecord stores the first response produced for an Idempotency-Key.
+// StatusCode is zero while the original request is still being processed.
+// Token identifies the request holding the reservation; only that request may
+// complete or release it.
+type IdempotencyRecord struct {
+	Key          string
+	Token        string
+	RequestHash  string
+	StatusCode   int
+	ResponseBody []byte
+	CreatedAt    time.Time
+}
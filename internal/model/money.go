@@ -0,0 +1,235 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact amount of Rupiah stored as an integer number of sen
+// (1/100 Rupiah), matching the DECIMAL(10, 2) columns in the schema. It is
+// encoded in JSON as a plain number with two decimal places.
+type Money int64
+
+// ParseMoney parses a decimal string such as "15000", "-2.5" or "1250.75".
+// More than two decimal places are rejected unless the extra digits are zero.
+func ParseMoney(s string) (Money, error) {
+	v, err := parseDecimal(s, 2)
+	switch err {
+	case nil:
+		return Money(v), nil
+	case errEmptyDecimal:
+		return 0, errors.New("empty money value")
+	case errDecimalPlaces:
+		return 0, fmt.Errorf("money value has more than two decimal places: %q", s)
+	case errDecimalRange:
+		return 0, fmt.Errorf("money value out of range: %q", s)
+	default:
+		return 0, fmt.Errorf("invalid money value: %q", s)
+	}
+}
+
+var (
+	errEmptyDecimal   = errors.New("empty value")
+	errInvalidDecimal = errors.New("invalid value")
+	errDecimalPlaces  = errors.New("too many decimal places")
+	errDecimalRange   = errors.New("value out of range")
+)
+
+// parseDecimal parses a decimal string into an integer count of 10^-places
+// units. Extra decimal places are only accepted when they are zero.
+func parseDecimal(s string, places int) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errEmptyDecimal
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, errInvalidDecimal
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if len(frac) > places {
+		if strings.Trim(frac[places:], "0") != "" {
+			return 0, errDecimalPlaces
+		}
+		frac = frac[:places]
+	}
+	for len(frac) < places {
+		frac += "0"
+	}
+
+	for _, c := range whole + frac {
+		if c < '0' || c > '9' {
+			return 0, errInvalidDecimal
+		}
+	}
+
+	scale := int64(1)
+	for i := 0; i < places; i++ {
+		scale *= 10
+	}
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, errDecimalRange
+	}
+	var fraction int64
+	if frac != "" {
+		fraction, _ = strconv.ParseInt(frac, 10, 64)
+	}
+	if units > (1<<63-1-fraction)/scale {
+		return 0, errDecimalRange
+	}
+
+	v := units*scale + fraction
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+// String formats the amount with exactly two decimal places, e.g. "1250.75".
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+// Mul returns the amount multiplied by a whole count.
+func (m Money) Mul(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// MulQuantity returns the price of q units at m per unit, rounded half away
+// from zero to the nearest sen.
+func (m Money) MulQuantity(q Quantity) Money {
+	return m.MulDiv(int(q), QuantityScale)
+}
+
+// MulDiv returns m * num / den rounded half away from zero to the nearest sen.
+// It is used to split an amount proportionally, e.g. a partial refund.
+func (m Money) MulDiv(num, den int) Money {
+	if den == 0 {
+		return 0
+	}
+	product := int64(m) * int64(num)
+	d := int64(den)
+	if d < 0 {
+		product, d = -product, -d
+	}
+	q, r := product/d, product%d
+	if r < 0 {
+		r = -r
+	}
+	if r*2 >= d {
+		if product < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return Money(q)
+}
+
+// Allocate splits m across weights in proportion to each weight. Leftover sen
+// from rounding go to the largest weights first, so the parts always add up
+// to exactly m.
+func (m Money) Allocate(weights []Money) []Money {
+	parts := make([]Money, len(weights))
+	var totalWeight Money
+	for _, w := range weights {
+		totalWeight += w
+	}
+	if totalWeight <= 0 {
+		return parts
+	}
+
+	var allocated Money
+	for i, w := range weights {
+		parts[i] = Money(int64(m) * int64(w) / int64(totalWeight))
+		allocated += parts[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return weights[order[a]] > weights[order[b]] })
+	for i := 0; allocated < m && len(order) > 0; i = (i + 1) % len(order) {
+		parts[order[i]]++
+		allocated++
+	}
+	return parts
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts both JSON numbers and numeric strings. The literal
+// text is parsed directly so no precision is lost through float64.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	s = strings.Trim(s, `"`)
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Scan implements sql.Scanner for DECIMAL columns.
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		parsed, err := ParseMoney(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case string:
+		parsed, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case int64:
+		*m = Money(v * 100)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+}
+
+// Value implements driver.Valuer so amounts are sent to the database as exact
+// decimal strings.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
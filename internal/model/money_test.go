@@ -0,0 +1,148 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Money
+	}{
+		{"0", 0},
+		{"15000", 1500000},
+		{"1250.75", 125075},
+		{"1250.7", 125070},
+		{"0.05", 5},
+		{".5", 50},
+		{"-2.50", -250},
+		{"99999999.99", 9999999999},
+		{"10.000", 1000},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.input)
+		if err != nil {
+			t.Errorf("ParseMoney(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ParseMoney(%q) = %d, expected %d", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestParseMoneyInvalid(t *testing.T) {
+	for _, input := range []string{"", "-", "abc", "1.2.3", "10.005", "1e3", "99999999999999999999"} {
+		if _, err := ParseMoney(input); err == nil {
+			t.Errorf("ParseMoney(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := map[Money]string{
+		0:       "0.00",
+		5:       "0.05",
+		125075:  "1250.75",
+		-250:    "-2.50",
+		1500000: "15000.00",
+	}
+	for m, expected := range tests {
+		if got := m.String(); got != expected {
+			t.Errorf("Money(%d).String() = %s, expected %s", int64(m), got, expected)
+		}
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	var product Product
+	if err := json.Unmarshal([]byte(`{"price": 19999.99}`), &product); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if product.Price != 1999999 {
+		t.Fatalf("Expected price 1999999 sen, got %d", product.Price)
+	}
+
+	if err := json.Unmarshal([]byte(`{"price": "2500.50"}`), &product); err != nil {
+		t.Fatalf("unexpected error for string price: %v", err)
+	}
+	if product.Price != 250050 {
+		t.Fatalf("Expected price 250050 sen, got %d", product.Price)
+	}
+
+	out, err := json.Marshal(map[string]Money{"total": 250050})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != `{"total":2500.50}` {
+		t.Errorf("Unexpected JSON: %s", out)
+	}
+}
+
+func TestMoneyLargeQuantity(t *testing.T) {
+	price, _ := ParseMoney("99999.99")
+	got := price.Mul(100000)
+	if got.String() != "9999999000.00" {
+		t.Errorf("Expected 9999999000.00, got %s", got)
+	}
+}
+
+func TestMoneyManyLineItems(t *testing.T) {
+	// 0.10 cannot be represented exactly as a float64, so summing it many
+	// times drifts. Money must stay exact.
+	price, _ := ParseMoney("0.10")
+	var total Money
+	for i := 0; i < 10000; i++ {
+		total += price.Mul(3)
+	}
+	if total.String() != "3000.00" {
+		t.Errorf("Expected 3000.00, got %s", total)
+	}
+}
+
+func TestMoneyMulDiv(t *testing.T) {
+	subtotal, _ := ParseMoney("100.00")
+	if got := subtotal.MulDiv(1, 3); got.String() != "33.33" {
+		t.Errorf("Expected 33.33, got %s", got)
+	}
+	if got := subtotal.MulDiv(2, 3); got.String() != "66.67" {
+		t.Errorf("Expected 66.67, got %s", got)
+	}
+	if got := Money(-10000).MulDiv(2, 3); got.String() != "-66.67" {
+		t.Errorf("Expected -66.67, got %s", got)
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	var m Money
+	if err := m.Scan([]byte("1234.56")); err != nil || m != 123456 {
+		t.Errorf("Scan([]byte) = %d, %v", m, err)
+	}
+	if err := m.Scan(int64(7)); err != nil || m != 700 {
+		t.Errorf("Scan(int64) = %d, %v", m, err)
+	}
+	if err := m.Scan(nil); err != nil || m != 0 {
+		t.Errorf("Scan(nil) = %d, %v", m, err)
+	}
+}
+
+func TestMoneyAllocate(t *testing.T) {
+	parts := Money(1000).Allocate([]Money{1, 1, 1})
+	var sum Money
+	for _, p := range parts {
+		sum += p
+	}
+	if sum != 1000 {
+		t.Errorf("Expected parts to add up to 1000, got %d (%v)", sum, parts)
+	}
+	if parts[0] != 334 || parts[1] != 333 || parts[2] != 333 {
+		t.Errorf("Unexpected allocation: %v", parts)
+	}
+
+	parts = Money(500).Allocate([]Money{3000, 1000})
+	if parts[0] != 375 || parts[1] != 125 {
+		t.Errorf("Unexpected proportional allocation: %v", parts)
+	}
+}
@@ -0,0 +1,43 @@
+package model
+
+const (
+	PaymentMethodCash      = "cash"
+	PaymentMethodDebitCard = "debit_card"
+	PaymentMethodQRIS      = "qris"
+	PaymentMethodEWallet   = "e_wallet"
+	PaymentMethodTransfer  = "transfer"
+)
+
+// PaymentMethods lists the accepted payment methods.
+var PaymentMethods = []string{
+	PaymentMethodCash,
+	PaymentMethodDebitCard,
+	PaymentMethodQRIS,
+	PaymentMethodEWallet,
+	PaymentMethodTransfer,
+}
+
+// Payment is one tender applied to a transaction. Amount is the part of the
+// bill it settles; for cash, Tendered may exceed Amount and the difference is
+// returned as Change.
+type Payment struct {
+	ID            int    `json:"id"`
+	TransactionID int    `json:"transaction_id"`
+	Method        string `json:"method"`
+	Amount        Money  `json:"amount"`
+	Tendered      Money  `json:"tendered"`
+	Change        Money  `json:"change"`
+	Reference     string `json:"reference,omitempty"`
+}
+
+type PaymentRequest struct {
+	Method    string `json:"method"`
+	Tendered  Money  `json:"tendered"`
+	Reference string `json:"reference"`
+}
+
+type PaymentMethodTotal struct {
+	Method string `json:"method"`
+	Total  Money  `json:"total"`
+	Count  int    `json:"count"`
+}
@@ -1,9 +1,136 @@
 package model
 
+// Units of measure. Products sold by weight, volume or length can be sold in
+// fractions of their unit; everything else is counted in whole pieces.
+const (
+	UnitPiece    = "pcs"
+	UnitKilogram = "kg"
+	UnitGram     = "g"
+	UnitLitre    = "l"
+	UnitMetre    = "m"
+)
+
+// Units lists every accepted unit of measure.
+var Units = []string{UnitPiece, UnitKilogram, UnitGram, UnitLitre, UnitMetre}
+
+// IsFractionalUnit reports whether quantities in unit may have decimals.
+func IsFractionalUnit(unit string) bool {
+	return unit == UnitKilogram || unit == UnitGram || unit == UnitLitre || unit == UnitMetre
+}
+
+// Product is an item for sale. CostPrice is what the store pays per unit and
+// is used for margin reporting. When stock falls to ReorderPoint the product
+// is low on stock and ReorderQuantity is the usual amount to order; a zero
+// reorder point turns the alert off. Stock and quantities are in Unit, which
+// decides whether they may be fractional. SKU is the store's own unique code and
+// Barcodes are the GS1 codes a till can scan to find the product. Conversions
+// are the packs the product is also sold in. Price is the retail price and
+// PriceTiers the cheaper prices for quantity breaks and price levels.
+//
+// A product can be the parent of variants, such as the sizes and colours of
+// a shirt. The parent lists the VariantAttributes its variants differ by and
+// each variant is a product of its own with a ParentID, a value for every
+// attribute, and its own SKU, barcodes and stock. A variant sells at its
+// PriceOverride, or at its parent's price when that is nil, and always uses
+// its parent's unit.
+//
+// A composite product, such as a gift hamper or a combo meal, is made of
+// Components. It keeps no stock of its own: selling one takes its components
+// out of stock, and its Stock is how many can be made from them.
 type Product struct {
-	ID         int     `json:"id"`
-	Name       string  `json:"name"`
-	Price      float64 `json:"price"`
-	Stock      int     `json:"stock"`
-	CategoryID int     `json:"category_id"`
+	ID         int      `json:"id"`
+	ParentID   *int     `json:"parent_id,omitempty"`
+	SKU        string   `json:"sku"`
+	Barcodes   []string `json:"barcodes"`
+	Name       string   `json:"name"`
+	Price      Money    `json:"price"`
+	CostPrice  Money    `json:"cost_price"`
+	Unit       string   `json:"unit"`
+	Stock      Quantity `json:"stock"`
+	CategoryID int      `json:"category_id"`
+	TaxClassID *int     `json:"tax_class_id"`
+
+	ReorderPoint    Quantity `json:"reorder_point"`
+	ReorderQuantity Quantity `json:"reorder_quantity"`
+
+	Conversions []UnitConversion `json:"conversions"`
+	PriceTiers  []PriceTier      `json:"price_tiers"`
+
+	VariantAttributes []string          `json:"variant_attributes,omitempty"`
+	Attributes        map[string]string `json:"attributes,omitempty"`
+	PriceOverride     *Money            `json:"price_override,omitempty"`
+	Variants          []Product         `json:"variants,omitempty"`
+
+	Components []Component `json:"components,omitempty"`
+}
+
+// IsComposite reports whether the product is made of other products.
+func (p Product) IsComposite() bool {
+	return len(p.Components) > 0
+}
+
+// Component is a product that goes into a composite product. Quantity is how
+// much of it, in its own unit, one composite takes.
+type Component struct {
+	ProductID   int      `json:"product_id"`
+	ProductName string   `json:"product_name"`
+	Quantity    Quantity `json:"quantity"`
+}
+
+// HasAttributes reports whether the product is a variant with exactly the
+// given attribute values.
+func (p Product) HasAttributes(attributes map[string]string) bool {
+	if len(p.Attributes) != len(attributes) {
+		return false
+	}
+	for name, value := range attributes {
+		if p.Attributes[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Conversion returns the product's conversion for unit.
+func (p Product) Conversion(unit string) (UnitConversion, bool) {
+	for _, c := range p.Conversions {
+		if c.Unit == unit {
+			return c, true
+		}
+	}
+	return UnitConversion{}, false
+}
+
+// UnitConversion is a pack a product is also sold in, such as a box of 12
+// pieces. Factor is how much of the product's own unit one pack holds, and
+// stock is always kept in that unit. Price is what one pack sells for and
+// Barcodes are the codes printed on the pack. Packs are sold whole.
+// LevelPrices are the pack's prices for customers on other price levels; a
+// level without one pays Price. The product's price tiers never apply to
+// packs.
+type UnitConversion struct {
+	ID          int              `json:"id"`
+	Unit        string           `json:"unit"`
+	Factor      Quantity         `json:"factor"`
+	Price       Money            `json:"price"`
+	LevelPrices map[string]Money `json:"level_prices,omitempty"`
+	Barcodes    []string         `json:"barcodes"`
+}
+
+// PriceTier is a price for customers on Level once a line reaches MinQuantity
+// of the product's own unit. A tier with no minimum quantity is the level's
+// standing price. Retail tiers apply to every customer.
+type PriceTier struct {
+	ID          int      `json:"id"`
+	Level       string   `json:"level"`
+	MinQuantity Quantity `json:"min_quantity"`
+	Price       Money    `json:"price"`
+}
+
+// BarcodeMatch is the product a barcode belongs to. Conversion is set when
+// the barcode is printed on one of the product's packs rather than on the
+// product itself.
+type BarcodeMatch struct {
+	Product    Product         `json:"product"`
+	Conversion *UnitConversion `json:"conversion,omitempty"`
 }
@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+const (
+	PromotionTypePercentage = "percentage"
+	PromotionTypeFixed      = "fixed"
+	PromotionTypeBuyXGetY   = "buy_x_get_y"
+	PromotionTypeBundle     = "bundle"
+	PromotionTypeMinSpend   = "min_spend"
+)
+
+// Promotion is a discount rule evaluated at checkout. Which fields are used
+// depends on Type:
+//
+//   - percentage: Percent off each unit of ProductIDs
+//   - fixed: Amount off each unit of ProductIDs
+//   - buy_x_get_y: for every BuyQuantity+GetQuantity units of a product in
+//     ProductIDs, GetQuantity units are free
+//   - bundle: one unit of every product in ProductIDs sells for BundlePrice
+//   - min_spend: Percent or Amount off the cart once it reaches MinSpend
+//
+// StartsAt/EndsAt bound the dates the promotion runs, and DailyStart/DailyEnd
+// ("HH:MM") optionally limit it to a time of day, e.g. a happy hour.
+type Promotion struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	ProductIDs  []int      `json:"product_ids,omitempty"`
+	Percent     int        `json:"percent,omitempty"`
+	Amount      Money      `json:"amount,omitempty"`
+	BuyQuantity int        `json:"buy_quantity,omitempty"`
+	GetQuantity int        `json:"get_quantity,omitempty"`
+	BundlePrice Money      `json:"bundle_price,omitempty"`
+	MinSpend    Money      `json:"min_spend,omitempty"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	DailyStart  string     `json:"daily_start,omitempty"`
+	DailyEnd    string     `json:"daily_end,omitempty"`
+	Active      bool       `json:"active"`
+}
+
+// AppliedDiscount records a promotion applied to a transaction. Discounts on a
+// single line carry its TransactionDetailID; cart-wide discounts leave it zero.
+type AppliedDiscount struct {
+	ID                  int    `json:"id"`
+	TransactionID       int    `json:"transaction_id"`
+	TransactionDetailID int    `json:"transaction_detail_id,omitempty"`
+	PromotionID         int    `json:"promotion_id"`
+	PromotionName       string `json:"promotion_name"`
+	Amount              Money  `json:"amount"`
+}
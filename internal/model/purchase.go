@@ -0,0 +1,93 @@
+package model
+
+import "time"
+
+// A partially received order the supplier will not complete is closed: its
+// receipts stand and nothing more is expected on it.
+const (
+	PurchaseOrderStatusOpen              = "open"
+	PurchaseOrderStatusPartiallyReceived = "partially_received"
+	PurchaseOrderStatusReceived          = "received"
+	PurchaseOrderStatusCancelled         = "cancelled"
+	PurchaseOrderStatusClosed            = "closed"
+)
+
+// StockReferenceGoodsReceipt marks stock movements caused by receiving goods.
+const StockReferenceGoodsReceipt = "goods_receipt"
+
+// PurchaseOrder is stock ordered from a supplier. TotalAmount is the value of
+// the order at the expected unit costs.
+type PurchaseOrder struct {
+	ID           int                 `json:"id"`
+	SupplierID   int                 `json:"supplier_id"`
+	SupplierName string              `json:"supplier_name,omitempty"`
+	Status       string              `json:"status"`
+	Note         string              `json:"note,omitempty"`
+	TotalAmount  Money               `json:"total_amount"`
+	CreatedAt    time.Time           `json:"created_at"`
+	Items        []PurchaseOrderItem `json:"items,omitempty"`
+	Receipts     []GoodsReceipt      `json:"receipts,omitempty"`
+}
+
+// PurchaseOrderItem is one line of an order. ProductID is 0 once the product
+// has been deleted; ProductName keeps its name from when it was ordered.
+type PurchaseOrderItem struct {
+	ID                  int      `json:"id"`
+	PurchaseOrderID     int      `json:"purchase_order_id"`
+	ProductID           int      `json:"product_id"`
+	ProductName         string   `json:"product_name,omitempty"`
+	Quantity            Quantity `json:"quantity"`
+	UnitCost            Money    `json:"unit_cost"`
+	ReceivedQuantity    Quantity `json:"received_quantity"`
+	OutstandingQuantity Quantity `json:"outstanding_quantity"`
+}
+
+// GoodsReceipt records a delivery against a purchase order. A delivery may
+// cover only part of the order.
+type GoodsReceipt struct {
+	ID              int                `json:"id"`
+	PurchaseOrderID int                `json:"purchase_order_id"`
+	SupplierID      int                `json:"supplier_id"`
+	Note            string             `json:"note,omitempty"`
+	TotalAmount     Money              `json:"total_amount"`
+	ReceivedAt      time.Time          `json:"received_at"`
+	Items           []GoodsReceiptItem `json:"items,omitempty"`
+}
+
+// GoodsReceiptItem is one received line. Like an order line it keeps the
+// product's name, and ProductID is 0 once the product has been deleted.
+type GoodsReceiptItem struct {
+	ID                  int      `json:"id"`
+	GoodsReceiptID      int      `json:"goods_receipt_id"`
+	PurchaseOrderItemID int      `json:"purchase_order_item_id"`
+	ProductID           int      `json:"product_id"`
+	ProductName         string   `json:"product_name,omitempty"`
+	Quantity            Quantity `json:"quantity"`
+	UnitCost            Money    `json:"unit_cost"`
+	Amount              Money    `json:"amount"`
+}
+
+type PurchaseOrderRequestItem struct {
+	ProductID int      `json:"product_id"`
+	Quantity  Quantity `json:"quantity"`
+	UnitCost  Money    `json:"unit_cost"`
+}
+
+type PurchaseOrderRequest struct {
+	SupplierID int                        `json:"supplier_id"`
+	Note       string                     `json:"note"`
+	Items      []PurchaseOrderRequestItem `json:"items"`
+}
+
+// GoodsReceiptRequestItem receives units of a purchase order line. UnitCost
+// is the invoiced cost and defaults to the cost expected on the order.
+type GoodsReceiptRequestItem struct {
+	PurchaseOrderItemID int      `json:"purchase_order_item_id"`
+	Quantity            Quantity `json:"quantity"`
+	UnitCost            *Money   `json:"unit_cost"`
+}
+
+type GoodsReceiptRequest struct {
+	Note  string                    `json:"note"`
+	Items []GoodsReceiptRequestItem `json:"items"`
+}
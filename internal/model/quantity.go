@@ -0,0 +1,169 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// QuantityScale is the number of Quantity steps in one unit.
+const QuantityScale = 1000
+
+// Quantity is an exact amount of a product stored as an integer number of
+// thousandths of its unit, matching the DECIMAL(12, 3) columns in the schema:
+// 3 pieces is 3000 and 1.25 kg is 1250. It is encoded in JSON as a plain
+// number without trailing zeros.
+type Quantity int64
+
+// WholeQuantity returns n whole units.
+func WholeQuantity(n int) Quantity {
+	return Quantity(n) * QuantityScale
+}
+
+// ParseQuantity parses a decimal string such as "3", "0.5" or "1.250". More
+// than three decimal places are rejected unless the extra digits are zero.
+func ParseQuantity(s string) (Quantity, error) {
+	v, err := parseDecimal(s, 3)
+	switch err {
+	case nil:
+		return Quantity(v), nil
+	case errEmptyDecimal:
+		return 0, fmt.Errorf("empty quantity value")
+	case errDecimalPlaces:
+		return 0, fmt.Errorf("quantity has more than three decimal places: %q", s)
+	case errDecimalRange:
+		return 0, fmt.Errorf("quantity out of range: %q", s)
+	default:
+		return 0, fmt.Errorf("invalid quantity: %q", s)
+	}
+}
+
+// IsWhole reports whether q is a whole number of units.
+func (q Quantity) IsWhole() bool {
+	return q%QuantityScale == 0
+}
+
+// Whole returns the number of whole units in q, dropping any fraction.
+func (q Quantity) Whole() int {
+	return int(q / QuantityScale)
+}
+
+// Ceil returns q rounded up to a whole number of units.
+func (q Quantity) Ceil() Quantity {
+	if q > 0 && !q.IsWhole() {
+		return (q/QuantityScale + 1) * QuantityScale
+	}
+	return q / QuantityScale * QuantityScale
+}
+
+// Mul returns q multiplied by another quantity, such as a number of packs
+// times the units in each, rounded to the nearest thousandth.
+func (q Quantity) Mul(factor Quantity) Quantity {
+	return Quantity(mulDivRound(int64(q), int64(factor), QuantityScale))
+}
+
+// Div returns q divided by another quantity, such as the number of packs q
+// units make up, rounded to the nearest thousandth. Dividing by zero gives
+// zero.
+func (q Quantity) Div(factor Quantity) Quantity {
+	if factor == 0 {
+		return 0
+	}
+	return Quantity(mulDivRound(int64(q), QuantityScale, int64(factor)))
+}
+
+// MulDiv returns q*num/den, such as the part of a line's stock a partial
+// refund returns, rounded to the nearest thousandth. A zero den gives zero.
+func (q Quantity) MulDiv(num, den Quantity) Quantity {
+	if den == 0 {
+		return 0
+	}
+	return Quantity(mulDivRound(int64(q), int64(num), int64(den)))
+}
+
+// mulDivRound returns v*num/den rounded half away from zero. den must be
+// positive.
+func mulDivRound(v, num, den int64) int64 {
+	product := v * num
+	q, r := product/den, product%den
+	if r < 0 {
+		r = -r
+	}
+	if r*2 >= den {
+		if product < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return q
+}
+
+// String formats the quantity with only the decimal places it needs, e.g.
+// "3", "0.5" or "1.125".
+func (q Quantity) String() string {
+	sign := ""
+	v := int64(q)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	s := fmt.Sprintf("%s%d", sign, v/QuantityScale)
+	if frac := v % QuantityScale; frac != 0 {
+		s += "." + strings.TrimRight(fmt.Sprintf("%03d", frac), "0")
+	}
+	return s
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// UnmarshalJSON accepts both JSON numbers and numeric strings, parsing the
+// literal text so no precision is lost through float64.
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	v, err := ParseQuantity(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+	*q = v
+	return nil
+}
+
+// Scan implements sql.Scanner for DECIMAL and integer columns.
+func (q *Quantity) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*q = 0
+		return nil
+	case []byte:
+		parsed, err := ParseQuantity(string(v))
+		if err != nil {
+			return err
+		}
+		*q = parsed
+		return nil
+	case string:
+		parsed, err := ParseQuantity(v)
+		if err != nil {
+			return err
+		}
+		*q = parsed
+		return nil
+	case int64:
+		*q = Quantity(v * QuantityScale)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Quantity", src)
+	}
+}
+
+// Value implements driver.Valuer so quantities are sent to the database as
+// exact decimal strings.
+func (q Quantity) Value() (driver.Value, error) {
+	return q.String(), nil
+}
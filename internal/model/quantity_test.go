@@ -0,0 +1,115 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := map[string]Quantity{
+		"0":      0,
+		"3":      3000,
+		"0.5":    500,
+		"1.250":  1250,
+		"0.005":  5,
+		"-2":     -2000,
+		"1.2500": 1250,
+	}
+	for input, expected := range tests {
+		got, err := ParseQuantity(input)
+		if err != nil {
+			t.Errorf("ParseQuantity(%q) returned error: %v", input, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("ParseQuantity(%q) = %d, expected %d", input, got, expected)
+		}
+	}
+
+	for _, input := range []string{"", "abc", "1.2345", "1e3"} {
+		if _, err := ParseQuantity(input); err == nil {
+			t.Errorf("ParseQuantity(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestQuantityString(t *testing.T) {
+	tests := map[Quantity]string{
+		0:     "0",
+		3000:  "3",
+		500:   "0.5",
+		1125:  "1.125",
+		5:     "0.005",
+		-2500: "-2.5",
+	}
+	for q, expected := range tests {
+		if got := q.String(); got != expected {
+			t.Errorf("Quantity(%d).String() = %s, expected %s", int64(q), got, expected)
+		}
+	}
+}
+
+func TestQuantityRounding(t *testing.T) {
+	if !WholeQuantity(4).IsWhole() || Quantity(4500).IsWhole() {
+		t.Error("IsWhole gave the wrong answer")
+	}
+	if got := Quantity(4500).Whole(); got != 4 {
+		t.Errorf("Whole() = %d, expected 4", got)
+	}
+	if got := Quantity(4001).Ceil(); got != 5000 {
+		t.Errorf("Ceil() = %d, expected 5000", got)
+	}
+	if got := Quantity(4000).Ceil(); got != 4000 {
+		t.Errorf("Ceil() = %d, expected 4000", got)
+	}
+}
+
+func TestQuantityMulDiv(t *testing.T) {
+	if got := WholeQuantity(3).Mul(WholeQuantity(12)); got != WholeQuantity(36) {
+		t.Errorf("3 x 12 = %s, expected 36", got)
+	}
+	if got := Quantity(1500).Mul(250); got != 375 {
+		t.Errorf("1.5 x 0.25 = %s, expected 0.375", got)
+	}
+	if got := WholeQuantity(30).Div(WholeQuantity(12)); got != 2500 {
+		t.Errorf("30 / 12 = %s, expected 2.5", got)
+	}
+	if got := WholeQuantity(10).Div(WholeQuantity(3)); got != 3333 {
+		t.Errorf("10 / 3 = %s, expected 3.333", got)
+	}
+	if got := WholeQuantity(5).Div(0); got != 0 {
+		t.Errorf("5 / 0 = %s, expected 0", got)
+	}
+	if got := WholeQuantity(10).MulDiv(WholeQuantity(1), WholeQuantity(3)); got != 3333 {
+		t.Errorf("10 x 1 / 3 = %s, expected 3.333", got)
+	}
+	if got := WholeQuantity(5).MulDiv(WholeQuantity(1), 0); got != 0 {
+		t.Errorf("5 x 1 / 0 = %s, expected 0", got)
+	}
+}
+
+func TestQuantityJSON(t *testing.T) {
+	var item TransactionRequestItem
+	if err := json.Unmarshal([]byte(`{"product_id":1,"quantity":1.25}`), &item); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if item.Quantity != 1250 {
+		t.Errorf("Expected 1250, got %d", item.Quantity)
+	}
+
+	out, _ := json.Marshal(item)
+	if string(out) != `{"product_id":1,"quantity":1.25}` {
+		t.Errorf("Unexpected JSON %s", out)
+	}
+}
+
+func TestMoneyMulQuantity(t *testing.T) {
+	// Rp 32.500/kg for 1.235 kg is Rp 40.137,50
+	if got := Money(3250000).MulQuantity(1235); got != 4013750 {
+		t.Errorf("MulQuantity = %s, expected 40137.50", got)
+	}
+	// Rp 12.999/kg for 0.333 kg is Rp 4.328,667 which rounds to 4.328,67
+	if got := Money(1299900).MulQuantity(333); got != 432867 {
+		t.Errorf("MulQuantity = %s, expected 4328.67", got)
+	}
+}
@@ -0,0 +1,65 @@
+package model
+
+import "time"
+
+const (
+	TransactionStatusCompleted         = "completed"
+	TransactionStatusPartiallyRefunded = "partially_refunded"
+	TransactionStatusRefunded          = "refunded"
+	TransactionStatusVoided            = "voided"
+)
+
+const (
+	RefundTypeVoid   = "void"
+	RefundTypeRefund = "refund"
+)
+
+// RefundReasonCodes lists the accepted reason codes for voids and refunds.
+var RefundReasonCodes = []string{
+	"customer_return",
+	"damaged",
+	"wrong_item",
+	"cashier_error",
+	"price_adjustment",
+	"other",
+}
+
+type Refund struct {
+	ID            int          `json:"id"`
+	TransactionID int          `json:"transaction_id"`
+	ShiftID       *int         `json:"shift_id,omitempty"`
+	Type          string       `json:"type"`
+	Method        string       `json:"method"`
+	ReasonCode    string       `json:"reason_code"`
+	Note          string       `json:"note,omitempty"`
+	TotalAmount   Money        `json:"total_amount"`
+	CreatedAt     time.Time    `json:"created_at"`
+	Items         []RefundItem `json:"items,omitempty"`
+}
+
+type RefundItem struct {
+	ID                  int      `json:"id"`
+	RefundID            int      `json:"refund_id"`
+	TransactionDetailID int      `json:"transaction_detail_id"`
+	ProductID           int      `json:"product_id"`
+	Quantity            Quantity `json:"quantity"`
+	Amount              Money    `json:"amount"`
+}
+
+type RefundRequestItem struct {
+	TransactionDetailID int      `json:"transaction_detail_id"`
+	Quantity            Quantity `json:"quantity"`
+}
+
+// RefundRequest describes a reversal. An empty Items list reverses every
+// quantity that has not been refunded yet. ShiftID may be left out when
+// exactly one shift is open. Method is how the money goes back to the
+// customer; it defaults to the method the sale was paid with and must be
+// given for a sale split across methods.
+type RefundRequest struct {
+	ShiftID    int                 `json:"shift_id"`
+	Method     string              `json:"method,omitempty"`
+	ReasonCode string              `json:"reason_code"`
+	Note       string              `json:"note"`
+	Items      []RefundRequestItem `json:"items"`
+}
@@ -0,0 +1,50 @@
+package model
+
+// ReorderCandidate is a product together with how much of it sold over the
+// sales window, how much is still due from suppliers and the supplier it was
+// last ordered from. SupplierID is zero for products that have never been on
+// a purchase order.
+type ReorderCandidate struct {
+	Product
+	SoldQuantity    Quantity
+	OnOrderQuantity Quantity
+	SupplierID      int
+	SupplierName    string
+}
+
+// ReorderSuggestion proposes ordering SuggestedQuantity of a product.
+// DailySales is the average sold per day over the sales window and
+// DaysOfCover is how long the current stock lasts at that rate; it is nil when
+// nothing sold. OnOrderQuantity is what open purchase orders still have to
+// deliver; it is counted as stock when deciding what to order.
+type ReorderSuggestion struct {
+	ProductID         int      `json:"product_id"`
+	ProductName       string   `json:"product_name"`
+	Stock             Quantity `json:"stock"`
+	ReorderPoint      Quantity `json:"reorder_point"`
+	ReorderQuantity   Quantity `json:"reorder_quantity"`
+	SoldQuantity      Quantity `json:"sold_quantity"`
+	OnOrderQuantity   Quantity `json:"on_order_quantity"`
+	DailySales        float64  `json:"daily_sales"`
+	DaysOfCover       *float64 `json:"days_of_cover"`
+	SuggestedQuantity Quantity `json:"suggested_quantity"`
+	UnitCost          Money    `json:"unit_cost"`
+	EstimatedCost     Money    `json:"estimated_cost"`
+}
+
+// SupplierReorder groups the suggestions for one supplier, ready to be turned
+// into a purchase order.
+type SupplierReorder struct {
+	SupplierID    int                 `json:"supplier_id"`
+	SupplierName  string              `json:"supplier_name"`
+	EstimatedCost Money               `json:"estimated_cost"`
+	Items         []ReorderSuggestion `json:"items"`
+}
+
+// ReorderReport lists what to order so stock lasts CoverDays, judging demand
+// by the last SalesDays of sales.
+type ReorderReport struct {
+	SalesDays int               `json:"sales_days"`
+	CoverDays int               `json:"cover_days"`
+	Suppliers []SupplierReorder `json:"suppliers"`
+}
@@ -0,0 +1,96 @@
+package model
+
+const (
+	GroupByDay      = "day"
+	GroupByWeek     = "week"
+	GroupByMonth    = "month"
+	GroupByProduct  = "product"
+	GroupByCategory = "category"
+)
+
+// SalesPeriod is one bucket of a sales report. Dates are YYYY-MM-DD and both
+// ends are inclusive. Voided transactions are excluded. TotalRefunds is what
+// was refunded during the period, whenever the sale was made, and NetSales is
+// TotalSales less TotalRefunds. AverageBasket is taken over TotalSales, as
+// the customers paid it.
+type SalesPeriod struct {
+	PeriodStart      string `json:"period_start"`
+	PeriodEnd        string `json:"period_end"`
+	TotalSales       Money  `json:"total_sales"`
+	TotalRefunds     Money  `json:"total_refunds"`
+	NetSales         Money  `json:"net_sales"`
+	TransactionCount int    `json:"transaction_count"`
+	AverageBasket    Money  `json:"average_basket"`
+}
+
+type SalesReport struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	GroupBy string        `json:"group_by"`
+	Periods []SalesPeriod `json:"periods"`
+}
+
+// ItemSales is one product or category in a sales breakdown. Quantity and
+// revenue are net of refunds; voided transactions are excluded. For a product
+// Quantity is in its own Unit, whatever it was sold in, and Packs gives the
+// same quantity in each of its packs. A parent product includes the sales of
+// its variants, which are also listed one by one under Variants.
+type ItemSales struct {
+	ID       int            `json:"id"`
+	ParentID int            `json:"-"`
+	Name     string         `json:"name"`
+	Unit     string         `json:"unit,omitempty"`
+	Quantity Quantity       `json:"quantity"`
+	Packs    []PackQuantity `json:"packs,omitempty"`
+	Revenue  Money          `json:"revenue"`
+	Share    Rate           `json:"share"`
+	Variants []ItemSales    `json:"variants,omitempty"`
+}
+
+// PackQuantity is a quantity expressed in one of a product's packs.
+type PackQuantity struct {
+	Unit     string   `json:"unit"`
+	Factor   Quantity `json:"factor"`
+	Quantity Quantity `json:"quantity"`
+}
+
+// SalesBreakdown ranks products or categories by revenue. Bottom lists the
+// weakest sellers first and includes items that did not sell at all.
+type SalesBreakdown struct {
+	From          string      `json:"from"`
+	To            string      `json:"to"`
+	TotalQuantity Quantity    `json:"total_quantity"`
+	TotalRevenue  Money       `json:"total_revenue"`
+	Top           []ItemSales `json:"top"`
+	Bottom        []ItemSales `json:"bottom"`
+}
+
+// ShareOf returns part as a percentage of whole.
+func ShareOf(part, whole Money) Rate {
+	return Rate(Money(10000).MulDiv(int(part), int(whole)))
+}
+
+// ProfitRow is the gross profit of one product, category or day. Revenue
+// excludes tax and service charge, and Cost uses the unit cost recorded at the
+// time of each sale. Margin is GrossProfit as a percentage of Revenue.
+type ProfitRow struct {
+	ID          int      `json:"id,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Date        string   `json:"date,omitempty"`
+	Quantity    Quantity `json:"quantity"`
+	Revenue     Money    `json:"revenue"`
+	Cost        Money    `json:"cost"`
+	GrossProfit Money    `json:"gross_profit"`
+	Margin      Rate     `json:"margin"`
+}
+
+type ProfitReport struct {
+	From        string      `json:"from"`
+	To          string      `json:"to"`
+	GroupBy     string      `json:"group_by"`
+	Revenue     Money       `json:"revenue"`
+	Cost        Money       `json:"cost"`
+	GrossProfit Money       `json:"gross_profit"`
+	Margin      Rate        `json:"margin"`
+	Rows        []ProfitRow `json:"rows"`
+}
@@ -0,0 +1,77 @@
+package model
+
+import "time"
+
+const (
+	ShiftStatusOpen   = "open"
+	ShiftStatusClosed = "closed"
+)
+
+const (
+	CashMovementIn  = "cash_in"
+	CashMovementOut = "cash_out"
+)
+
+// Denominations lists the Rupiah notes and coins accepted in a cash count.
+var Denominations = []Money{
+	10000000, 5000000, 2000000, 1000000, 500000, 200000, 100000,
+	50000, 20000, 10000, 5000,
+}
+
+// Shift is one cashier's session at the till. ExpectedCash, CountedCash and
+// Variance are only set once the shift is closed.
+type Shift struct {
+	ID            int                 `json:"id"`
+	CashierName   string              `json:"cashier_name"`
+	OpeningFloat  Money               `json:"opening_float"`
+	Status        string              `json:"status"`
+	OpenedAt      time.Time           `json:"opened_at"`
+	ClosedAt      *time.Time          `json:"closed_at,omitempty"`
+	ExpectedCash  *Money              `json:"expected_cash,omitempty"`
+	CountedCash   *Money              `json:"counted_cash,omitempty"`
+	Variance      *Money              `json:"variance,omitempty"`
+	Note          string              `json:"note,omitempty"`
+	CashMovements []CashMovement      `json:"cash_movements,omitempty"`
+	Denominations []DenominationCount `json:"denominations,omitempty"`
+}
+
+type CashMovement struct {
+	ID        int       `json:"id"`
+	ShiftID   int       `json:"shift_id"`
+	Type      string    `json:"type"`
+	Amount    Money     `json:"amount"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type DenominationCount struct {
+	Denomination Money `json:"denomination"`
+	Count        int   `json:"count"`
+}
+
+type OpenShiftRequest struct {
+	CashierName  string `json:"cashier_name"`
+	OpeningFloat Money  `json:"opening_float"`
+}
+
+type CloseShiftRequest struct {
+	Denominations []DenominationCount `json:"denominations"`
+	Note          string              `json:"note"`
+}
+
+// ShiftReport reconciles the cash drawer for a shift. Expected cash is the
+// opening float plus cash taken for sales, plus cash-ins, minus cash-outs and
+// refunds paid out during the shift. A negative variance means cash is short.
+type ShiftReport struct {
+	Shift            Shift                `json:"shift"`
+	TransactionCount int                  `json:"transaction_count"`
+	TotalSales       Money                `json:"total_sales"`
+	Payments         []PaymentMethodTotal `json:"payments"`
+	CashSales        Money                `json:"cash_sales"`
+	CashRefunds      Money                `json:"cash_refunds"`
+	CashIn           Money                `json:"cash_in"`
+	CashOut          Money                `json:"cash_out"`
+	ExpectedCash     Money                `json:"expected_cash"`
+	CountedCash      *Money               `json:"counted_cash,omitempty"`
+	Variance         *Money               `json:"variance,omitempty"`
+}
@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+const (
+	StockMovementSale       = "sale"
+	StockMovementRefund     = "refund"
+	StockMovementPurchase   = "purchase"
+	StockMovementAdjustment = "adjustment"
+	StockMovementTransfer   = "transfer"
+)
+
+// Reference documents a stock movement can point to.
+const (
+	StockReferenceTransaction = "transaction"
+	StockReferenceRefund      = "refund"
+	StockReferenceStocktake   = "stocktake"
+)
+
+// StockMovement is one immutable entry in a product's stock ledger. Quantity
+// is the signed change, so a product's stock is the sum of its movements.
+// ReferenceType and ReferenceID identify the document that caused it, such as
+// the sale's transaction.
+type StockMovement struct {
+	ID            int       `json:"id"`
+	ProductID     int       `json:"product_id"`
+	ProductName   string    `json:"product_name,omitempty"`
+	Type          string    `json:"type"`
+	Quantity      Quantity  `json:"quantity"`
+	ReferenceType string    `json:"reference_type,omitempty"`
+	ReferenceID   int       `json:"reference_id,omitempty"`
+	Note          string    `json:"note,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// StockDiscrepancy is a product whose stock column disagrees with the sum of
+// its ledger.
+type StockDiscrepancy struct {
+	ProductID   int      `json:"product_id"`
+	ProductName string   `json:"product_name"`
+	Stock       Quantity `json:"stock"`
+	LedgerStock Quantity `json:"ledger_stock"`
+	Difference  Quantity `json:"difference"`
+}
@@ -0,0 +1,76 @@
+package model
+
+import "time"
+
+const (
+	StocktakeStatusOpen      = "open"
+	StocktakeStatusPosted    = "posted"
+	StocktakeStatusCancelled = "cancelled"
+)
+
+// Stocktake is a stock opname session covering one category, or the whole
+// store when CategoryID is nil. System stock and unit cost are captured for
+// every product when the session starts, and variances are measured against
+// that snapshot.
+type Stocktake struct {
+	ID         int             `json:"id"`
+	CategoryID *int            `json:"category_id"`
+	Status     string          `json:"status"`
+	Note       string          `json:"note,omitempty"`
+	StartedAt  time.Time       `json:"started_at"`
+	PostedAt   *time.Time      `json:"posted_at,omitempty"`
+	Items      []StocktakeItem `json:"items,omitempty"`
+}
+
+// StocktakeItem is one product in a session. CountedQuantity is the sum of
+// every counter's count and stays nil until someone counts the product.
+// Adjustment is the stock change posting made: the count plus whatever moved
+// after the last count, less the stock at the time of posting.
+type StocktakeItem struct {
+	ProductID       int              `json:"product_id"`
+	ProductName     string           `json:"product_name"`
+	SystemStock     Quantity         `json:"system_stock"`
+	UnitCost        Money            `json:"unit_cost"`
+	CountedQuantity *Quantity        `json:"counted_quantity"`
+	Variance        Quantity         `json:"variance"`
+	VarianceValue   Money            `json:"variance_value"`
+	Adjustment      *Quantity        `json:"adjustment,omitempty"`
+	Counts          []StocktakeCount `json:"counts,omitempty"`
+}
+
+// StocktakeCount is what one counter found for a product, e.g. on one shelf.
+// Counting the same product again replaces that counter's previous count.
+type StocktakeCount struct {
+	ProductID   int       `json:"product_id"`
+	CounterName string    `json:"counter_name"`
+	Quantity    Quantity  `json:"quantity"`
+	CountedAt   time.Time `json:"counted_at"`
+}
+
+type StartStocktakeRequest struct {
+	CategoryID *int   `json:"category_id"`
+	Note       string `json:"note"`
+}
+
+type StocktakeCountItem struct {
+	ProductID int      `json:"product_id"`
+	Quantity  Quantity `json:"quantity"`
+}
+
+type SubmitCountsRequest struct {
+	CounterName string               `json:"counter_name"`
+	Counts      []StocktakeCountItem `json:"counts"`
+}
+
+// StocktakeReport is the variance review of a session. Uncounted products
+// are left out of the totals and are not adjusted when the session is posted.
+type StocktakeReport struct {
+	Stocktake        Stocktake `json:"stocktake"`
+	CountedItems     int       `json:"counted_items"`
+	UncountedItems   int       `json:"uncounted_items"`
+	SurplusQuantity  Quantity  `json:"surplus_quantity"`
+	ShortageQuantity Quantity  `json:"shortage_quantity"`
+	SurplusValue     Money     `json:"surplus_value"`
+	ShortageValue    Money     `json:"shortage_value"`
+	NetVarianceValue Money     `json:"net_variance_value"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Supplier is a vendor the store buys stock from. Payable is what the store
+// still owes: the value of goods received minus payments made, and is
+// read-only.
+type Supplier struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Phone   string `json:"phone"`
+	Email   string `json:"email"`
+	Address string `json:"address"`
+	Payable Money  `json:"payable"`
+}
+
+// SupplierPayment settles part of a supplier's payable balance.
+type SupplierPayment struct {
+	ID         int       `json:"id"`
+	SupplierID int       `json:"supplier_id"`
+	Amount     Money     `json:"amount"`
+	Method     string    `json:"method"`
+	Reference  string    `json:"reference,omitempty"`
+	PaidAt     time.Time `json:"paid_at"`
+}
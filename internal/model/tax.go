@@ -0,0 +1,80 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// Rate is a percentage stored in basis points, so 11% is Rate(1100). Like
+// Money it is encoded in JSON and the database as a two-decimal number.
+type Rate int
+
+// ParseRate parses a percentage such as "11", "12.00" or "5.5".
+func ParseRate(s string) (Rate, error) {
+	m, err := ParseMoney(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate: %q", s)
+	}
+	return Rate(m), nil
+}
+
+func (r Rate) String() string {
+	return Money(r).String()
+}
+
+// Of returns the rate applied to amount, e.g. 11% of 10000.00 is 1100.00.
+func (r Rate) Of(amount Money) Money {
+	return amount.MulDiv(int(r), 10000)
+}
+
+// IncludedIn returns the part of a tax-inclusive amount that is tax, e.g. the
+// 11% tax included in 11100.00 is 1100.00.
+func (r Rate) IncludedIn(amount Money) Money {
+	return amount.MulDiv(int(r), 10000+int(r))
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return Money(r).MarshalJSON()
+}
+
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+	v, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+func (r *Rate) Scan(src interface{}) error {
+	var m Money
+	if err := m.Scan(src); err != nil {
+		return err
+	}
+	*r = Rate(m)
+	return nil
+}
+
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// TaxClass is a tax rule products can be assigned to. When Inclusive is true
+// the product price already contains the tax; otherwise tax is added on top.
+type TaxClass struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Rate      Rate   `json:"rate"`
+	Inclusive bool   `json:"inclusive"`
+}
+
+type TaxReport struct {
+	Date          string `json:"date"`
+	TaxableAmount Money  `json:"taxable_amount"`
+	TaxAmount     Money  `json:"tax_amount"`
+}
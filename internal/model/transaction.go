@@ -2,32 +2,113 @@ package model
 
 import "time"
 
+// Transaction is a completed sale. Subtotal is the sum of the line amounts
+// after discounts, and TotalAmount is what the customer pays: Subtotal plus
+// any tax not already included in prices, plus the service charge.
+// DiscountAmount is the sum of every promotion applied; Discounts lists only
+// the cart-wide ones, line promotions are on each detail. PriceLevel is the
+// customer's price level at the time of sale, retail for walk-in customers.
 type Transaction struct {
-	ID          int                 `json:"id"`
-	TotalAmount float64             `json:"total_amount"`
-	CreatedAt   time.Time           `json:"created_at"`
-	Details     []TransactionDetail `json:"details,omitempty"`
+	ID             int                 `json:"id"`
+	ShiftID        *int                `json:"shift_id,omitempty"`
+	CustomerID     *int                `json:"customer_id,omitempty"`
+	PriceLevel     string              `json:"price_level"`
+	Subtotal       Money               `json:"subtotal"`
+	TaxAmount      Money               `json:"tax_amount"`
+	ServiceCharge  Money               `json:"service_charge"`
+	TotalAmount    Money               `json:"total_amount"`
+	DiscountAmount Money               `json:"discount_amount"`
+	Status         string              `json:"status"`
+	CreatedAt      time.Time           `json:"created_at"`
+	Details        []TransactionDetail `json:"details,omitempty"`
+	Discounts      []AppliedDiscount   `json:"discounts,omitempty"`
+	Payments       []Payment           `json:"payments,omitempty"`
+	Change         Money               `json:"change"`
+	Refunds        []Refund            `json:"refunds,omitempty"`
 }
 
+// TransactionDetail is one sold line. DiscountAmount includes the line's share
+// of any cart-wide discount, so Subtotal is always what was paid for the line.
+// The product's name, category, unit, price and cost are copied onto the line
+// at the time of sale, so renaming or repricing a product does not change
+// history. A line sold in packs has the pack's unit and price, and
+// BaseQuantity is the quantity in the product's own unit taken from stock.
+// When UnitPrice came from a price tier, PriceTierID, PriceLevel and
+// TierMinQuantity record which one; a pack sold at a level's pack price
+// records only PriceLevel.
+// ProductID is zero once the product has been deleted.
 type TransactionDetail struct {
-	ID            int     `json:"id"`
-	TransactionID int     `json:"transaction_id"`
-	ProductID     int     `json:"product_id"`
-	Quantity      int     `json:"quantity"`
-	Subtotal      float64 `json:"subtotal"`
+	ID              int               `json:"id"`
+	TransactionID   int               `json:"transaction_id"`
+	ProductID       int               `json:"product_id"`
+	ProductName     string            `json:"product_name"`
+	CategoryID      int               `json:"category_id"`
+	CategoryName    string            `json:"category_name"`
+	Unit            string            `json:"unit"`
+	Quantity        Quantity          `json:"quantity"`
+	BaseQuantity    Quantity          `json:"base_quantity"`
+	UnitPrice       Money             `json:"unit_price"`
+	PriceTierID     int               `json:"price_tier_id,omitempty"`
+	PriceLevel      string            `json:"price_level,omitempty"`
+	TierMinQuantity *Quantity         `json:"tier_min_quantity,omitempty"`
+	DiscountAmount  Money             `json:"discount_amount"`
+	Subtotal        Money             `json:"subtotal"`
+	UnitCost        Money             `json:"unit_cost"`
+	TaxRate         Rate              `json:"tax_rate"`
+	TaxInclusive    bool              `json:"tax_inclusive"`
+	TaxAmount       Money             `json:"tax_amount"`
+	ServiceCharge   Money             `json:"service_charge"`
+	Discounts       []AppliedDiscount `json:"discounts,omitempty"`
 }
 
+// TransactionRequestItem is one product in a checkout, identified either by
+// its ID or by a scanned barcode. Quantity is left out for a scale barcode,
+// which carries its own weight or price. Unit sells the product in one of
+// its packs; a pack's barcode selects the pack by itself. Attributes pick a
+// variant when the product is the parent of variants.
 type TransactionRequestItem struct {
-	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+	ProductID  int               `json:"product_id"`
+	Barcode    string            `json:"barcode,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Unit       string            `json:"unit,omitempty"`
+	Quantity   Quantity          `json:"quantity"`
 }
 
+// TransactionRequest is a checkout. ShiftID may be left out when exactly one
+// shift is open, and CustomerID for a walk-in customer.
 type TransactionRequest struct {
-	Items []TransactionRequestItem `json:"items"`
+	ShiftID    int                      `json:"shift_id"`
+	CustomerID int                      `json:"customer_id"`
+	Items      []TransactionRequestItem `json:"items"`
+	Payments   []PaymentRequest         `json:"payments"`
+}
+
+// TransactionFilter narrows the transaction history listing. Nil pointers and
+// zero values mean "no filter" for that field. To is exclusive.
+type TransactionFilter struct {
+	From      *time.Time
+	To        *time.Time
+	MinAmount *Money
+	MaxAmount *Money
+	ProductID int
+	Page      int
+	Limit     int
+}
+
+type Pagination struct {
+	Page       int `json:"page"`
+	Limit      int `json:"limit"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
 }
 
 type DailyReport struct {
-	Date             string  `json:"date"`
-	TotalSales       float64 `json:"total_sales"`
-	TransactionCount int     `json:"transaction_count"`
+	Date             string               `json:"date"`
+	TotalSales       Money                `json:"total_sales"`
+	TotalRefunds     Money                `json:"total_refunds"`
+	NetSales         Money                `json:"net_sales"`
+	TotalTax         Money                `json:"total_tax"`
+	ServiceCharge    Money                `json:"service_charge"`
+	TransactionCount int                  `json:"transaction_count"`
+	Payments         []PaymentMethodTotal `json:"payments"`
 }
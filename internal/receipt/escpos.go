@@ -0,0 +1,28 @@
+package receipt
+
+// ESC/POS commands understood by 58mm and 80mm thermal printers.
+const (
+	escInit     = "\x1b@"      // ESC @: reset the printer
+	escCodePage = "\x1bt\x00"  // ESC t 0: PC437 code page
+	escFeed     = "\x1bd\x04"  // ESC d 4: feed four lines so the cut clears the text
+	escCut      = "\x1dVB\x00" // GS V 66 0: feed to the cutter and partial cut
+)
+
+// escposDocument wraps rendered receipt text in the commands needed to print
+// and cut it. Characters outside ASCII are replaced with '?' since the
+// printer's code page cannot be relied on for them.
+func escposDocument(body []byte) []byte {
+	out := make([]byte, 0, len(body)+len(escInit)+len(escCodePage)+len(escFeed)+len(escCut))
+	out = append(out, escInit...)
+	out = append(out, escCodePage...)
+	for _, r := range string(body) {
+		if r > 0x7e {
+			out = append(out, '?')
+			continue
+		}
+		out = append(out, byte(r))
+	}
+	out = append(out, escFeed...)
+	out = append(out, escCut...)
+	return out
+}
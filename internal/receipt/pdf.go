@@ -0,0 +1,84 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Page sizes in PDF points (1/72 inch) for thermal roll widths.
+const (
+	pdfWidth58mm = 164.41
+	pdfWidth80mm = 226.77
+	pdfMargin    = 8.0
+	// Courier glyphs are 0.6 em wide
+	courierAdvance = 0.6
+)
+
+// pdfDocument lays the rendered receipt text out on a single page the width
+// of a thermal roll and as tall as the receipt. It uses the built-in Courier
+// font so no font needs embedding, and contains no timestamps, so the same
+// receipt always produces the same bytes.
+func pdfDocument(body []byte, width int) []byte {
+	pageWidth := pdfWidth80mm
+	if width <= Width58mm {
+		pageWidth = pdfWidth58mm
+	}
+	fontSize := (pageWidth - 2*pdfMargin) / (float64(width) * courierAdvance)
+	leading := fontSize * 1.2
+
+	lines := strings.Split(strings.TrimRight(string(body), "\n"), "\n")
+	pageHeight := 2*pdfMargin + float64(len(lines))*leading
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "BT\n/F1 %.2f Tf\n%.2f TL\n%.2f %.2f Td\n", fontSize, leading, pdfMargin, pageHeight-pdfMargin-fontSize)
+	for i, l := range lines {
+		if i > 0 {
+			content.WriteString("T*\n")
+		}
+		fmt.Fprintf(&content, "(%s) Tj\n", pdfEscape(l))
+	}
+	content.WriteString("ET\n")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>", pageWidth, pageHeight),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// pdfEscape makes s safe inside a PDF literal string. Characters outside
+// ASCII are replaced with '?' because the standard fonts only cover Latin-1.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,209 @@
+package receipt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"kasir-api/internal/model"
+	"os"
+	"strings"
+	"text/template"
+)
+
+const (
+	FormatText   = "text"
+	FormatESCPOS = "escpos"
+	FormatPDF    = "pdf"
+)
+
+// Paper widths in characters for the common thermal roll sizes.
+const (
+	Width58mm = 32
+	Width80mm = 48
+)
+
+// Store is the header and footer printed on every receipt.
+type Store struct {
+	Name    string
+	Address string
+	Phone   string
+	Footer  string
+}
+
+// Renderer turns transactions into receipts. The layout comes from a
+// text/template so stores can change it without a rebuild; the same template
+// drives the text, ESC/POS and PDF output.
+type Renderer struct {
+	store  Store
+	layout string
+}
+
+// NewRenderer creates a renderer using the template at templatePath, or the
+// built-in layout when templatePath is empty.
+func NewRenderer(store Store, templatePath string) (*Renderer, error) {
+	layout := defaultTemplate
+	if templatePath != "" {
+		b, err := os.ReadFile(templatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read receipt template: %w", err)
+		}
+		layout = string(b)
+	}
+
+	// Parse once up front so a broken template fails at startup
+	if _, err := template.New("receipt").Funcs(templateFuncs(Width58mm, false)).Parse(layout); err != nil {
+		return nil, fmt.Errorf("failed to parse receipt template: %w", err)
+	}
+	return &Renderer{store: store, layout: layout}, nil
+}
+
+// Render produces the receipt in format and returns it with its content type.
+// width is the paper width in characters.
+func (r *Renderer) Render(transaction model.Transaction, format string, width int) ([]byte, string, error) {
+	if width <= 0 {
+		return nil, "", errors.New("width must be greater than zero")
+	}
+
+	switch format {
+	case FormatText, "":
+		b, err := r.execute(transaction, width, false)
+		return b, "text/plain; charset=utf-8", err
+	case FormatESCPOS:
+		b, err := r.execute(transaction, width, true)
+		if err != nil {
+			return nil, "", err
+		}
+		return escposDocument(b), "application/octet-stream", nil
+	case FormatPDF:
+		b, err := r.execute(transaction, width, false)
+		if err != nil {
+			return nil, "", err
+		}
+		return pdfDocument(b, width), "application/pdf", nil
+	default:
+		return nil, "", fmt.Errorf("unsupported receipt format: %s", format)
+	}
+}
+
+func (r *Renderer) execute(transaction model.Transaction, width int, escpos bool) ([]byte, error) {
+	tmpl, err := template.New("receipt").Funcs(templateFuncs(width, escpos)).Parse(r.layout)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, newView(r.store, transaction)); err != nil {
+		return nil, fmt.Errorf("failed to render receipt: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// view is the data available to receipt templates.
+type view struct {
+	Store         Store
+	Number        string
+	Date          string
+	Lines         []line
+	CartDiscounts []discount
+	Discount      model.Money
+	Subtotal      model.Money
+	Tax           model.Money
+	ServiceCharge model.Money
+	Total         model.Money
+	Payments      []payment
+	Change        model.Money
+}
+
+type line struct {
+	Name      string
+	Quantity  string
+	UnitPrice model.Money
+	Amount    model.Money
+	Discounts []discount
+}
+
+type discount struct {
+	Name   string
+	Amount model.Money
+}
+
+type payment struct {
+	Method string
+	Amount model.Money
+}
+
+var paymentLabels = map[string]string{
+	model.PaymentMethodCash:      "Cash",
+	model.PaymentMethodDebitCard: "Debit Card",
+	model.PaymentMethodQRIS:      "QRIS",
+	model.PaymentMethodEWallet:   "E-Wallet",
+	model.PaymentMethodTransfer:  "Transfer",
+}
+
+func newView(store Store, t model.Transaction) view {
+	v := view{
+		Store:         store,
+		Number:        fmt.Sprintf("%d", t.ID),
+		Date:          t.CreatedAt.Format("02/01/2006 15:04"),
+		Discount:      t.DiscountAmount,
+		Subtotal:      t.Subtotal,
+		Tax:           t.TaxAmount,
+		ServiceCharge: t.ServiceCharge,
+		Total:         t.TotalAmount,
+		Change:        t.Change,
+	}
+
+	for _, d := range t.Details {
+		// The detail discount also holds the line's share of cart discounts,
+		// which are printed once in the totals instead.
+		l := line{Name: d.ProductName, Quantity: quantityLabel(d), UnitPrice: d.UnitPrice, Amount: d.Subtotal + d.DiscountAmount}
+		for _, ad := range d.Discounts {
+			l.Discounts = append(l.Discounts, discount{Name: ad.PromotionName, Amount: ad.Amount})
+		}
+		v.Lines = append(v.Lines, l)
+	}
+	for _, ad := range t.Discounts {
+		v.CartDiscounts = append(v.CartDiscounts, discount{Name: ad.PromotionName, Amount: ad.Amount})
+	}
+	for _, p := range t.Payments {
+		label, ok := paymentLabels[p.Method]
+		if !ok {
+			label = p.Method
+		}
+		v.Payments = append(v.Payments, payment{Method: label, Amount: p.Tendered})
+	}
+	return v
+}
+
+// FormatRupiah formats an amount the Indonesian way, e.g. 12.500 or 12.500,50.
+func FormatRupiah(m model.Money) string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	digits := fmt.Sprintf("%d", v/100)
+	var grouped strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte('.')
+		}
+		grouped.WriteRune(c)
+	}
+
+	if sen := v % 100; sen != 0 {
+		return fmt.Sprintf("%s%s,%02d", sign, grouped.String(), sen)
+	}
+	return sign + grouped.String()
+}
+
+// quantityLabel prints a line's quantity, naming the unit for anything not
+// sold by the piece, e.g. "1.25 kg".
+func quantityLabel(d model.TransactionDetail) string {
+	if d.Unit == "" || d.Unit == model.UnitPiece {
+		return d.Quantity.String()
+	}
+	return d.Quantity.String() + " " + d.Unit
+}
@@ -0,0 +1,139 @@
+package receipt
+
+import (
+	"bytes"
+	"flag"
+	"kasir-api/internal/model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func sampleTransaction() model.Transaction {
+	return model.Transaction{
+		ID:             42,
+		CreatedAt:      time.Date(2026, 3, 10, 14, 5, 0, 0, time.UTC),
+		Subtotal:       4500000,
+		TaxAmount:      495000,
+		ServiceCharge:  225000,
+		TotalAmount:    5220000,
+		DiscountAmount: 500000,
+		Details: []model.TransactionDetail{
+			{
+				ProductName:    "Kopi Susu Gula Aren",
+				Quantity:       model.WholeQuantity(2),
+				UnitPrice:      1500000,
+				DiscountAmount: 300000,
+				Subtotal:       2700000,
+				Discounts:      []model.AppliedDiscount{{PromotionName: "Happy Hour 10%", Amount: 300000}},
+			},
+			{
+				ProductName:    "Roti Bakar Cokelat Keju Spesial Jumbo",
+				Quantity:       model.WholeQuantity(1),
+				UnitPrice:      2000000,
+				DiscountAmount: 200000,
+				Subtotal:       1800000,
+			},
+		},
+		Discounts: []model.AppliedDiscount{{PromotionName: "Min. spend 40k", Amount: 200000}},
+		Payments: []model.Payment{
+			{Method: model.PaymentMethodQRIS, Amount: 2000000, Tendered: 2000000},
+			{Method: model.PaymentMethodCash, Amount: 3220000, Tendered: 5000000, Change: 1780000},
+		},
+		Change: 1780000,
+	}
+}
+
+func sampleStore() Store {
+	return Store{
+		Name:    "TOKO MAJU JAYA",
+		Address: "Jl. Merdeka No. 1, Bandung",
+		Phone:   "022-1234567",
+		Footer:  "Terima kasih!",
+	}
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s does not match golden file\ngot:\n%q\nwant:\n%q", name, got, want)
+	}
+}
+
+func TestRenderGolden(t *testing.T) {
+	r, err := NewRenderer(sampleStore(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		golden string
+		format string
+		width  int
+	}{
+		{"receipt_58mm.txt", FormatText, Width58mm},
+		{"receipt_80mm.txt", FormatText, Width80mm},
+		{"receipt_58mm.escpos", FormatESCPOS, Width58mm},
+		{"receipt_80mm.escpos", FormatESCPOS, Width80mm},
+		{"receipt_58mm.pdf", FormatPDF, Width58mm},
+	}
+
+	for _, tt := range tests {
+		got, _, err := r.Render(sampleTransaction(), tt.format, tt.width)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.golden, err)
+		}
+		checkGolden(t, tt.golden, got)
+	}
+}
+
+func TestRenderUnsupportedFormat(t *testing.T) {
+	r, _ := NewRenderer(sampleStore(), "")
+	if _, _, err := r.Render(sampleTransaction(), "html", Width58mm); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+func TestFormatRupiah(t *testing.T) {
+	tests := map[model.Money]string{
+		0:          "0",
+		150000:     "1.500",
+		1234567850: "12.345.678,50",
+		-250000:    "-2.500",
+	}
+	for m, expected := range tests {
+		if got := FormatRupiah(m); got != expected {
+			t.Errorf("FormatRupiah(%d) = %s, expected %s", int64(m), got, expected)
+		}
+	}
+}
+
+func TestQuantityLabel(t *testing.T) {
+	tests := []struct {
+		detail   model.TransactionDetail
+		expected string
+	}{
+		{model.TransactionDetail{Quantity: model.WholeQuantity(2)}, "2"},
+		{model.TransactionDetail{Unit: model.UnitPiece, Quantity: model.WholeQuantity(3)}, "3"},
+		{model.TransactionDetail{Unit: model.UnitKilogram, Quantity: 1250}, "1.25 kg"},
+	}
+	for _, tt := range tests {
+		if got := quantityLabel(tt.detail); got != tt.expected {
+			t.Errorf("quantityLabel(%+v) = %q, expected %q", tt.detail, got, tt.expected)
+		}
+	}
+}
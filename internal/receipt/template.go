@@ -0,0 +1,111 @@
+package receipt
+
+import (
+	"kasir-api/internal/model"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// ESC/POS control sequences emitted by the bold template function.
+const (
+	escBoldOn  = "\x1bE\x01"
+	escBoldOff = "\x1bE\x00"
+)
+
+// defaultTemplate is the built-in receipt layout. Templates can use:
+//
+//	center s         s centred on the paper
+//	wrap s           s word-wrapped to the paper width
+//	columns l r      l on the left and r right-aligned on the same row
+//	rule             a full-width dashed line
+//	bold s           s in bold (ESC/POS only, plain text elsewhere)
+//	rupiah m         an amount formatted as 12.500
+const defaultTemplate = `{{bold (center .Store.Name)}}
+{{with .Store.Address}}{{center .}}
+{{end}}{{with .Store.Phone}}{{center .}}
+{{end}}{{rule}}
+{{columns (print "No. " .Number) .Date}}
+{{rule}}
+{{range .Lines}}{{wrap .Name}}
+{{columns (print "  " .Quantity " x " (rupiah .UnitPrice)) (rupiah .Amount)}}
+{{range .Discounts}}{{columns (print "  " .Name) (print "-" (rupiah .Amount))}}
+{{end}}{{end}}{{rule}}
+{{range .CartDiscounts}}{{columns .Name (print "-" (rupiah .Amount))}}
+{{end}}{{if .Discount}}{{columns "Total Discount" (print "-" (rupiah .Discount))}}
+{{end}}{{columns "Subtotal" (rupiah .Subtotal)}}
+{{if .Tax}}{{columns "Tax" (rupiah .Tax)}}
+{{end}}{{if .ServiceCharge}}{{columns "Service Charge" (rupiah .ServiceCharge)}}
+{{end}}{{bold (columns "TOTAL" (rupiah .Total))}}
+{{rule}}
+{{range .Payments}}{{columns .Method (rupiah .Amount)}}
+{{end}}{{if .Change}}{{columns "Change" (rupiah .Change)}}
+{{end}}{{with .Store.Footer}}{{rule}}
+{{center .}}
+{{end}}`
+
+func templateFuncs(width int, escpos bool) template.FuncMap {
+	return template.FuncMap{
+		"center": func(s string) string {
+			n := utf8.RuneCountInString(s)
+			if n >= width {
+				return s
+			}
+			return strings.Repeat(" ", (width-n)/2) + s
+		},
+		"wrap": func(s string) string {
+			return wrap(s, width)
+		},
+		"columns": func(left, right string) string {
+			gap := width - utf8.RuneCountInString(left) - utf8.RuneCountInString(right)
+			if gap < 1 {
+				// Too long for one row: right column goes on its own line
+				return left + "\n" + strings.Repeat(" ", max(width-utf8.RuneCountInString(right), 0)) + right
+			}
+			return left + strings.Repeat(" ", gap) + right
+		},
+		"rule": func() string {
+			return strings.Repeat("-", width)
+		},
+		"bold": func(s string) string {
+			if escpos {
+				return escBoldOn + s + escBoldOff
+			}
+			return s
+		},
+		"rupiah": func(m model.Money) string {
+			return FormatRupiah(m)
+		},
+	}
+}
+
+// wrap breaks s into lines of at most width characters, splitting on spaces
+// where possible and hard-breaking words that are longer than a line.
+func wrap(s string, width int) string {
+	var lines []string
+	var current []rune
+	for _, word := range strings.Fields(s) {
+		w := []rune(word)
+		for len(w) > width {
+			if len(current) > 0 {
+				lines = append(lines, string(current))
+				current = nil
+			}
+			lines = append(lines, string(w[:width]))
+			w = w[width:]
+		}
+		switch {
+		case len(current) == 0:
+			current = w
+		case len(current)+1+len(w) <= width:
+			current = append(append(current, ' '), w...)
+		default:
+			lines = append(lines, string(current))
+			current = w
+		}
+	}
+	if len(current) > 0 {
+		lines = append(lines, string(current))
+	}
+	return strings.Join(lines, "\n")
+}
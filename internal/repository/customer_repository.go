@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"database/sql"
+	"kasir-api/internal/model"
+)
+
+type CustomerRepository interface {
+	Create(customer model.Customer) (model.Customer, error)
+	GetAll() ([]model.Customer, error)
+	GetByID(id int) (model.Customer, error)
+	Update(id int, customer model.Customer) (model.Customer, error)
+	Delete(id int) error
+}
+
+type customerRepository struct {
+	db *sql.DB
+}
+
+func NewCustomerRepository(db *sql.DB) CustomerRepository {
+	return &customerRepository{db: db}
+}
+
+const customerColumns = `id, name, COALESCE(phone, ''), COALESCE(email, ''), price_level`
+
+func scanCustomer(scanner interface{ Scan(...interface{}) error }) (model.Customer, error) {
+	var c model.Customer
+	if err := scanner.Scan(&c.ID, &c.Name, &c.Phone, &c.Email, &c.PriceLevel); err != nil {
+		return model.Customer{}, err
+	}
+	return c, nil
+}
+
+func (r *customerRepository) Create(customer model.Customer) (model.Customer, error) {
+	query := `INSERT INTO customers (name, phone, email, price_level) VALUES ($1, $2, $3, $4) RETURNING id`
+	err := r.db.QueryRow(query, customer.Name, customer.Phone, customer.Email, customer.PriceLevel).Scan(&customer.ID)
+	if err != nil {
+		return model.Customer{}, err
+	}
+	return customer, nil
+}
+
+func (r *customerRepository) GetAll() ([]model.Customer, error) {
+	rows, err := r.db.Query(`SELECT ` + customerColumns + ` FROM customers ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	customers := []model.Customer{}
+	for rows.Next() {
+		c, err := scanCustomer(rows)
+		if err != nil {
+			return nil, err
+		}
+		customers = append(customers, c)
+	}
+	return customers, rows.Err()
+}
+
+func (r *customerRepository) GetByID(id int) (model.Customer, error) {
+	return scanCustomer(r.db.QueryRow(`SELECT `+customerColumns+` FROM customers WHERE id = $1`, id))
+}
+
+func (r *customerRepository) Update(id int, customer model.Customer) (model.Customer, error) {
+	query := `UPDATE customers SET name = $1, phone = $2, email = $3, price_level = $4 WHERE id = $5 RETURNING ` + customerColumns
+	return scanCustomer(r.db.QueryRow(query, customer.Name, customer.Phone, customer.Email, customer.PriceLevel, id))
+}
+
+func (r *customerRepository) Delete(id int) error {
+	_, err := r.db.Exec(`DELETE FROM customers WHERE id = $1`, id)
+	return err
+}
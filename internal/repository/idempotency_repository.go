@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"kasir-api/internal/model"
+	"time"
+)
+
+type IdempotencyRepository interface {
+	Reserve(ctx context.Context, key, requestHash, token string, staleAfter time.Duration) (model.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, key, token string, statusCode int, body []byte) error
+	Release(ctx context.Context, key, token string) error
+}
+
+type idempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// Reserve claims key for a new request holding token. It returns true when
+// the key was claimed, or false together with the existing record when it was
+// already taken. A reservation for the same request that has not completed
+// within staleAfter is taken over, since the request that made it is gone;
+// should it turn up after all, its token no longer matches.
+func (r *idempotencyRepository) Reserve(ctx context.Context, key, requestHash, token string, staleAfter time.Duration) (model.IdempotencyRecord, bool, error) {
+	query := `
+		INSERT INTO idempotency_keys (key, request_hash, owner_token) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET reserved_at = CURRENT_TIMESTAMP, owner_token = EXCLUDED.owner_token
+		WHERE idempotency_keys.status_code IS NULL
+			AND idempotency_keys.request_hash = EXCLUDED.request_hash
+			AND idempotency_keys.reserved_at < CURRENT_TIMESTAMP - make_interval(secs => $4)
+	`
+	result, err := r.db.ExecContext(ctx, query, key, requestHash, token, staleAfter.Seconds())
+	if err != nil {
+		return model.IdempotencyRecord{}, false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return model.IdempotencyRecord{}, false, err
+	}
+	if affected == 1 {
+		return model.IdempotencyRecord{Key: key, Token: token, RequestHash: requestHash}, true, nil
+	}
+
+	var record model.IdempotencyRecord
+	var statusCode sql.NullInt64
+	var body sql.NullString
+	query = `SELECT key, request_hash, status_code, response_body, created_at FROM idempotency_keys WHERE key = $1`
+	if err := r.db.QueryRowContext(ctx, query, key).Scan(&record.Key, &record.RequestHash, &statusCode, &body, &record.CreatedAt); err != nil {
+		return model.IdempotencyRecord{}, false, err
+	}
+	record.StatusCode = int(statusCode.Int64)
+	record.ResponseBody = []byte(body.String)
+	return record, false, nil
+}
+
+// Complete stores the response for the reservation holding token. It returns
+// ErrIdempotencyKeyTakenOver when another request has since taken the key.
+func (r *idempotencyRepository) Complete(ctx context.Context, key, token string, statusCode int, body []byte) error {
+	query := `UPDATE idempotency_keys SET status_code = $1, response_body = $2 WHERE key = $3 AND owner_token = $4 AND status_code IS NULL`
+	result, err := r.db.ExecContext(ctx, query, statusCode, string(body), key, token)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return model.ErrIdempotencyKeyTakenOver
+	}
+	return nil
+}
+
+// Release gives up the reservation holding token. A key another request has
+// since taken is left alone.
+func (r *idempotencyRepository) Release(ctx context.Context, key, token string) error {
+	query := `DELETE FROM idempotency_keys WHERE key = $1 AND owner_token = $2 AND status_code IS NULL`
+	_, err := r.db.ExecContext(ctx, query, key, token)
+	return err
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"kasir-api/internal/model"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyTakeoverLocksOutTheStaleRequest(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	repo := NewIdempotencyRepository(db)
+	key := "test-takeover"
+	db.Exec(`DELETE FROM idempotency_keys WHERE key = $1`, key)
+	t.Cleanup(func() { db.Exec(`DELETE FROM idempotency_keys WHERE key = $1`, key) })
+
+	if _, created, err := repo.Reserve(ctx, key, "hash", "first", time.Minute); err != nil || !created {
+		t.Fatalf("Expected the first request to reserve the key, got %v, %v", created, err)
+	}
+	// Treat the first reservation as stale straight away
+	time.Sleep(10 * time.Millisecond)
+	if _, created, err := repo.Reserve(ctx, key, "hash", "retry", 0); err != nil || !created {
+		t.Fatalf("Expected the retry to take over the key, got %v, %v", created, err)
+	}
+
+	if err := repo.Complete(ctx, key, "first", 201, []byte(`{}`)); !errors.Is(err, model.ErrIdempotencyKeyTakenOver) {
+		t.Errorf("Expected ErrIdempotencyKeyTakenOver for the stale request, got %v", err)
+	}
+	if err := repo.Release(ctx, key, "first"); err != nil {
+		t.Fatalf("failed to release: %v", err)
+	}
+	if err := repo.Complete(ctx, key, "retry", 201, []byte(`{"success":true}`)); err != nil {
+		t.Errorf("Expected the retry to complete the key, got %v", err)
+	}
+
+	record, created, err := repo.Reserve(ctx, key, "hash", "third", time.Minute)
+	if err != nil || created || record.StatusCode != 201 || string(record.ResponseBody) != `{"success":true}` {
+		t.Errorf("Expected the retry's response to be stored, got %+v, %v, %v", record, created, err)
+	}
+}
@@ -1,8 +1,13 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"kasir-api/internal/model"
+
+	"github.com/lib/pq"
 )
 
 type ProductRepository interface {
@@ -12,6 +17,9 @@ type ProductRepository interface {
 	Update(id int, product model.Product) (model.Product, error)
 	Delete(id int) error
 	SearchByName(name string) ([]model.Product, error)
+	GetByBarcode(code string) (model.BarcodeMatch, error)
+	GetLowStock() ([]model.Product, error)
+	IsComponent(id int) (bool, error)
 }
 
 type productRepository struct {
@@ -22,18 +30,230 @@ func NewProductRepository(db *sql.DB) ProductRepository {
 	return &productRepository{db: db}
 }
 
-func (r *productRepository) Create(product model.Product) (model.Product, error) {
-	query := `INSERT INTO products (name, price, stock, category_id) VALUES ($1, $2, $3, $4) RETURNING id`
-	err := r.db.QueryRow(query, product.Name, product.Price, product.Stock, product.CategoryID).Scan(&product.ID)
+// productColumns selects a product with its own barcodes and, as JSON
+// arrays, its unit conversions with their barcodes, its price tiers and its
+// components. A variant without a price override gets its parent's price,
+// the stock of a composite product is how many whole units its scarcest
+// component allows and its cost is that of its components.
+const productColumns = `id, name, COALESCE(price_override, (SELECT pp.price FROM products pp WHERE pp.id = products.parent_id), price), unit,
+	COALESCE((
+		SELECT MIN(FLOOR(c.stock / pc.quantity))
+		FROM product_components pc JOIN products c ON c.id = pc.component_id
+		WHERE pc.product_id = products.id
+	), stock), category_id, tax_class_id,
+	COALESCE((
+		SELECT ROUND(SUM(c.cost_price * pc.quantity), 2)
+		FROM product_components pc JOIN products c ON c.id = pc.component_id
+		WHERE pc.product_id = products.id
+	), cost_price), reorder_point, reorder_quantity, COALESCE(sku, ''),
+	ARRAY(SELECT b.code FROM product_barcodes b WHERE b.product_id = products.id AND b.conversion_id IS NULL ORDER BY b.code),
+	COALESCE((
+		SELECT json_agg(json_build_object('id', c.id, 'unit', c.unit, 'factor', c.factor, 'price', c.price, 'level_prices', c.level_prices,
+			'barcodes', ARRAY(SELECT b.code FROM product_barcodes b WHERE b.conversion_id = c.id ORDER BY b.code)) ORDER BY c.factor, c.id)
+		FROM product_unit_conversions c WHERE c.product_id = products.id
+	), '[]'),
+	COALESCE((
+		SELECT json_agg(json_build_object('id', t.id, 'level', t.level, 'min_quantity', t.min_quantity, 'price', t.price) ORDER BY t.level, t.min_quantity)
+		FROM product_price_tiers t WHERE t.product_id = products.id
+	), '[]'),
+	parent_id, COALESCE(variant_attributes, '{}'), COALESCE(attributes, '{}'), price_override,
+	COALESCE((
+		SELECT json_agg(json_build_object('product_id', pc.component_id, 'product_name', c.name, 'quantity', pc.quantity) ORDER BY pc.component_id)
+		FROM product_components pc JOIN products c ON c.id = pc.component_id
+		WHERE pc.product_id = products.id
+	), '[]')`
+
+func scanProduct(scanner interface{ Scan(...interface{}) error }) (model.Product, error) {
+	var p model.Product
+	var conversions, tiers, attributes, components []byte
+	err := scanner.Scan(&p.ID, &p.Name, &p.Price, &p.Unit, &p.Stock, &p.CategoryID, &p.TaxClassID, &p.CostPrice, &p.ReorderPoint, &p.ReorderQuantity, &p.SKU, pq.Array(&p.Barcodes), &conversions, &tiers,
+		&p.ParentID, pq.Array(&p.VariantAttributes), &attributes, &p.PriceOverride, &components)
 	if err != nil {
-		return model.Product{}, err
+		return p, err
 	}
-	return product, nil
+	if p.Barcodes == nil {
+		p.Barcodes = []string{}
+	}
+	if err := json.Unmarshal(conversions, &p.Conversions); err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(tiers, &p.PriceTiers); err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(attributes, &p.Attributes); err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(components, &p.Components); err != nil {
+		return p, err
+	}
+	return p, nil
 }
 
-func (r *productRepository) GetAll() ([]model.Product, error) {
-	query := `SELECT id, name, price, stock, category_id FROM products`
-	rows, err := r.db.Query(query)
+// replaceComponents makes components the product's complete bill of
+// materials.
+func replaceComponents(tx *sql.Tx, productID int, components []model.Component) error {
+	if _, err := tx.Exec(`DELETE FROM product_components WHERE product_id = $1`, productID); err != nil {
+		return err
+	}
+	for _, c := range components {
+		if _, err := tx.Exec(`INSERT INTO product_components (product_id, component_id, quantity) VALUES ($1, $2, $3)`, productID, c.ProductID, c.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// variantValues returns what is stored in the variant_attributes and
+// attributes columns for the product, NULL when it has none.
+func variantValues(product model.Product) (interface{}, interface{}, error) {
+	var variantAttributes, attributes interface{}
+	if len(product.VariantAttributes) > 0 {
+		variantAttributes = pq.Array(product.VariantAttributes)
+	}
+	if len(product.Attributes) > 0 {
+		encoded, err := json.Marshal(product.Attributes)
+		if err != nil {
+			return nil, nil, err
+		}
+		attributes = string(encoded)
+	}
+	return variantAttributes, attributes, nil
+}
+
+// nestVariants moves each variant under its parent, keeping the order of
+// products. Variants whose parent is not among products are left out.
+func nestVariants(products []model.Product) []model.Product {
+	parents := []model.Product{}
+	index := map[int]int{}
+	for _, p := range products {
+		if p.ParentID == nil {
+			index[p.ID] = len(parents)
+			parents = append(parents, p)
+		}
+	}
+	for _, p := range products {
+		if p.ParentID == nil {
+			continue
+		}
+		if i, ok := index[*p.ParentID]; ok {
+			parents[i].Variants = append(parents[i].Variants, p)
+		}
+	}
+	return parents
+}
+
+// replacePriceTiers makes the product's price tiers exactly tiers, keeping
+// the IDs of tiers that already existed so past sales still point at them,
+// and fills in each ID.
+func replacePriceTiers(tx *sql.Tx, productID int, tiers []model.PriceTier) error {
+	ids := []int64{}
+	query := `INSERT INTO product_price_tiers (product_id, level, min_quantity, price) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (product_id, level, min_quantity) DO UPDATE SET price = EXCLUDED.price
+		RETURNING id`
+	for i := range tiers {
+		t := &tiers[i]
+		if err := tx.QueryRow(query, productID, t.Level, t.MinQuantity, t.Price).Scan(&t.ID); err != nil {
+			return err
+		}
+		ids = append(ids, int64(t.ID))
+	}
+	_, err := tx.Exec(`DELETE FROM product_price_tiers WHERE product_id = $1 AND id <> ALL($2)`, productID, pq.Array(ids))
+	return err
+}
+
+// replaceConversions makes the product's conversions exactly conversions,
+// keeping the IDs of units that already existed, and fills in each ID.
+func replaceConversions(tx *sql.Tx, productID int, conversions []model.UnitConversion) error {
+	units := make([]string, len(conversions))
+	for i, c := range conversions {
+		units[i] = c.Unit
+	}
+	if _, err := tx.Exec(`DELETE FROM product_unit_conversions WHERE product_id = $1 AND unit <> ALL($2)`, productID, pq.Array(units)); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO product_unit_conversions (product_id, unit, factor, price, level_prices) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (product_id, unit) DO UPDATE SET factor = EXCLUDED.factor, price = EXCLUDED.price, level_prices = EXCLUDED.level_prices
+		RETURNING id`
+	for i := range conversions {
+		c := &conversions[i]
+		levelPrices := "{}"
+		if len(c.LevelPrices) > 0 {
+			encoded, err := json.Marshal(c.LevelPrices)
+			if err != nil {
+				return err
+			}
+			levelPrices = string(encoded)
+		}
+		if err := tx.QueryRow(query, productID, c.Unit, c.Factor, c.Price, levelPrices).Scan(&c.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceBarcodes makes the product's barcodes and those of its conversions
+// its complete set of barcodes. Conversion IDs must already be filled in.
+func replaceBarcodes(tx *sql.Tx, product model.Product) error {
+	if _, err := tx.Exec(`DELETE FROM product_barcodes WHERE product_id = $1`, product.ID); err != nil {
+		return err
+	}
+	query := `INSERT INTO product_barcodes (code, product_id, conversion_id) VALUES ($1, $2, NULLIF($3, 0))`
+	for _, code := range product.Barcodes {
+		if _, err := tx.Exec(query, code, product.ID, 0); err != nil {
+			return productConflict(err)
+		}
+	}
+	for _, c := range product.Conversions {
+		for _, code := range c.Barcodes {
+			if _, err := tx.Exec(query, code, product.ID, c.ID); err != nil {
+				return productConflict(err)
+			}
+		}
+	}
+	return nil
+}
+
+// saveRelated stores the product's conversions, price tiers, components and
+// all of its barcodes.
+func saveRelated(tx *sql.Tx, product *model.Product) error {
+	if product.Conversions == nil {
+		product.Conversions = []model.UnitConversion{}
+	}
+	if product.PriceTiers == nil {
+		product.PriceTiers = []model.PriceTier{}
+	}
+	if err := replaceConversions(tx, product.ID, product.Conversions); err != nil {
+		return err
+	}
+	if err := replacePriceTiers(tx, product.ID, product.PriceTiers); err != nil {
+		return err
+	}
+	if err := replaceComponents(tx, product.ID, product.Components); err != nil {
+		return err
+	}
+	return replaceBarcodes(tx, *product)
+}
+
+// productConflict turns a unique violation on the SKU, a barcode or a
+// variant's attributes into the matching model error.
+func productConflict(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		switch pqErr.Constraint {
+		case "idx_products_sku":
+			return model.ErrDuplicateSKU
+		case "product_barcodes_pkey":
+			return model.ErrDuplicateBarcode
+		case "idx_products_variant_attributes":
+			return model.ErrDuplicateVariant
+		}
+	}
+	return err
+}
+
+func (r *productRepository) queryProducts(query string, args ...interface{}) ([]model.Product, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -41,56 +261,195 @@ func (r *productRepository) GetAll() ([]model.Product, error) {
 
 	var products []model.Product
 	for rows.Next() {
-		var p model.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.CategoryID); err != nil {
+		p, err := scanProduct(rows)
+		if err != nil {
 			return nil, err
 		}
 		products = append(products, p)
 	}
-	return products, nil
+	return products, rows.Err()
 }
 
+// Create inserts the product and records its starting stock in the ledger.
+func (r *productRepository) Create(product model.Product) (model.Product, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.Product{}, err
+	}
+	defer tx.Rollback()
+
+	variantAttributes, attributes, err := variantValues(product)
+	if err != nil {
+		return model.Product{}, err
+	}
+	query := `INSERT INTO products (name, price, stock, category_id, tax_class_id, cost_price, reorder_point, reorder_quantity, sku, unit,
+			parent_id, variant_attributes, attributes, price_override)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12, $13, $14) RETURNING id`
+	err = tx.QueryRow(query, product.Name, product.Price, product.Stock, product.CategoryID, product.TaxClassID, product.CostPrice, product.ReorderPoint, product.ReorderQuantity, product.SKU, product.Unit,
+		product.ParentID, variantAttributes, attributes, product.PriceOverride).Scan(&product.ID)
+	if err != nil {
+		return model.Product{}, productConflict(err)
+	}
+	if err := saveRelated(tx, &product); err != nil {
+		return model.Product{}, err
+	}
+	if product.Barcodes == nil {
+		product.Barcodes = []string{}
+	}
+
+	if product.Stock != 0 {
+		opening := model.StockMovement{ProductID: product.ID, Type: model.StockMovementAdjustment, Quantity: product.Stock, Note: "opening stock"}
+		if err := insertStockMovement(context.Background(), tx, &opening); err != nil {
+			return model.Product{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.Product{}, err
+	}
+	return product, nil
+}
+
+// GetAll returns every product with its variants nested under it.
+func (r *productRepository) GetAll() ([]model.Product, error) {
+	products, err := r.queryProducts(`SELECT ` + productColumns + ` FROM products ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	return nestVariants(products), nil
+}
+
+// GetByID returns the product, with its variants when it is a parent.
 func (r *productRepository) GetByID(id int) (model.Product, error) {
-	query := `SELECT id, name, price, stock, category_id FROM products WHERE id = $1`
-	var p model.Product
-	err := r.db.QueryRow(query, id).Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.CategoryID)
+	query := `SELECT ` + productColumns + ` FROM products WHERE id = $1`
+	p, err := scanProduct(r.db.QueryRow(query, id))
 	if err != nil {
 		return model.Product{}, err
 	}
+	if p.ParentID == nil {
+		p.Variants, err = r.queryProducts(`SELECT `+productColumns+` FROM products WHERE parent_id = $1 ORDER BY id`, id)
+		if err != nil {
+			return model.Product{}, err
+		}
+	}
 	return p, nil
 }
 
+// Update saves the product. A changed stock figure is recorded in the ledger
+// as an adjustment.
 func (r *productRepository) Update(id int, product model.Product) (model.Product, error) {
-	query := `UPDATE products SET name = $1, price = $2, stock = $3, category_id = $4 WHERE id = $5 RETURNING id, name, price, stock, category_id`
-	var updatedProduct model.Product
-	err := r.db.QueryRow(query, product.Name, product.Price, product.Stock, product.CategoryID, id).Scan(&updatedProduct.ID, &updatedProduct.Name, &updatedProduct.Price, &updatedProduct.Stock, &updatedProduct.CategoryID)
+	tx, err := r.db.Begin()
 	if err != nil {
 		return model.Product{}, err
 	}
-	return updatedProduct, nil
+	defer tx.Rollback()
+
+	var currentStock model.Quantity
+	if err := tx.QueryRow(`SELECT stock FROM products WHERE id = $1 FOR UPDATE`, id).Scan(&currentStock); err != nil {
+		return model.Product{}, err
+	}
+
+	product.ID = id
+	if err := saveRelated(tx, &product); err != nil {
+		return model.Product{}, err
+	}
+
+	variantAttributes, attributes, err := variantValues(product)
+	if err != nil {
+		return model.Product{}, err
+	}
+	query := `UPDATE products SET name = $1, price = $2, stock = $3, category_id = $4, tax_class_id = $5, cost_price = $6, reorder_point = $7, reorder_quantity = $8, sku = NULLIF($9, ''), unit = $10,
+			parent_id = $11, variant_attributes = $12, attributes = $13, price_override = $14
+		WHERE id = $15`
+	_, err = tx.Exec(query, product.Name, product.Price, product.Stock, product.CategoryID, product.TaxClassID, product.CostPrice, product.ReorderPoint, product.ReorderQuantity, product.SKU, product.Unit,
+		product.ParentID, variantAttributes, attributes, product.PriceOverride, id)
+	if err != nil {
+		return model.Product{}, productConflict(err)
+	}
+
+	if delta := product.Stock - currentStock; delta != 0 {
+		adjustment := model.StockMovement{ProductID: id, Type: model.StockMovementAdjustment, Quantity: delta, Note: "product update"}
+		if err := insertStockMovement(context.Background(), tx, &adjustment); err != nil {
+			return model.Product{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.Product{}, err
+	}
+	return r.GetByID(id)
 }
 
+// Delete removes the product. A product that is still a component of a
+// composite product cannot be deleted.
 func (r *productRepository) Delete(id int) error {
 	query := `DELETE FROM products WHERE id = $1`
 	_, err := r.db.Exec(query, id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		switch pqErr.Constraint {
+		case "product_components_component_id_fkey":
+			return model.ErrComponentInUse
+		case "products_parent_id_fkey":
+			return model.ErrProductHasVariants
+		}
+	}
 	return err
 }
 
+// SearchByName finds products whose name matches, and the parents of
+// variants whose name, SKU or attribute values match, each with all of its
+// variants.
 func (r *productRepository) SearchByName(name string) ([]model.Product, error) {
-	query := `SELECT id, name, price, stock, category_id FROM products WHERE name ILIKE '%' || $1 || '%'`
-	rows, err := r.db.Query(query, name)
+	query := `
+		WITH matched AS (
+			SELECT COALESCE(parent_id, id) AS id FROM products
+			WHERE name ILIKE '%' || $1 || '%'
+				OR (parent_id IS NOT NULL AND sku ILIKE '%' || $1 || '%')
+				OR EXISTS (SELECT 1 FROM jsonb_each_text(attributes) a WHERE a.value ILIKE '%' || $1 || '%')
+		)
+		SELECT ` + productColumns + ` FROM products
+		WHERE id IN (SELECT id FROM matched) OR parent_id IN (SELECT id FROM matched)
+		ORDER BY id
+	`
+	products, err := r.queryProducts(query, name)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return nestVariants(products), nil
+}
 
-	var products []model.Product
-	for rows.Next() {
-		var p model.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.CategoryID); err != nil {
-			return nil, err
+// GetByBarcode finds the product, and the pack if any, with the given
+// barcode, which must already be normalized.
+func (r *productRepository) GetByBarcode(code string) (model.BarcodeMatch, error) {
+	var productID, conversionID int
+	query := `SELECT product_id, COALESCE(conversion_id, 0) FROM product_barcodes WHERE code = $1`
+	if err := r.db.QueryRow(query, code).Scan(&productID, &conversionID); err != nil {
+		return model.BarcodeMatch{}, err
+	}
+	p, err := r.GetByID(productID)
+	if err != nil {
+		return model.BarcodeMatch{}, err
+	}
+
+	match := model.BarcodeMatch{Product: p}
+	for i := range p.Conversions {
+		if p.Conversions[i].ID == conversionID {
+			match.Conversion = &p.Conversions[i]
 		}
-		products = append(products, p)
 	}
-	return products, nil
+	return match, nil
+}
+
+// GetLowStock returns the products at or below their reorder point, the most
+// urgent first. Products without a reorder point are never low.
+func (r *productRepository) GetLowStock() ([]model.Product, error) {
+	return r.queryProducts(`SELECT ` + productColumns + ` FROM products WHERE reorder_point > 0 AND stock <= reorder_point ORDER BY stock - reorder_point, name`)
+}
+
+// IsComponent reports whether the product goes into any composite product.
+func (r *productRepository) IsComponent(id int) (bool, error) {
+	var used bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM product_components WHERE component_id = $1)`, id).Scan(&used)
+	return used, err
 }
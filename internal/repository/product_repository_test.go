@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"errors"
+	"kasir-api/internal/model"
+	"testing"
+)
+
+func TestDeleteProductWithVariants(t *testing.T) {
+	db := openTestDB(t)
+	categoryID, parentID := createTestProduct(t, db, "kaos polos", 0)
+
+	var variantID int
+	query := `INSERT INTO products (name, price, stock, category_id, parent_id, attributes) VALUES ('kaos polos merah', 1000, 5, $1, $2, '{"warna": "merah"}') RETURNING id`
+	if err := db.QueryRow(query, categoryID, parentID).Scan(&variantID); err != nil {
+		t.Fatalf("failed to create variant: %v", err)
+	}
+	repo := NewProductRepository(db)
+
+	if err := repo.Delete(parentID); !errors.Is(err, model.ErrProductHasVariants) {
+		t.Fatalf("Expected ErrProductHasVariants, got %v", err)
+	}
+	if stock := readStock(t, db, variantID); stock != model.WholeQuantity(5) {
+		t.Errorf("Expected the variant to be kept with stock 5, got %v", stock)
+	}
+
+	if err := repo.Delete(variantID); err != nil {
+		t.Fatalf("failed to delete variant: %v", err)
+	}
+	if err := repo.Delete(parentID); err != nil {
+		t.Errorf("Expected the parent to delete once its variants are gone, got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"database/sql"
+	"kasir-api/internal/model"
+	"time"
+)
+
+type PromotionRepository interface {
+	Create(promotion model.Promotion) (model.Promotion, error)
+	GetAll() ([]model.Promotion, error)
+	GetByID(id int) (model.Promotion, error)
+	Update(id int, promotion model.Promotion) (model.Promotion, error)
+	Delete(id int) error
+	GetActive(now time.Time) ([]model.Promotion, error)
+}
+
+type promotionRepository struct {
+	db *sql.DB
+}
+
+func NewPromotionRepository(db *sql.DB) PromotionRepository {
+	return &promotionRepository{db: db}
+}
+
+const promotionColumns = `id, name, type, percent, amount, buy_quantity, get_quantity, bundle_price, min_spend, starts_at, ends_at, COALESCE(daily_start, ''), COALESCE(daily_end, ''), active`
+
+func scanPromotion(scanner interface{ Scan(...interface{}) error }) (model.Promotion, error) {
+	var p model.Promotion
+	var startsAt, endsAt sql.NullTime
+	err := scanner.Scan(&p.ID, &p.Name, &p.Type, &p.Percent, &p.Amount, &p.BuyQuantity, &p.GetQuantity, &p.BundlePrice, &p.MinSpend, &startsAt, &endsAt, &p.DailyStart, &p.DailyEnd, &p.Active)
+	if err != nil {
+		return model.Promotion{}, err
+	}
+	if startsAt.Valid {
+		p.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		p.EndsAt = &endsAt.Time
+	}
+	return p, nil
+}
+
+func (r *promotionRepository) Create(promotion model.Promotion) (model.Promotion, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.Promotion{}, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO promotions (name, type, percent, amount, buy_quantity, get_quantity, bundle_price, min_spend, starts_at, ends_at, daily_start, daily_end, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), NULLIF($12, ''), $13) RETURNING id`
+	err = tx.QueryRow(query, promotion.Name, promotion.Type, promotion.Percent, promotion.Amount, promotion.BuyQuantity, promotion.GetQuantity,
+		promotion.BundlePrice, promotion.MinSpend, promotion.StartsAt, promotion.EndsAt, promotion.DailyStart, promotion.DailyEnd, promotion.Active).Scan(&promotion.ID)
+	if err != nil {
+		return model.Promotion{}, err
+	}
+
+	if err := insertPromotionProducts(tx, promotion.ID, promotion.ProductIDs); err != nil {
+		return model.Promotion{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Promotion{}, err
+	}
+	return promotion, nil
+}
+
+func (r *promotionRepository) GetAll() ([]model.Promotion, error) {
+	return r.query(`SELECT ` + promotionColumns + ` FROM promotions ORDER BY id`)
+}
+
+func (r *promotionRepository) GetByID(id int) (model.Promotion, error) {
+	query := `SELECT ` + promotionColumns + ` FROM promotions WHERE id = $1`
+	p, err := scanPromotion(r.db.QueryRow(query, id))
+	if err != nil {
+		return model.Promotion{}, err
+	}
+
+	products, err := r.productIDs()
+	if err != nil {
+		return model.Promotion{}, err
+	}
+	p.ProductIDs = products[p.ID]
+	return p, nil
+}
+
+func (r *promotionRepository) Update(id int, promotion model.Promotion) (model.Promotion, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.Promotion{}, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE promotions SET name = $1, type = $2, percent = $3, amount = $4, buy_quantity = $5, get_quantity = $6, bundle_price = $7,
+		min_spend = $8, starts_at = $9, ends_at = $10, daily_start = NULLIF($11, ''), daily_end = NULLIF($12, ''), active = $13
+		WHERE id = $14 RETURNING ` + promotionColumns
+	updated, err := scanPromotion(tx.QueryRow(query, promotion.Name, promotion.Type, promotion.Percent, promotion.Amount, promotion.BuyQuantity,
+		promotion.GetQuantity, promotion.BundlePrice, promotion.MinSpend, promotion.StartsAt, promotion.EndsAt, promotion.DailyStart,
+		promotion.DailyEnd, promotion.Active, id))
+	if err != nil {
+		return model.Promotion{}, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM promotion_products WHERE promotion_id = $1`, id); err != nil {
+		return model.Promotion{}, err
+	}
+	if err := insertPromotionProducts(tx, id, promotion.ProductIDs); err != nil {
+		return model.Promotion{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Promotion{}, err
+	}
+
+	updated.ProductIDs = promotion.ProductIDs
+	return updated, nil
+}
+
+func (r *promotionRepository) Delete(id int) error {
+	query := `DELETE FROM promotions WHERE id = $1`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// GetActive returns enabled promotions whose date range covers now. Daily
+// time windows are left to the caller.
+func (r *promotionRepository) GetActive(now time.Time) ([]model.Promotion, error) {
+	query := `SELECT ` + promotionColumns + ` FROM promotions
+		WHERE active AND (starts_at IS NULL OR starts_at <= $1) AND (ends_at IS NULL OR ends_at > $1)
+		ORDER BY id`
+	return r.query(query, now)
+}
+
+func (r *promotionRepository) query(query string, args ...interface{}) ([]model.Promotion, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promotions []model.Promotion
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	products, err := r.productIDs()
+	if err != nil {
+		return nil, err
+	}
+	for i := range promotions {
+		promotions[i].ProductIDs = products[promotions[i].ID]
+	}
+	return promotions, nil
+}
+
+// productIDs loads the product list of every promotion keyed by promotion ID.
+func (r *promotionRepository) productIDs() (map[int][]int, error) {
+	rows, err := r.db.Query(`SELECT promotion_id, product_id FROM promotion_products ORDER BY promotion_id, product_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := map[int][]int{}
+	for rows.Next() {
+		var promotionID, productID int
+		if err := rows.Scan(&promotionID, &productID); err != nil {
+			return nil, err
+		}
+		products[promotionID] = append(products[promotionID], productID)
+	}
+	return products, rows.Err()
+}
+
+func insertPromotionProducts(tx *sql.Tx, promotionID int, productIDs []int) error {
+	query := `INSERT INTO promotion_products (promotion_id, product_id) VALUES ($1, $2)`
+	for _, productID := range productIDs {
+		if _, err := tx.Exec(query, promotionID, productID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
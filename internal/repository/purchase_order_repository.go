@@ -0,0 +1,303 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/internal/model"
+)
+
+type PurchaseOrderRepository interface {
+	Create(ctx context.Context, order model.PurchaseOrder) (model.PurchaseOrder, error)
+	GetAll(ctx context.Context, status string, supplierID int) ([]model.PurchaseOrder, error)
+	GetByID(ctx context.Context, id int) (model.PurchaseOrder, error)
+	Receive(ctx context.Context, id int, request model.GoodsReceiptRequest) (model.GoodsReceipt, error)
+	Cancel(ctx context.Context, id int) error
+	Close(ctx context.Context, id int) error
+}
+
+type purchaseOrderRepository struct {
+	db *sql.DB
+}
+
+func NewPurchaseOrderRepository(db *sql.DB) PurchaseOrderRepository {
+	return &purchaseOrderRepository{db: db}
+}
+
+const purchaseOrderColumns = `po.id, po.supplier_id, COALESCE(s.name, ''), po.status, COALESCE(po.note, ''), po.total_amount, po.created_at`
+
+func scanPurchaseOrder(scanner interface{ Scan(...interface{}) error }) (model.PurchaseOrder, error) {
+	var o model.PurchaseOrder
+	err := scanner.Scan(&o.ID, &o.SupplierID, &o.SupplierName, &o.Status, &o.Note, &o.TotalAmount, &o.CreatedAt)
+	return o, err
+}
+
+func (r *purchaseOrderRepository) Create(ctx context.Context, order model.PurchaseOrder) (model.PurchaseOrder, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.PurchaseOrder{}, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO purchase_orders (supplier_id, note, total_amount) VALUES ($1, NULLIF($2, ''), $3) RETURNING id, status, created_at`
+	if err := tx.QueryRowContext(ctx, query, order.SupplierID, order.Note, order.TotalAmount).Scan(&order.ID, &order.Status, &order.CreatedAt); err != nil {
+		return model.PurchaseOrder{}, fmt.Errorf("failed to insert purchase order: %w", err)
+	}
+
+	itemQuery := `INSERT INTO purchase_order_items (purchase_order_id, product_id, product_name, quantity, unit_cost)
+		VALUES ($1, $2, (SELECT name FROM products WHERE id = $2), $3, $4) RETURNING id, COALESCE(product_name, '')`
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.PurchaseOrderID = order.ID
+		if err := tx.QueryRowContext(ctx, itemQuery, order.ID, item.ProductID, item.Quantity, item.UnitCost).Scan(&item.ID, &item.ProductName); err != nil {
+			return model.PurchaseOrder{}, fmt.Errorf("failed to insert purchase order item: %w", err)
+		}
+		item.OutstandingQuantity = item.Quantity
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.PurchaseOrder{}, err
+	}
+	return order, nil
+}
+
+func (r *purchaseOrderRepository) GetAll(ctx context.Context, status string, supplierID int) ([]model.PurchaseOrder, error) {
+	query := `SELECT ` + purchaseOrderColumns + ` FROM purchase_orders po
+		LEFT JOIN suppliers s ON s.id = po.supplier_id
+		WHERE ($1 = '' OR po.status = $1) AND ($2 = 0 OR po.supplier_id = $2)
+		ORDER BY po.created_at DESC, po.id DESC`
+	rows, err := r.db.QueryContext(ctx, query, status, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []model.PurchaseOrder{}
+	for rows.Next() {
+		o, err := scanPurchaseOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+func (r *purchaseOrderRepository) GetByID(ctx context.Context, id int) (model.PurchaseOrder, error) {
+	query := `SELECT ` + purchaseOrderColumns + ` FROM purchase_orders po LEFT JOIN suppliers s ON s.id = po.supplier_id WHERE po.id = $1`
+	order, err := scanPurchaseOrder(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return model.PurchaseOrder{}, err
+	}
+
+	itemsQuery := `SELECT id, purchase_order_id, COALESCE(product_id, 0), COALESCE(product_name, ''), quantity, unit_cost, received_quantity
+		FROM purchase_order_items WHERE purchase_order_id = $1 ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, itemsQuery, id)
+	if err != nil {
+		return model.PurchaseOrder{}, err
+	}
+	for rows.Next() {
+		var item model.PurchaseOrderItem
+		if err := rows.Scan(&item.ID, &item.PurchaseOrderID, &item.ProductID, &item.ProductName, &item.Quantity, &item.UnitCost, &item.ReceivedQuantity); err != nil {
+			rows.Close()
+			return model.PurchaseOrder{}, err
+		}
+		if order.Status != model.PurchaseOrderStatusCancelled && order.Status != model.PurchaseOrderStatusClosed {
+			item.OutstandingQuantity = max(item.Quantity-item.ReceivedQuantity, 0)
+		}
+		order.Items = append(order.Items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return model.PurchaseOrder{}, err
+	}
+
+	order.Receipts, err = r.getReceipts(ctx, id)
+	if err != nil {
+		return model.PurchaseOrder{}, err
+	}
+	return order, nil
+}
+
+func (r *purchaseOrderRepository) getReceipts(ctx context.Context, orderID int) ([]model.GoodsReceipt, error) {
+	query := `SELECT id, purchase_order_id, supplier_id, COALESCE(note, ''), total_amount, received_at FROM goods_receipts WHERE purchase_order_id = $1 ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	var receipts []model.GoodsReceipt
+	index := map[int]int{}
+	for rows.Next() {
+		var gr model.GoodsReceipt
+		if err := rows.Scan(&gr.ID, &gr.PurchaseOrderID, &gr.SupplierID, &gr.Note, &gr.TotalAmount, &gr.ReceivedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[gr.ID] = len(receipts)
+		receipts = append(receipts, gr)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	itemsQuery := `
+		SELECT gi.id, gi.goods_receipt_id, gi.purchase_order_item_id, COALESCE(gi.product_id, 0), COALESCE(gi.product_name, ''), gi.quantity, gi.unit_cost, gi.amount
+		FROM goods_receipt_items gi
+		JOIN goods_receipts gr ON gr.id = gi.goods_receipt_id
+		WHERE gr.purchase_order_id = $1
+		ORDER BY gi.id
+	`
+	rows, err = r.db.QueryContext(ctx, itemsQuery, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item model.GoodsReceiptItem
+		if err := rows.Scan(&item.ID, &item.GoodsReceiptID, &item.PurchaseOrderItemID, &item.ProductID, &item.ProductName, &item.Quantity, &item.UnitCost, &item.Amount); err != nil {
+			return nil, err
+		}
+		gr := &receipts[index[item.GoodsReceiptID]]
+		gr.Items = append(gr.Items, item)
+	}
+	return receipts, rows.Err()
+}
+
+// Receive books a delivery against the order. Each received line adds stock
+// through the ledger, moves the product's cost to the weighted average of the
+// stock on hand and the new units, and reduces the outstanding quantity. The
+// receipt's value is added to the supplier's payable.
+func (r *purchaseOrderRepository) Receive(ctx context.Context, id int, request model.GoodsReceiptRequest) (model.GoodsReceipt, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.GoodsReceipt{}, err
+	}
+	defer tx.Rollback()
+
+	var supplierID int
+	var status string
+	if err := tx.QueryRowContext(ctx, `SELECT supplier_id, status FROM purchase_orders WHERE id = $1 FOR UPDATE`, id).Scan(&supplierID, &status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.GoodsReceipt{}, fmt.Errorf("purchase order not found: %d", id)
+		}
+		return model.GoodsReceipt{}, err
+	}
+	if status != model.PurchaseOrderStatusOpen && status != model.PurchaseOrderStatusPartiallyReceived {
+		return model.GoodsReceipt{}, fmt.Errorf("purchase order %d is %s", id, status)
+	}
+
+	receipt := model.GoodsReceipt{PurchaseOrderID: id, SupplierID: supplierID, Note: request.Note}
+	query := `INSERT INTO goods_receipts (purchase_order_id, supplier_id, note) VALUES ($1, $2, NULLIF($3, '')) RETURNING id, received_at`
+	if err := tx.QueryRowContext(ctx, query, id, supplierID, request.Note).Scan(&receipt.ID, &receipt.ReceivedAt); err != nil {
+		return model.GoodsReceipt{}, fmt.Errorf("failed to insert goods receipt: %w", err)
+	}
+
+	lineQuery := `SELECT COALESCE(product_id, 0), quantity, received_quantity, unit_cost FROM purchase_order_items WHERE id = $1 AND purchase_order_id = $2 FOR UPDATE`
+	costQuery := `UPDATE products SET cost_price = CASE
+			WHEN stock > 0 THEN ROUND((stock * cost_price + $1 * $2::numeric) / (stock + $1), 2)
+			ELSE $2::numeric
+		END
+		WHERE id = $3`
+	itemQuery := `INSERT INTO goods_receipt_items (goods_receipt_id, purchase_order_item_id, product_id, product_name, quantity, unit_cost, amount)
+		VALUES ($1, $2, $3, (SELECT name FROM products WHERE id = $3), $4, $5, $6) RETURNING id, COALESCE(product_name, '')`
+	for _, requested := range request.Items {
+		item := model.GoodsReceiptItem{GoodsReceiptID: receipt.ID, PurchaseOrderItemID: requested.PurchaseOrderItemID, Quantity: requested.Quantity}
+		var ordered, received model.Quantity
+		if err := tx.QueryRowContext(ctx, lineQuery, requested.PurchaseOrderItemID, id).Scan(&item.ProductID, &ordered, &received, &item.UnitCost); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.GoodsReceipt{}, fmt.Errorf("purchase order item %d is not on purchase order %d", requested.PurchaseOrderItemID, id)
+			}
+			return model.GoodsReceipt{}, err
+		}
+		if item.ProductID == 0 {
+			return model.GoodsReceipt{}, fmt.Errorf("the product on purchase order item %d has been deleted", requested.PurchaseOrderItemID)
+		}
+		if outstanding := ordered - received; requested.Quantity > outstanding {
+			return model.GoodsReceipt{}, fmt.Errorf("purchase order item %d has only %s outstanding", requested.PurchaseOrderItemID, outstanding)
+		}
+		if requested.UnitCost != nil {
+			item.UnitCost = *requested.UnitCost
+		}
+		item.Amount = item.UnitCost.MulQuantity(item.Quantity)
+
+		// Cost is averaged against the stock on hand before the delivery
+		if _, err := tx.ExecContext(ctx, costQuery, item.Quantity, item.UnitCost, item.ProductID); err != nil {
+			return model.GoodsReceipt{}, fmt.Errorf("failed to update product cost: %w", err)
+		}
+		purchase := model.StockMovement{
+			ProductID:     item.ProductID,
+			Type:          model.StockMovementPurchase,
+			Quantity:      item.Quantity,
+			ReferenceType: model.StockReferenceGoodsReceipt,
+			ReferenceID:   receipt.ID,
+		}
+		if err := applyStockMovement(ctx, tx, &purchase); err != nil {
+			return model.GoodsReceipt{}, err
+		}
+
+		if err := tx.QueryRowContext(ctx, itemQuery, receipt.ID, item.PurchaseOrderItemID, item.ProductID, item.Quantity, item.UnitCost, item.Amount).Scan(&item.ID, &item.ProductName); err != nil {
+			return model.GoodsReceipt{}, fmt.Errorf("failed to insert goods receipt item: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE purchase_order_items SET received_quantity = received_quantity + $1 WHERE id = $2`, item.Quantity, item.PurchaseOrderItemID); err != nil {
+			return model.GoodsReceipt{}, fmt.Errorf("failed to update purchase order item: %w", err)
+		}
+
+		receipt.TotalAmount += item.Amount
+		receipt.Items = append(receipt.Items, item)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE goods_receipts SET total_amount = $1 WHERE id = $2`, receipt.TotalAmount, receipt.ID); err != nil {
+		return model.GoodsReceipt{}, fmt.Errorf("failed to update goods receipt: %w", err)
+	}
+
+	statusQuery := `
+		UPDATE purchase_orders SET status = CASE
+			WHEN (SELECT bool_and(received_quantity >= quantity) FROM purchase_order_items WHERE purchase_order_id = $1) THEN $2
+			ELSE $3
+		END
+		WHERE id = $1
+	`
+	if _, err := tx.ExecContext(ctx, statusQuery, id, model.PurchaseOrderStatusReceived, model.PurchaseOrderStatusPartiallyReceived); err != nil {
+		return model.GoodsReceipt{}, fmt.Errorf("failed to update purchase order status: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.GoodsReceipt{}, fmt.Errorf("failed to commit goods receipt: %w", err)
+	}
+	return receipt, nil
+}
+
+// Cancel closes an order that has not been received at all. Partially
+// received orders keep their receipts and cannot be cancelled; Close ends
+// them instead.
+func (r *purchaseOrderRepository) Cancel(ctx context.Context, id int) error {
+	query := `UPDATE purchase_orders SET status = $1 WHERE id = $2 AND status = $3`
+	result, err := r.db.ExecContext(ctx, query, model.PurchaseOrderStatusCancelled, id, model.PurchaseOrderStatusOpen)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return fmt.Errorf("purchase order %d is not open", id)
+	}
+	return nil
+}
+
+// Close short-closes a partially received order, giving up on what the
+// supplier has not delivered. The receipts already booked are kept.
+func (r *purchaseOrderRepository) Close(ctx context.Context, id int) error {
+	query := `UPDATE purchase_orders SET status = $1 WHERE id = $2 AND status = $3`
+	result, err := r.db.ExecContext(ctx, query, model.PurchaseOrderStatusClosed, id, model.PurchaseOrderStatusPartiallyReceived)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return fmt.Errorf("purchase order %d is not partially received", id)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"kasir-api/internal/model"
+	"testing"
+)
+
+func TestReceivePurchaseOrder(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	_, productID := createTestProduct(t, db, "gula curah", 10)
+	if _, err := db.Exec(`UPDATE products SET cost_price = 400 WHERE id = $1`, productID); err != nil {
+		t.Fatalf("failed to set cost: %v", err)
+	}
+
+	suppliers := NewSupplierRepository(db)
+	supplier, err := suppliers.Create(model.Supplier{Name: "receiving test"})
+	if err != nil {
+		t.Fatalf("failed to create supplier: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM supplier_payments WHERE supplier_id = $1`, supplier.ID)
+		db.Exec(`DELETE FROM goods_receipts WHERE supplier_id = $1`, supplier.ID)
+		db.Exec(`DELETE FROM purchase_orders WHERE supplier_id = $1`, supplier.ID)
+		db.Exec(`DELETE FROM suppliers WHERE id = $1`, supplier.ID)
+	})
+
+	repo := NewPurchaseOrderRepository(db)
+	order, err := repo.Create(ctx, model.PurchaseOrder{SupplierID: supplier.ID, TotalAmount: 600000, Items: []model.PurchaseOrderItem{
+		{ProductID: productID, Quantity: model.WholeQuantity(12), UnitCost: 50000},
+	}})
+	if err != nil {
+		t.Fatalf("failed to create purchase order: %v", err)
+	}
+	lineID := order.Items[0].ID
+
+	receive := func(units int) (model.GoodsReceipt, error) {
+		return repo.Receive(ctx, order.ID, model.GoodsReceiptRequest{Items: []model.GoodsReceiptRequestItem{
+			{PurchaseOrderItemID: lineID, Quantity: model.WholeQuantity(units)},
+		}})
+	}
+
+	receipt, err := receive(5)
+	if err != nil {
+		t.Fatalf("failed to receive: %v", err)
+	}
+	if receipt.TotalAmount != 250000 {
+		t.Errorf("Expected a receipt of 2500.00, got %s", receipt.TotalAmount)
+	}
+	if stock := readStock(t, db, productID); stock != model.WholeQuantity(15) {
+		t.Errorf("Expected stock 15, got %v", stock)
+	}
+	// (10 x 400.00 + 5 x 500.00) / 15
+	var cost model.Money
+	if err := db.QueryRow(`SELECT cost_price FROM products WHERE id = $1`, productID).Scan(&cost); err != nil {
+		t.Fatalf("failed to read cost: %v", err)
+	}
+	if cost != 43333 {
+		t.Errorf("Expected an average cost of 433.33, got %s", cost)
+	}
+
+	want := fmt.Sprintf("purchase order item %d has only 7 outstanding", lineID)
+	if _, err := receive(8); err == nil || err.Error() != want {
+		t.Errorf("Expected %q, got %v", want, err)
+	}
+	if stock := readStock(t, db, productID); stock != model.WholeQuantity(15) {
+		t.Errorf("Expected a refused delivery to leave stock at 15, got %v", stock)
+	}
+
+	// Only what was delivered is owed, and it cannot be overpaid
+	want = "payment exceeds the outstanding payable of 2500.00"
+	if _, err := suppliers.AddPayment(ctx, model.SupplierPayment{SupplierID: supplier.ID, Amount: 250001, Method: model.PaymentMethodCash}); err == nil || err.Error() != want {
+		t.Errorf("Expected %q, got %v", want, err)
+	}
+	if _, err := suppliers.AddPayment(ctx, model.SupplierPayment{SupplierID: supplier.ID, Amount: 250000, Method: model.PaymentMethodCash}); err != nil {
+		t.Errorf("Expected paying the full payable to succeed, got %v", err)
+	}
+
+	// Short-closing ends the order and no more can be received on it
+	if err := repo.Close(ctx, order.ID); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	closed, err := repo.GetByID(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("failed to read purchase order: %v", err)
+	}
+	if closed.Status != model.PurchaseOrderStatusClosed || closed.Items[0].ReceivedQuantity != model.WholeQuantity(5) || closed.Items[0].OutstandingQuantity != 0 {
+		t.Errorf("Unexpected closed order: %+v", closed)
+	}
+	want = fmt.Sprintf("purchase order %d is %s", order.ID, model.PurchaseOrderStatusClosed)
+	if _, err := receive(1); err == nil || err.Error() != want {
+		t.Errorf("Expected %q, got %v", want, err)
+	}
+}
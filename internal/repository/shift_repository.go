@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/internal/model"
+)
+
+type ShiftRepository interface {
+	Open(ctx context.Context, shift model.Shift) (model.Shift, error)
+	GetAll(ctx context.Context, status string) ([]model.Shift, error)
+	GetByID(ctx context.Context, id int) (model.Shift, error)
+	AddCashMovement(ctx context.Context, movement model.CashMovement) (model.CashMovement, error)
+	Close(ctx context.Context, id int, denominations []model.DenominationCount, counted model.Money, note string) (model.ShiftReport, error)
+	GetReport(ctx context.Context, id int) (model.ShiftReport, error)
+}
+
+type shiftRepository struct {
+	db *sql.DB
+}
+
+func NewShiftRepository(db *sql.DB) ShiftRepository {
+	return &shiftRepository{db: db}
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx so report queries can run
+// inside the closing transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+const shiftColumns = `id, cashier_name, opening_float, status, opened_at, closed_at, expected_cash, counted_cash, variance, COALESCE(note, '')`
+
+func scanShift(scanner interface{ Scan(...interface{}) error }) (model.Shift, error) {
+	var s model.Shift
+	var closedAt sql.NullTime
+	err := scanner.Scan(&s.ID, &s.CashierName, &s.OpeningFloat, &s.Status, &s.OpenedAt, &closedAt, &s.ExpectedCash, &s.CountedCash, &s.Variance, &s.Note)
+	if err != nil {
+		return model.Shift{}, err
+	}
+	if closedAt.Valid {
+		s.ClosedAt = &closedAt.Time
+	}
+	return s, nil
+}
+
+func (r *shiftRepository) Open(ctx context.Context, shift model.Shift) (model.Shift, error) {
+	query := `INSERT INTO shifts (cashier_name, opening_float) VALUES ($1, $2) RETURNING ` + shiftColumns
+	return scanShift(r.db.QueryRowContext(ctx, query, shift.CashierName, shift.OpeningFloat))
+}
+
+func (r *shiftRepository) GetAll(ctx context.Context, status string) ([]model.Shift, error) {
+	query := `SELECT ` + shiftColumns + ` FROM shifts WHERE ($1 = '' OR status = $1) ORDER BY opened_at DESC, id DESC`
+	rows, err := r.db.QueryContext(ctx, query, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shifts := []model.Shift{}
+	for rows.Next() {
+		s, err := scanShift(rows)
+		if err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, s)
+	}
+	return shifts, rows.Err()
+}
+
+func (r *shiftRepository) GetByID(ctx context.Context, id int) (model.Shift, error) {
+	return r.getShift(ctx, r.db, id)
+}
+
+func (r *shiftRepository) getShift(ctx context.Context, q queryer, id int) (model.Shift, error) {
+	s, err := scanShift(q.QueryRowContext(ctx, `SELECT `+shiftColumns+` FROM shifts WHERE id = $1`, id))
+	if err != nil {
+		return model.Shift{}, err
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT id, shift_id, type, amount, COALESCE(reason, ''), created_at FROM shift_cash_movements WHERE shift_id = $1 ORDER BY id`, id)
+	if err != nil {
+		return model.Shift{}, err
+	}
+	for rows.Next() {
+		var m model.CashMovement
+		if err := rows.Scan(&m.ID, &m.ShiftID, &m.Type, &m.Amount, &m.Reason, &m.CreatedAt); err != nil {
+			rows.Close()
+			return model.Shift{}, err
+		}
+		s.CashMovements = append(s.CashMovements, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return model.Shift{}, err
+	}
+
+	rows, err = q.QueryContext(ctx, `SELECT denomination, count FROM shift_denominations WHERE shift_id = $1 ORDER BY denomination DESC`, id)
+	if err != nil {
+		return model.Shift{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d model.DenominationCount
+		if err := rows.Scan(&d.Denomination, &d.Count); err != nil {
+			return model.Shift{}, err
+		}
+		s.Denominations = append(s.Denominations, d)
+	}
+	return s, rows.Err()
+}
+
+func (r *shiftRepository) AddCashMovement(ctx context.Context, movement model.CashMovement) (model.CashMovement, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.CashMovement{}, err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenShift(ctx, tx, movement.ShiftID, "FOR SHARE"); err != nil {
+		return model.CashMovement{}, err
+	}
+
+	query := `INSERT INTO shift_cash_movements (shift_id, type, amount, reason) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	if err := tx.QueryRowContext(ctx, query, movement.ShiftID, movement.Type, movement.Amount, movement.Reason).Scan(&movement.ID, &movement.CreatedAt); err != nil {
+		return model.CashMovement{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.CashMovement{}, err
+	}
+	return movement, nil
+}
+
+func (r *shiftRepository) Close(ctx context.Context, id int, denominations []model.DenominationCount, counted model.Money, note string) (model.ShiftReport, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.ShiftReport{}, err
+	}
+	defer tx.Rollback()
+
+	// The exclusive lock waits for in-flight sales holding a share lock
+	if err := lockOpenShift(ctx, tx, id, "FOR UPDATE"); err != nil {
+		return model.ShiftReport{}, err
+	}
+
+	report, err := r.report(ctx, tx, id)
+	if err != nil {
+		return model.ShiftReport{}, err
+	}
+
+	for _, d := range denominations {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO shift_denominations (shift_id, denomination, count) VALUES ($1, $2, $3)`, id, d.Denomination, d.Count); err != nil {
+			return model.ShiftReport{}, fmt.Errorf("failed to insert denomination: %w", err)
+		}
+	}
+
+	variance := counted - report.ExpectedCash
+	query := `UPDATE shifts SET status = $1, closed_at = CURRENT_TIMESTAMP, expected_cash = $2, counted_cash = $3, variance = $4, note = $5 WHERE id = $6`
+	if _, err := tx.ExecContext(ctx, query, model.ShiftStatusClosed, report.ExpectedCash, counted, variance, note, id); err != nil {
+		return model.ShiftReport{}, fmt.Errorf("failed to close shift: %w", err)
+	}
+
+	report.Shift, err = r.getShift(ctx, tx, id)
+	if err != nil {
+		return model.ShiftReport{}, err
+	}
+	report.CountedCash = report.Shift.CountedCash
+	report.Variance = report.Shift.Variance
+
+	if err := tx.Commit(); err != nil {
+		return model.ShiftReport{}, fmt.Errorf("failed to commit shift close: %w", err)
+	}
+	return report, nil
+}
+
+func (r *shiftRepository) GetReport(ctx context.Context, id int) (model.ShiftReport, error) {
+	report, err := r.report(ctx, r.db, id)
+	if err != nil {
+		return model.ShiftReport{}, err
+	}
+	report.Shift, err = r.getShift(ctx, r.db, id)
+	if err != nil {
+		return model.ShiftReport{}, err
+	}
+	report.CountedCash = report.Shift.CountedCash
+	report.Variance = report.Shift.Variance
+	return report, nil
+}
+
+// report works out the cash figures for a shift. Only refunds made during
+// the shift and paid out in cash come out of the drawer; card and other
+// refunds leave it alone.
+func (r *shiftRepository) report(ctx context.Context, q queryer, id int) (model.ShiftReport, error) {
+	var report model.ShiftReport
+	var openingFloat model.Money
+	if err := q.QueryRowContext(ctx, `SELECT opening_float FROM shifts WHERE id = $1`, id).Scan(&openingFloat); err != nil {
+		return model.ShiftReport{}, err
+	}
+
+	salesQuery := `SELECT COUNT(id) FILTER (WHERE status <> 'voided'), COALESCE(SUM(total_amount), 0) FROM transactions WHERE shift_id = $1`
+	if err := q.QueryRowContext(ctx, salesQuery, id).Scan(&report.TransactionCount, &report.TotalSales); err != nil {
+		return model.ShiftReport{}, err
+	}
+
+	paymentsQuery := `
+		SELECT p.method, COALESCE(SUM(p.amount), 0), COUNT(p.id)
+		FROM payments p
+		JOIN transactions t ON t.id = p.transaction_id
+		WHERE t.shift_id = $1
+		GROUP BY p.method
+		ORDER BY p.method
+	`
+	rows, err := q.QueryContext(ctx, paymentsQuery, id)
+	if err != nil {
+		return model.ShiftReport{}, err
+	}
+	report.Payments = []model.PaymentMethodTotal{}
+	for rows.Next() {
+		var total model.PaymentMethodTotal
+		if err := rows.Scan(&total.Method, &total.Total, &total.Count); err != nil {
+			rows.Close()
+			return model.ShiftReport{}, err
+		}
+		report.Payments = append(report.Payments, total)
+		if total.Method == model.PaymentMethodCash {
+			report.CashSales = total.Total
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return model.ShiftReport{}, err
+	}
+
+	if err := q.QueryRowContext(ctx, `SELECT COALESCE(SUM(total_amount), 0) FROM refunds WHERE shift_id = $1 AND method = $2`, id, model.PaymentMethodCash).Scan(&report.CashRefunds); err != nil {
+		return model.ShiftReport{}, err
+	}
+
+	movementsQuery := `
+		SELECT
+			COALESCE(SUM(amount) FILTER (WHERE type = 'cash_in'), 0),
+			COALESCE(SUM(amount) FILTER (WHERE type = 'cash_out'), 0)
+		FROM shift_cash_movements
+		WHERE shift_id = $1
+	`
+	if err := q.QueryRowContext(ctx, movementsQuery, id).Scan(&report.CashIn, &report.CashOut); err != nil {
+		return model.ShiftReport{}, err
+	}
+
+	report.ExpectedCash = openingFloat + report.CashSales + report.CashIn - report.CashOut - report.CashRefunds
+	return report, nil
+}
+
+// lockOpenShift locks the shift row with the given lock clause and checks it is
+// still open. Sales and cash movements take a share lock so that a close,
+// which takes an exclusive lock, waits for them to finish.
+func lockOpenShift(ctx context.Context, tx *sql.Tx, id int, lock string) error {
+	var status string
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM shifts WHERE id = $1 `+lock, id).Scan(&status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("shift not found: %d", id)
+		}
+		return err
+	}
+	if status != model.ShiftStatusOpen {
+		return fmt.Errorf("shift %d is not open", id)
+	}
+	return nil
+}
+
+// nullableID maps a zero ID to nil so it is stored as NULL.
+func nullableID(id int) *int {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
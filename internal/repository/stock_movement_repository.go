@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"kasir-api/internal/model"
+	"time"
+)
+
+type StockMovementRepository interface {
+	Record(ctx context.Context, movement model.StockMovement) (model.StockMovement, error)
+	GetByProduct(ctx context.Context, productID int) ([]model.StockMovement, error)
+	CheckConsistency(ctx context.Context) ([]model.StockDiscrepancy, error)
+	GetReorderCandidates(ctx context.Context, since, until time.Time) ([]model.ReorderCandidate, error)
+}
+
+type stockMovementRepository struct {
+	db *sql.DB
+}
+
+func NewStockMovementRepository(db *sql.DB) StockMovementRepository {
+	return &stockMovementRepository{db: db}
+}
+
+// Record applies a manual movement such as an adjustment or transfer to the
+// product's stock and writes it to the ledger.
+func (r *stockMovementRepository) Record(ctx context.Context, movement model.StockMovement) (model.StockMovement, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.StockMovement{}, err
+	}
+	defer tx.Rollback()
+
+	if err := applyStockMovement(ctx, tx, &movement); err != nil {
+		return model.StockMovement{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.StockMovement{}, err
+	}
+	return movement, nil
+}
+
+func (r *stockMovementRepository) GetByProduct(ctx context.Context, productID int) ([]model.StockMovement, error) {
+	query := `
+		SELECT id, product_id, COALESCE(product_name, ''), type, quantity, COALESCE(reference_type, ''), COALESCE(reference_id, 0), COALESCE(note, ''), created_at
+		FROM stock_movements
+		WHERE product_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := []model.StockMovement{}
+	for rows.Next() {
+		var m model.StockMovement
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.ProductName, &m.Type, &m.Quantity, &m.ReferenceType, &m.ReferenceID, &m.Note, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
+
+// CheckConsistency recomputes every product's stock from the ledger and
+// returns the products where it differs from the stock column.
+func (r *stockMovementRepository) CheckConsistency(ctx context.Context) ([]model.StockDiscrepancy, error) {
+	query := `
+		SELECT p.id, p.name, p.stock, COALESCE(SUM(m.quantity), 0)
+		FROM products p
+		LEFT JOIN stock_movements m ON m.product_id = p.id
+		GROUP BY p.id, p.name, p.stock
+		HAVING p.stock <> COALESCE(SUM(m.quantity), 0)
+		ORDER BY p.id
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	discrepancies := []model.StockDiscrepancy{}
+	for rows.Next() {
+		var d model.StockDiscrepancy
+		if err := rows.Scan(&d.ProductID, &d.ProductName, &d.Stock, &d.LedgerStock); err != nil {
+			return nil, err
+		}
+		d.Difference = d.Stock - d.LedgerStock
+		discrepancies = append(discrepancies, d)
+	}
+	return discrepancies, rows.Err()
+}
+
+// GetReorderCandidates returns every product with its net quantity sold
+// between since and until, what is still due on open and partially received
+// purchase orders, and the supplier of its most recent purchase order. Voided
+// sales and refunded quantities are left out of the sales figure, which is in
+// the product's own unit. Composite products are left out and their sales
+// count towards their components.
+func (r *stockMovementRepository) GetReorderCandidates(ctx context.Context, since, until time.Time) ([]model.ReorderCandidate, error) {
+	query := `
+		WITH kept AS (
+			SELECT d.id, d.product_id, d.base_quantity, (d.quantity - COALESCE(ri.quantity, 0)) / d.quantity AS share
+			FROM transaction_details d
+			JOIN transactions t ON t.id = d.transaction_id
+			LEFT JOIN (
+				SELECT transaction_detail_id, SUM(quantity) AS quantity
+				FROM refund_items
+				GROUP BY transaction_detail_id
+			) ri ON ri.transaction_detail_id = d.id
+			WHERE t.created_at >= $1 AND t.created_at < $4 AND t.status <> $2
+		), drawn AS (
+			SELECT k.product_id, k.base_quantity * k.share AS quantity
+			FROM kept k
+			WHERE NOT EXISTS (SELECT 1 FROM transaction_detail_components c WHERE c.transaction_detail_id = k.id)
+			UNION ALL
+			SELECT c.product_id, c.quantity * k.share
+			FROM kept k
+			JOIN transaction_detail_components c ON c.transaction_detail_id = k.id
+		), sold AS (
+			SELECT product_id, SUM(ROUND(quantity, 3)) AS quantity
+			FROM drawn
+			WHERE product_id IS NOT NULL
+			GROUP BY product_id
+		), on_order AS (
+			SELECT i.product_id, SUM(GREATEST(i.quantity - i.received_quantity, 0)) AS quantity
+			FROM purchase_order_items i
+			JOIN purchase_orders po ON po.id = i.purchase_order_id
+			WHERE po.status IN ($5, $6) AND i.product_id IS NOT NULL
+			GROUP BY i.product_id
+		)
+		SELECT p.id, p.name, p.stock, p.cost_price, p.reorder_point, p.reorder_quantity,
+			COALESCE(sold.quantity, 0), COALESCE(on_order.quantity, 0), COALESCE(s.id, 0), COALESCE(s.name, '')
+		FROM products p
+		LEFT JOIN sold ON sold.product_id = p.id
+		LEFT JOIN on_order ON on_order.product_id = p.id
+		LEFT JOIN LATERAL (
+			SELECT po.supplier_id
+			FROM purchase_order_items i
+			JOIN purchase_orders po ON po.id = i.purchase_order_id
+			WHERE i.product_id = p.id AND po.status <> $3
+			ORDER BY po.created_at DESC, po.id DESC
+			LIMIT 1
+		) last_order ON true
+		LEFT JOIN suppliers s ON s.id = last_order.supplier_id
+		WHERE NOT EXISTS (SELECT 1 FROM product_components pc WHERE pc.product_id = p.id)
+		ORDER BY p.name, p.id
+	`
+	rows, err := r.db.QueryContext(ctx, query, since, model.TransactionStatusVoided, model.PurchaseOrderStatusCancelled, until,
+		model.PurchaseOrderStatusOpen, model.PurchaseOrderStatusPartiallyReceived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []model.ReorderCandidate
+	for rows.Next() {
+		var c model.ReorderCandidate
+		if err := rows.Scan(&c.ID, &c.Name, &c.Stock, &c.CostPrice, &c.ReorderPoint, &c.ReorderQuantity, &c.SoldQuantity, &c.OnOrderQuantity, &c.SupplierID, &c.SupplierName); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// applyStockMovement changes the product's stock by movement.Quantity and
+// records the movement. It refuses to take stock below zero, returning an
+// InsufficientStockError. A composite product has no stock of its own, so
+// moving it returns ErrCompositeStock.
+func applyStockMovement(ctx context.Context, tx *sql.Tx, movement *model.StockMovement) error {
+	query := `UPDATE products SET stock = stock + $1
+		WHERE id = $2 AND stock + $1 >= 0 AND NOT EXISTS (SELECT 1 FROM product_components WHERE product_id = $2)`
+	result, err := tx.ExecContext(ctx, query, movement.Quantity, movement.ProductID)
+	if err != nil {
+		return fmt.Errorf("failed to update stock: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update stock: %w", err)
+	} else if affected == 0 {
+		var name string
+		var stock model.Quantity
+		var composite bool
+		err := tx.QueryRowContext(ctx, `SELECT name, stock, EXISTS (SELECT 1 FROM product_components WHERE product_id = $1) FROM products WHERE id = $1`, movement.ProductID).
+			Scan(&name, &stock, &composite)
+		if err != nil {
+			return fmt.Errorf("product not found: %d", movement.ProductID)
+		}
+		if composite {
+			return model.ErrCompositeStock
+		}
+		return &model.InsufficientStockError{ProductID: movement.ProductID, ProductName: name, Requested: -movement.Quantity, Available: stock}
+	}
+	return insertStockMovement(ctx, tx, movement)
+}
+
+// insertStockMovement writes a ledger entry for a stock change the caller has
+// already made, with the product's current name so the entry still reads
+// after the product is deleted.
+func insertStockMovement(ctx context.Context, tx *sql.Tx, movement *model.StockMovement) error {
+	query := `INSERT INTO stock_movements (product_id, product_name, type, quantity, reference_type, reference_id, note)
+		VALUES ($1, (SELECT name FROM products WHERE id = $1), $2, $3, NULLIF($4, ''), $5, NULLIF($6, '')) RETURNING id, COALESCE(product_name, ''), created_at`
+	err := tx.QueryRowContext(ctx, query, movement.ProductID, movement.Type, movement.Quantity, movement.ReferenceType, nullableID(movement.ReferenceID), movement.Note).
+		Scan(&movement.ID, &movement.ProductName, &movement.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record stock movement: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"kasir-api/internal/model"
+	"testing"
+)
+
+func TestRecordStockMovementKeepsLedgerAfterDelete(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	_, productID := createTestProduct(t, db, "ledger shelf", 5)
+	repo := NewStockMovementRepository(db)
+
+	removal := model.StockMovement{ProductID: productID, Type: model.StockMovementAdjustment, Quantity: model.WholeQuantity(-6), Note: "broken"}
+	var stockErr *model.InsufficientStockError
+	if _, err := repo.Record(ctx, removal); !errors.As(err, &stockErr) || stockErr.Available != model.WholeQuantity(5) {
+		t.Fatalf("Expected an InsufficientStockError with 5 available, got %v", err)
+	}
+
+	removal.Quantity = model.WholeQuantity(-2)
+	recorded, err := repo.Record(ctx, removal)
+	if err != nil {
+		t.Fatalf("failed to record movement: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM stock_movements WHERE id = $1`, recorded.ID) })
+	if recorded.ProductName != "ledger shelf" {
+		t.Errorf("Expected the ledger entry to carry the product name, got %q", recorded.ProductName)
+	}
+	if stock := readStock(t, db, productID); stock != model.WholeQuantity(3) {
+		t.Errorf("Expected stock 3, got %v", stock)
+	}
+
+	if _, err := db.Exec(`DELETE FROM products WHERE id = $1`, productID); err != nil {
+		t.Fatalf("failed to delete product: %v", err)
+	}
+	var name string
+	var quantity model.Quantity
+	if err := db.QueryRow(`SELECT product_name, quantity FROM stock_movements WHERE id = $1 AND product_id IS NULL`, recorded.ID).Scan(&name, &quantity); err != nil {
+		t.Fatalf("Expected the ledger entry to outlive the product: %v", err)
+	}
+	if name != "ledger shelf" || quantity != model.WholeQuantity(-2) {
+		t.Errorf("Unexpected ledger entry after delete: %q %v", name, quantity)
+	}
+}
@@ -0,0 +1,320 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/internal/model"
+	"sort"
+)
+
+type StocktakeRepository interface {
+	Start(ctx context.Context, request model.StartStocktakeRequest) (model.Stocktake, error)
+	GetAll(ctx context.Context) ([]model.Stocktake, error)
+	GetByID(ctx context.Context, id int) (model.Stocktake, error)
+	SubmitCounts(ctx context.Context, id int, request model.SubmitCountsRequest) error
+	Post(ctx context.Context, id int) (model.Stocktake, error)
+	Cancel(ctx context.Context, id int) error
+}
+
+type stocktakeRepository struct {
+	db *sql.DB
+}
+
+func NewStocktakeRepository(db *sql.DB) StocktakeRepository {
+	return &stocktakeRepository{db: db}
+}
+
+const stocktakeColumns = `id, category_id, status, COALESCE(note, ''), started_at, posted_at`
+
+func scanStocktake(scanner interface{ Scan(...interface{}) error }) (model.Stocktake, error) {
+	var s model.Stocktake
+	var postedAt sql.NullTime
+	if err := scanner.Scan(&s.ID, &s.CategoryID, &s.Status, &s.Note, &s.StartedAt, &postedAt); err != nil {
+		return model.Stocktake{}, err
+	}
+	if postedAt.Valid {
+		s.PostedAt = &postedAt.Time
+	}
+	return s, nil
+}
+
+// Start opens a session and snapshots the stock and cost of every product in
+// scope.
+func (r *stocktakeRepository) Start(ctx context.Context, request model.StartStocktakeRequest) (model.Stocktake, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Stocktake{}, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO stocktakes (category_id, note) VALUES ($1, NULLIF($2, '')) RETURNING ` + stocktakeColumns
+	stocktake, err := scanStocktake(tx.QueryRowContext(ctx, query, request.CategoryID, request.Note))
+	if err != nil {
+		return model.Stocktake{}, fmt.Errorf("failed to start stocktake: %w", err)
+	}
+
+	itemsQuery := `
+		INSERT INTO stocktake_items (stocktake_id, product_id, product_name, system_stock, unit_cost)
+		SELECT $1, id, name, stock, cost_price FROM products
+		WHERE ($2::int IS NULL OR category_id = $2)
+			AND NOT EXISTS (SELECT 1 FROM products v WHERE v.parent_id = products.id)
+			AND NOT EXISTS (SELECT 1 FROM product_components pc WHERE pc.product_id = products.id)
+	`
+	if _, err := tx.ExecContext(ctx, itemsQuery, stocktake.ID, request.CategoryID); err != nil {
+		return model.Stocktake{}, fmt.Errorf("failed to snapshot stock: %w", err)
+	}
+
+	stocktake.Items, err = r.getItems(ctx, tx, stocktake.ID)
+	if err != nil {
+		return model.Stocktake{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Stocktake{}, err
+	}
+	return stocktake, nil
+}
+
+func (r *stocktakeRepository) GetAll(ctx context.Context) ([]model.Stocktake, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+stocktakeColumns+` FROM stocktakes ORDER BY started_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stocktakes := []model.Stocktake{}
+	for rows.Next() {
+		s, err := scanStocktake(rows)
+		if err != nil {
+			return nil, err
+		}
+		stocktakes = append(stocktakes, s)
+	}
+	return stocktakes, rows.Err()
+}
+
+func (r *stocktakeRepository) GetByID(ctx context.Context, id int) (model.Stocktake, error) {
+	stocktake, err := scanStocktake(r.db.QueryRowContext(ctx, `SELECT `+stocktakeColumns+` FROM stocktakes WHERE id = $1`, id))
+	if err != nil {
+		return model.Stocktake{}, err
+	}
+	stocktake.Items, err = r.getItems(ctx, r.db, id)
+	if err != nil {
+		return model.Stocktake{}, err
+	}
+	return stocktake, nil
+}
+
+// getItems loads the session's products with the counted quantity summed over
+// all counters and the variance against the snapshot.
+func (r *stocktakeRepository) getItems(ctx context.Context, q queryer, id int) ([]model.StocktakeItem, error) {
+	query := `
+		SELECT i.product_id, i.product_name, i.system_stock, i.unit_cost, SUM(c.quantity), i.adjustment
+		FROM stocktake_items i
+		LEFT JOIN stocktake_counts c ON c.stocktake_id = i.stocktake_id AND c.product_id = i.product_id
+		WHERE i.stocktake_id = $1
+		GROUP BY i.product_id, i.product_name, i.system_stock, i.unit_cost, i.adjustment
+		ORDER BY i.product_name, i.product_id
+	`
+	rows, err := q.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []model.StocktakeItem{}
+	index := map[int]int{}
+	for rows.Next() {
+		var item model.StocktakeItem
+		if err := rows.Scan(&item.ProductID, &item.ProductName, &item.SystemStock, &item.UnitCost, &item.CountedQuantity, &item.Adjustment); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if item.CountedQuantity != nil {
+			item.Variance = *item.CountedQuantity - item.SystemStock
+			item.VarianceValue = item.UnitCost.MulQuantity(item.Variance)
+		}
+		index[item.ProductID] = len(items)
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	countsQuery := `SELECT product_id, counter_name, quantity, counted_at FROM stocktake_counts WHERE stocktake_id = $1 ORDER BY counted_at, counter_name`
+	rows, err = q.QueryContext(ctx, countsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c model.StocktakeCount
+		if err := rows.Scan(&c.ProductID, &c.CounterName, &c.Quantity, &c.CountedAt); err != nil {
+			return nil, err
+		}
+		if i, ok := index[c.ProductID]; ok {
+			items[i].Counts = append(items[i].Counts, c)
+		}
+	}
+	return items, rows.Err()
+}
+
+// SubmitCounts saves one counter's counts with the position the product's
+// ledger had reached. A product the counter already counted in this session
+// is replaced, so recounts don't add up twice.
+func (r *stocktakeRepository) SubmitCounts(ctx context.Context, id int, request model.SubmitCountsRequest) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenStocktake(ctx, tx, id, "FOR SHARE"); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO stocktake_counts (stocktake_id, product_id, counter_name, quantity, ledger_position)
+		SELECT $1, product_id, $3, $4, COALESCE((SELECT MAX(m.id) FROM stock_movements m WHERE m.product_id = $2), 0)
+		FROM stocktake_items WHERE stocktake_id = $1 AND product_id = $2
+		ON CONFLICT (stocktake_id, product_id, counter_name)
+		DO UPDATE SET quantity = EXCLUDED.quantity, counted_at = CURRENT_TIMESTAMP, ledger_position = EXCLUDED.ledger_position
+	`
+	for _, count := range request.Counts {
+		result, err := tx.ExecContext(ctx, query, id, count.ProductID, request.CounterName, count.Quantity)
+		if err != nil {
+			return fmt.Errorf("failed to save count: %w", err)
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			return fmt.Errorf("product %d is not part of stocktake %d", count.ProductID, id)
+		}
+	}
+	return tx.Commit()
+}
+
+// Post brings the stock of every counted product in line with its count and
+// closes the session, all in one database transaction. Sales, refunds and
+// receipts made after a product's last count are carried forward on top of
+// the count, so nothing that moved while the session was open is lost or
+// counted twice.
+func (r *stocktakeRepository) Post(ctx context.Context, id int) (model.Stocktake, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Stocktake{}, err
+	}
+	defer tx.Rollback()
+
+	// The exclusive lock waits for counts still being saved
+	if err := lockOpenStocktake(ctx, tx, id, "FOR UPDATE"); err != nil {
+		return model.Stocktake{}, err
+	}
+
+	items, err := r.getItems(ctx, tx, id)
+	if err != nil {
+		return model.Stocktake{}, err
+	}
+
+	// Products are locked in ID order, as checkouts do, so the two cannot
+	// deadlock
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return items[order[a]].ProductID < items[order[b]].ProductID })
+
+	sinceCountQuery := `
+		SELECT COALESCE(SUM(m.quantity), 0)
+		FROM stock_movements m
+		WHERE m.product_id = $2 AND m.id > (SELECT MAX(c.ledger_position) FROM stocktake_counts c WHERE c.stocktake_id = $1 AND c.product_id = $2)
+	`
+	for _, i := range order {
+		item := &items[i]
+		if item.CountedQuantity == nil {
+			continue
+		}
+		var stock model.Quantity
+		if err := tx.QueryRowContext(ctx, `SELECT stock FROM products WHERE id = $1 FOR UPDATE`, item.ProductID).Scan(&stock); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				// A product deleted since the count has no stock left to adjust
+				continue
+			}
+			return model.Stocktake{}, err
+		}
+		var sinceCount model.Quantity
+		if err := tx.QueryRowContext(ctx, sinceCountQuery, id, item.ProductID).Scan(&sinceCount); err != nil {
+			return model.Stocktake{}, err
+		}
+
+		change := stocktakeAdjustment(*item.CountedQuantity, sinceCount, stock)
+		if change != 0 {
+			adjustment := model.StockMovement{
+				ProductID:     item.ProductID,
+				Type:          model.StockMovementAdjustment,
+				Quantity:      change,
+				ReferenceType: model.StockReferenceStocktake,
+				ReferenceID:   id,
+				Note:          "stock opname",
+			}
+			if err := applyStockMovement(ctx, tx, &adjustment); err != nil {
+				return model.Stocktake{}, err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE stocktake_items SET adjustment = $3 WHERE stocktake_id = $1 AND product_id = $2`, id, item.ProductID, change); err != nil {
+			return model.Stocktake{}, fmt.Errorf("failed to record adjustment: %w", err)
+		}
+		item.Adjustment = &change
+	}
+
+	query := `UPDATE stocktakes SET status = $1, posted_at = CURRENT_TIMESTAMP WHERE id = $2 RETURNING ` + stocktakeColumns
+	stocktake, err := scanStocktake(tx.QueryRowContext(ctx, query, model.StocktakeStatusPosted, id))
+	if err != nil {
+		return model.Stocktake{}, fmt.Errorf("failed to post stocktake: %w", err)
+	}
+	stocktake.Items = items
+
+	if err := tx.Commit(); err != nil {
+		return model.Stocktake{}, fmt.Errorf("failed to commit stocktake: %w", err)
+	}
+	return stocktake, nil
+}
+
+// stocktakeAdjustment is the change that makes stock match a count once what
+// has moved since the count is added to it.
+func stocktakeAdjustment(counted, sinceCount, stock model.Quantity) model.Quantity {
+	return counted + sinceCount - stock
+}
+
+func (r *stocktakeRepository) Cancel(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenStocktake(ctx, tx, id, "FOR UPDATE"); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE stocktakes SET status = $1 WHERE id = $2`, model.StocktakeStatusCancelled, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockOpenStocktake locks the session row and checks it can still change.
+// Count submissions take a share lock so posting waits for them.
+func lockOpenStocktake(ctx context.Context, tx *sql.Tx, id int, lock string) error {
+	var status string
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM stocktakes WHERE id = $1 `+lock, id).Scan(&status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("stocktake not found: %d", id)
+		}
+		return err
+	}
+	if status != model.StocktakeStatusOpen {
+		return fmt.Errorf("stocktake %d is %s", id, status)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"kasir-api/internal/model"
+	"testing"
+)
+
+func TestStocktakePostCarriesForwardSalesAfterTheCount(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	categoryID, productID := createTestProduct(t, db, "stocktake shelf", 10)
+	sales := NewTransactionRepository(db, model.BusinessCalendar{})
+	stocktakes := NewStocktakeRepository(db)
+
+	start := func() int {
+		stocktake, err := stocktakes.Start(ctx, model.StartStocktakeRequest{CategoryID: &categoryID})
+		if err != nil {
+			t.Fatalf("failed to start stocktake: %v", err)
+		}
+		t.Cleanup(func() { db.Exec(`DELETE FROM stocktakes WHERE id = $1`, stocktake.ID) })
+		return stocktake.ID
+	}
+	count := func(id, units int) {
+		request := model.SubmitCountsRequest{CounterName: "shelf", Counts: []model.StocktakeCountItem{{ProductID: productID, Quantity: model.WholeQuantity(units)}}}
+		if err := stocktakes.SubmitCounts(ctx, id, request); err != nil {
+			t.Fatalf("failed to count: %v", err)
+		}
+	}
+	post := func(id int) model.Stocktake {
+		stocktake, err := stocktakes.Post(ctx, id)
+		if err != nil {
+			t.Fatalf("failed to post: %v", err)
+		}
+		return stocktake
+	}
+
+	// 3 sold between the start and the count: the shelf already shows them
+	// gone, so the 7 counted is exactly right
+	id := start()
+	sellTestProduct(t, sales, productID, 3)
+	count(id, 7)
+	posted := post(id)
+	if stock := readStock(t, db, productID); stock != model.WholeQuantity(7) {
+		t.Errorf("Expected stock 7 after a sale before the count, got %v", stock)
+	}
+	if adjustment := posted.Items[0].Adjustment; adjustment == nil || *adjustment != 0 {
+		t.Errorf("Expected no adjustment, got %v", adjustment)
+	}
+
+	// 2 sold between the count and the post: the count of 6 is one short
+	// of the 7 on record, and the sale still comes off afterwards
+	id = start()
+	count(id, 6)
+	sellTestProduct(t, sales, productID, 2)
+	posted = post(id)
+	if stock := readStock(t, db, productID); stock != model.WholeQuantity(4) {
+		t.Errorf("Expected stock 4 after a sale after the count, got %v", stock)
+	}
+	if adjustment := posted.Items[0].Adjustment; adjustment == nil || *adjustment != model.WholeQuantity(-1) {
+		t.Errorf("Expected an adjustment of -1, got %v", adjustment)
+	}
+
+	// A posted session cannot be posted again
+	want := fmt.Sprintf("stocktake %d is %s", id, model.StocktakeStatusPosted)
+	if _, err := stocktakes.Post(ctx, id); err == nil || err.Error() != want {
+		t.Errorf("Expected %q, got %v", want, err)
+	}
+	if stock := readStock(t, db, productID); stock != model.WholeQuantity(4) {
+		t.Errorf("Expected stock to stay 4, got %v", stock)
+	}
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/internal/model"
+)
+
+type SupplierRepository interface {
+	Create(supplier model.Supplier) (model.Supplier, error)
+	GetAll() ([]model.Supplier, error)
+	GetByID(id int) (model.Supplier, error)
+	Update(id int, supplier model.Supplier) (model.Supplier, error)
+	Delete(id int) error
+	AddPayment(ctx context.Context, payment model.SupplierPayment) (model.SupplierPayment, error)
+	GetPayments(ctx context.Context, supplierID int) ([]model.SupplierPayment, error)
+}
+
+type supplierRepository struct {
+	db *sql.DB
+}
+
+func NewSupplierRepository(db *sql.DB) SupplierRepository {
+	return &supplierRepository{db: db}
+}
+
+// supplierColumns includes the payable balance: goods received less payments.
+const supplierColumns = `s.id, s.name, COALESCE(s.phone, ''), COALESCE(s.email, ''), COALESCE(s.address, ''),
+	COALESCE((SELECT SUM(total_amount) FROM goods_receipts WHERE supplier_id = s.id), 0)
+	- COALESCE((SELECT SUM(amount) FROM supplier_payments WHERE supplier_id = s.id), 0)`
+
+func scanSupplier(scanner interface{ Scan(...interface{}) error }) (model.Supplier, error) {
+	var s model.Supplier
+	if err := scanner.Scan(&s.ID, &s.Name, &s.Phone, &s.Email, &s.Address, &s.Payable); err != nil {
+		return model.Supplier{}, err
+	}
+	return s, nil
+}
+
+func (r *supplierRepository) Create(supplier model.Supplier) (model.Supplier, error) {
+	query := `INSERT INTO suppliers (name, phone, email, address) VALUES ($1, $2, $3, $4) RETURNING id`
+	err := r.db.QueryRow(query, supplier.Name, supplier.Phone, supplier.Email, supplier.Address).Scan(&supplier.ID)
+	if err != nil {
+		return model.Supplier{}, err
+	}
+	supplier.Payable = 0
+	return supplier, nil
+}
+
+func (r *supplierRepository) GetAll() ([]model.Supplier, error) {
+	rows, err := r.db.Query(`SELECT ` + supplierColumns + ` FROM suppliers s ORDER BY s.name, s.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suppliers := []model.Supplier{}
+	for rows.Next() {
+		s, err := scanSupplier(rows)
+		if err != nil {
+			return nil, err
+		}
+		suppliers = append(suppliers, s)
+	}
+	return suppliers, rows.Err()
+}
+
+func (r *supplierRepository) GetByID(id int) (model.Supplier, error) {
+	return scanSupplier(r.db.QueryRow(`SELECT `+supplierColumns+` FROM suppliers s WHERE s.id = $1`, id))
+}
+
+func (r *supplierRepository) Update(id int, supplier model.Supplier) (model.Supplier, error) {
+	query := `UPDATE suppliers SET name = $1, phone = $2, email = $3, address = $4 WHERE id = $5`
+	result, err := r.db.Exec(query, supplier.Name, supplier.Phone, supplier.Email, supplier.Address, id)
+	if err != nil {
+		return model.Supplier{}, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return model.Supplier{}, err
+	} else if affected == 0 {
+		return model.Supplier{}, sql.ErrNoRows
+	}
+	return r.GetByID(id)
+}
+
+func (r *supplierRepository) Delete(id int) error {
+	_, err := r.db.Exec(`DELETE FROM suppliers WHERE id = $1`, id)
+	return err
+}
+
+// AddPayment records a payment to the supplier. Paying more than is owed is
+// rejected so the payable never goes negative by mistake. The supplier row is
+// locked while the payable is checked, so two payments made at once cannot
+// both pass the check.
+func (r *supplierRepository) AddPayment(ctx context.Context, payment model.SupplierPayment) (model.SupplierPayment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.SupplierPayment{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM suppliers WHERE id = $1 FOR UPDATE`, payment.SupplierID); err != nil {
+		return model.SupplierPayment{}, err
+	}
+	supplier, err := scanSupplier(tx.QueryRowContext(ctx, `SELECT `+supplierColumns+` FROM suppliers s WHERE s.id = $1`, payment.SupplierID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.SupplierPayment{}, fmt.Errorf("supplier not found: %d", payment.SupplierID)
+		}
+		return model.SupplierPayment{}, err
+	}
+	if payment.Amount > supplier.Payable {
+		return model.SupplierPayment{}, fmt.Errorf("payment exceeds the outstanding payable of %s", supplier.Payable)
+	}
+
+	query := `INSERT INTO supplier_payments (supplier_id, amount, method, reference) VALUES ($1, $2, $3, NULLIF($4, '')) RETURNING id, paid_at`
+	err = tx.QueryRowContext(ctx, query, payment.SupplierID, payment.Amount, payment.Method, payment.Reference).Scan(&payment.ID, &payment.PaidAt)
+	if err != nil {
+		return model.SupplierPayment{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.SupplierPayment{}, err
+	}
+	return payment, nil
+}
+
+func (r *supplierRepository) GetPayments(ctx context.Context, supplierID int) ([]model.SupplierPayment, error) {
+	query := `SELECT id, supplier_id, amount, method, COALESCE(reference, ''), paid_at FROM supplier_payments WHERE supplier_id = $1 ORDER BY paid_at DESC, id DESC`
+	rows, err := r.db.QueryContext(ctx, query, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []model.SupplierPayment{}
+	for rows.Next() {
+		var p model.SupplierPayment
+		if err := rows.Scan(&p.ID, &p.SupplierID, &p.Amount, &p.Method, &p.Reference, &p.PaidAt); err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"database/sql"
+	"kasir-api/internal/model"
+)
+
+type TaxClassRepository interface {
+	Create(taxClass model.TaxClass) (model.TaxClass, error)
+	GetAll() ([]model.TaxClass, error)
+	GetByID(id int) (model.TaxClass, error)
+	Update(id int, taxClass model.TaxClass) (model.TaxClass, error)
+	Delete(id int) error
+}
+
+type taxClassRepository struct {
+	db *sql.DB
+}
+
+func NewTaxClassRepository(db *sql.DB) TaxClassRepository {
+	return &taxClassRepository{db: db}
+}
+
+func (r *taxClassRepository) Create(taxClass model.TaxClass) (model.TaxClass, error) {
+	query := `INSERT INTO tax_classes (name, rate, inclusive) VALUES ($1, $2, $3) RETURNING id`
+	err := r.db.QueryRow(query, taxClass.Name, taxClass.Rate, taxClass.Inclusive).Scan(&taxClass.ID)
+	if err != nil {
+		return model.TaxClass{}, err
+	}
+	return taxClass, nil
+}
+
+func (r *taxClassRepository) GetAll() ([]model.TaxClass, error) {
+	query := `SELECT id, name, rate, inclusive FROM tax_classes ORDER BY id`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var taxClasses []model.TaxClass
+	for rows.Next() {
+		var tc model.TaxClass
+		if err := rows.Scan(&tc.ID, &tc.Name, &tc.Rate, &tc.Inclusive); err != nil {
+			return nil, err
+		}
+		taxClasses = append(taxClasses, tc)
+	}
+	return taxClasses, nil
+}
+
+func (r *taxClassRepository) GetByID(id int) (model.TaxClass, error) {
+	query := `SELECT id, name, rate, inclusive FROM tax_classes WHERE id = $1`
+	var tc model.TaxClass
+	err := r.db.QueryRow(query, id).Scan(&tc.ID, &tc.Name, &tc.Rate, &tc.Inclusive)
+	if err != nil {
+		return model.TaxClass{}, err
+	}
+	return tc, nil
+}
+
+func (r *taxClassRepository) Update(id int, taxClass model.TaxClass) (model.TaxClass, error) {
+	query := `UPDATE tax_classes SET name = $1, rate = $2, inclusive = $3 WHERE id = $4 RETURNING id, name, rate, inclusive`
+	var updated model.TaxClass
+	err := r.db.QueryRow(query, taxClass.Name, taxClass.Rate, taxClass.Inclusive, id).Scan(&updated.ID, &updated.Name, &updated.Rate, &updated.Inclusive)
+	if err != nil {
+		return model.TaxClass{}, err
+	}
+	return updated, nil
+}
+
+func (r *taxClassRepository) Delete(id int) error {
+	query := `DELETE FROM tax_classes WHERE id = $1`
+	_, err := r.db.Exec(query, id)
+	return err
+}
@@ -3,22 +3,48 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"kasir-api/internal/model"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type TransactionRepository interface {
 	CreateTransaction(ctx context.Context, transaction model.Transaction, details []model.TransactionDetail) (model.Transaction, error)
 	GetDailyReport(ctx context.Context, date time.Time) (model.DailyReport, error)
+	GetAll(ctx context.Context, filter model.TransactionFilter) ([]model.Transaction, int, error)
+	GetByID(ctx context.Context, id int) (model.Transaction, error)
+	CreateRefund(ctx context.Context, transactionID int, refundType string, request model.RefundRequest) (model.Refund, error)
+	GetRefunds(ctx context.Context, transactionID int) ([]model.Refund, error)
+	GetTaxReport(ctx context.Context, from, to time.Time) ([]model.TaxReport, error)
+	GetSalesByPeriod(ctx context.Context, from, to time.Time, groupBy string) ([]model.SalesPeriod, error)
+	GetProductSales(ctx context.Context, from, to time.Time) ([]model.ItemSales, error)
+	GetCategorySales(ctx context.Context, from, to time.Time) ([]model.ItemSales, error)
+	GetProfit(ctx context.Context, from, to time.Time, groupBy string) ([]model.ProfitRow, error)
 }
 
+// Dates passed to a TransactionRepository, including TransactionFilter.From
+// and To, are business dates; the repository turns them into instants with
+// its calendar.
 type transactionRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	calendar model.BusinessCalendar
+}
+
+func NewTransactionRepository(db *sql.DB, calendar model.BusinessCalendar) TransactionRepository {
+	return &transactionRepository{db: db, calendar: calendar}
 }
 
-func NewTransactionRepository(db *sql.DB) TransactionRepository {
-	return &transactionRepository{db: db}
+// businessDate is the SQL expression for the business date of a created_at
+// column, taking the calendar's timezone and cutoff as parameters $tz and
+// $cutoff.
+func businessDate(column string, tz, cutoff int) string {
+	return fmt.Sprintf("((%s AT TIME ZONE $%d) - $%d::interval)::date", column, tz, cutoff)
 }
 
 func (r *transactionRepository) CreateTransaction(ctx context.Context, transaction model.Transaction, details []model.TransactionDetail) (model.Transaction, error) {
@@ -28,25 +54,87 @@ func (r *transactionRepository) CreateTransaction(ctx context.Context, transacti
 	}
 	defer tx.Rollback()
 
+	draws, err := loadStockDraws(ctx, tx, details)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	if err := lockAndCheckStock(ctx, tx, draws); err != nil {
+		return model.Transaction{}, err
+	}
+
+	if transaction.ShiftID != nil {
+		if err := lockOpenShift(ctx, tx, *transaction.ShiftID, "FOR SHARE"); err != nil {
+			return model.Transaction{}, err
+		}
+	}
+
 	// Insert Transaction
-	query := `INSERT INTO transactions (total_amount) VALUES ($1) RETURNING id, created_at`
-	if err := tx.QueryRowContext(ctx, query, transaction.TotalAmount).Scan(&transaction.ID, &transaction.CreatedAt); err != nil {
+	query := `INSERT INTO transactions (shift_id, subtotal, tax_amount, service_charge, total_amount, discount_amount, customer_id, price_level) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, status, created_at`
+	if err := tx.QueryRowContext(ctx, query, transaction.ShiftID, transaction.Subtotal, transaction.TaxAmount, transaction.ServiceCharge, transaction.TotalAmount, transaction.DiscountAmount, transaction.CustomerID, transaction.PriceLevel).Scan(&transaction.ID, &transaction.Status, &transaction.CreatedAt); err != nil {
 		return model.Transaction{}, fmt.Errorf("failed to insert transaction: %w", err)
 	}
 
 	// Insert Details and Update Stock
-	detailsQuery := `INSERT INTO transaction_details (transaction_id, product_id, quantity, subtotal) VALUES ($1, $2, $3, $4)`
-	updateStockQuery := `UPDATE products SET stock = stock - $1 WHERE id = $2`
+	detailsQuery := `INSERT INTO transaction_details (transaction_id, product_id, quantity, discount_amount, subtotal, tax_rate, tax_inclusive, tax_amount, service_charge, unit_cost,
+			unit_price, product_name, category_id, category_name, unit, base_quantity, price_tier_id, price_level, tier_min_quantity)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, (SELECT name FROM categories WHERE id = $13), $14, $15, NULLIF($16, 0), NULLIF($17, ''), $18)
+		RETURNING id, COALESCE(category_name, '')`
 
-	for _, detail := range details {
-		_, err := tx.ExecContext(ctx, detailsQuery, transaction.ID, detail.ProductID, detail.Quantity, detail.Subtotal)
+	for i := range details {
+		detail := &details[i]
+		detail.TransactionID = transaction.ID
+		err := tx.QueryRowContext(ctx, detailsQuery, transaction.ID, detail.ProductID, detail.Quantity, detail.DiscountAmount, detail.Subtotal,
+			detail.TaxRate, detail.TaxInclusive, detail.TaxAmount, detail.ServiceCharge, detail.UnitCost,
+			detail.UnitPrice, detail.ProductName, detail.CategoryID, detail.Unit, detail.BaseQuantity,
+			detail.PriceTierID, detail.PriceLevel, detail.TierMinQuantity).Scan(&detail.ID, &detail.CategoryName)
 		if err != nil {
 			return model.Transaction{}, fmt.Errorf("failed to insert detail: %w", err)
 		}
 
-		_, err = tx.ExecContext(ctx, updateStockQuery, detail.Quantity, detail.ProductID)
-		if err != nil {
-			return model.Transaction{}, fmt.Errorf("failed to update stock: %w", err)
+		for _, draw := range draws[i] {
+			sale := model.StockMovement{
+				ProductID:     draw.productID,
+				Type:          model.StockMovementSale,
+				Quantity:      -draw.quantity,
+				ReferenceType: model.StockReferenceTransaction,
+				ReferenceID:   transaction.ID,
+			}
+			if err := applyStockMovement(ctx, tx, &sale); err != nil {
+				return model.Transaction{}, err
+			}
+			if draw.productID != detail.ProductID {
+				// Remember what a composite line took so a refund can put it back
+				componentQuery := `INSERT INTO transaction_detail_components (transaction_detail_id, product_id, quantity) VALUES ($1, $2, $3)`
+				if _, err := tx.ExecContext(ctx, componentQuery, detail.ID, draw.productID, draw.quantity); err != nil {
+					return model.Transaction{}, fmt.Errorf("failed to insert detail component: %w", err)
+				}
+			}
+		}
+
+		for j := range detail.Discounts {
+			discount := &detail.Discounts[j]
+			discount.TransactionID = transaction.ID
+			discount.TransactionDetailID = detail.ID
+			if err := insertAppliedDiscount(ctx, tx, discount); err != nil {
+				return model.Transaction{}, err
+			}
+		}
+	}
+
+	for i := range transaction.Discounts {
+		discount := &transaction.Discounts[i]
+		discount.TransactionID = transaction.ID
+		if err := insertAppliedDiscount(ctx, tx, discount); err != nil {
+			return model.Transaction{}, err
+		}
+	}
+
+	paymentQuery := `INSERT INTO payments (transaction_id, method, amount, tendered, change_amount, reference) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	for i := range transaction.Payments {
+		payment := &transaction.Payments[i]
+		payment.TransactionID = transaction.ID
+		if err := tx.QueryRowContext(ctx, paymentQuery, payment.TransactionID, payment.Method, payment.Amount, payment.Tendered, payment.Change, payment.Reference).Scan(&payment.ID); err != nil {
+			return model.Transaction{}, fmt.Errorf("failed to insert payment: %w", err)
 		}
 	}
 
@@ -58,21 +146,912 @@ func (r *transactionRepository) CreateTransaction(ctx context.Context, transacti
 	return transaction, nil
 }
 
+func insertAppliedDiscount(ctx context.Context, tx *sql.Tx, discount *model.AppliedDiscount) error {
+	query := `INSERT INTO transaction_discounts (transaction_id, transaction_detail_id, promotion_id, promotion_name, amount) VALUES ($1, NULLIF($2, 0), $3, $4, $5) RETURNING id`
+	if err := tx.QueryRowContext(ctx, query, discount.TransactionID, discount.TransactionDetailID, discount.PromotionID, discount.PromotionName, discount.Amount).Scan(&discount.ID); err != nil {
+		return fmt.Errorf("failed to insert discount: %w", err)
+	}
+	return nil
+}
+
+// stockDraw is an amount of one product's stock a sale line takes.
+type stockDraw struct {
+	productID int
+	quantity  model.Quantity
+}
+
+// loadStockDraws returns what each detail takes from stock: its own product
+// in base units, or for a composite product each of its components.
+func loadStockDraws(ctx context.Context, tx *sql.Tx, details []model.TransactionDetail) ([][]stockDraw, error) {
+	var productIDs []int
+	for _, detail := range details {
+		productIDs = append(productIDs, detail.ProductID)
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT product_id, component_id, quantity FROM product_components WHERE product_id = ANY($1) ORDER BY product_id, component_id`, pq.Array(productIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load components: %w", err)
+	}
+	defer rows.Close()
+	components := map[int][]stockDraw{}
+	for rows.Next() {
+		var productID int
+		var c stockDraw
+		if err := rows.Scan(&productID, &c.productID, &c.quantity); err != nil {
+			return nil, err
+		}
+		components[productID] = append(components[productID], c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return stockDraws(details, components), nil
+}
+
+// stockDraws expands each detail into the stock it takes, given the
+// components per unit of every composite product in the sale.
+func stockDraws(details []model.TransactionDetail, components map[int][]stockDraw) [][]stockDraw {
+	draws := make([][]stockDraw, len(details))
+	for i, detail := range details {
+		perUnit, ok := components[detail.ProductID]
+		if !ok {
+			draws[i] = []stockDraw{{productID: detail.ProductID, quantity: detail.BaseQuantity}}
+			continue
+		}
+		for _, c := range perUnit {
+			draws[i] = append(draws[i], stockDraw{productID: c.productID, quantity: detail.BaseQuantity.Mul(c.quantity)})
+		}
+	}
+	return draws
+}
+
+// lockAndCheckStock takes a row lock on every product the sale draws from and
+// verifies the requested quantities, in each product's own unit, are still
+// available. Rows are locked in ID order so two checkouts touching the same
+// products cannot deadlock each other.
+func lockAndCheckStock(ctx context.Context, tx *sql.Tx, draws [][]stockDraw) error {
+	requested := map[int]model.Quantity{}
+	var productIDs []int
+	for _, line := range draws {
+		for _, draw := range line {
+			if _, ok := requested[draw.productID]; !ok {
+				productIDs = append(productIDs, draw.productID)
+			}
+			requested[draw.productID] += draw.quantity
+		}
+	}
+	sort.Ints(productIDs)
+
+	query := `SELECT name, stock FROM products WHERE id = $1 FOR UPDATE`
+	for _, id := range productIDs {
+		var name string
+		var stock model.Quantity
+		if err := tx.QueryRowContext(ctx, query, id).Scan(&name, &stock); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("product not found: %d", id)
+			}
+			return fmt.Errorf("failed to lock product: %w", err)
+		}
+		if stock < requested[id] {
+			return &model.InsufficientStockError{ProductID: id, ProductName: name, Requested: requested[id], Available: stock}
+		}
+	}
+	return nil
+}
+
+// refundedLines joins each detail line d to the quantity and amount refunded
+// of it so far, as ri.quantity and ri.amount.
+const refundedLines = `LEFT JOIN (
+			SELECT transaction_detail_id, SUM(quantity) AS quantity, SUM(amount) AS amount
+			FROM refund_items
+			GROUP BY transaction_detail_id
+		) ri ON ri.transaction_detail_id = d.id`
+
+// keptShare is the part of detail line d that has not been refunded, for use
+// with refundedLines.
+const keptShare = `(d.quantity - COALESCE(ri.quantity, 0)) / d.quantity`
+
 func (r *transactionRepository) GetDailyReport(ctx context.Context, date time.Time) (model.DailyReport, error) {
 	query := `
 		SELECT 
 			COALESCE(SUM(total_amount), 0) as total_sales,
-			COUNT(id) as transaction_count
+			COUNT(id) FILTER (WHERE status <> 'voided') as transaction_count
 		FROM transactions 
-		WHERE DATE(created_at) = $1
+		WHERE created_at >= $1 AND created_at < $2
 	`
+	// Tax and service charge are what the day's sales still owe after
+	// refunds, worked out the same way as GetTaxReport
+	chargesQuery := `
+		SELECT
+			COALESCE(SUM(ROUND(d.tax_amount * ` + keptShare + `, 2)), 0),
+			COALESCE(SUM(ROUND(d.service_charge * ` + keptShare + `, 2)), 0)
+		FROM transaction_details d
+		JOIN transactions t ON t.id = d.transaction_id
+		` + refundedLines + `
+		WHERE t.created_at >= $1 AND t.created_at < $2 AND t.status <> 'voided'
+	`
+	refundsQuery := `SELECT COALESCE(SUM(total_amount), 0) FROM refunds WHERE created_at >= $1 AND created_at < $2`
 
 	var report model.DailyReport
 	report.Date = date.Format("2006-01-02")
+	start, end := r.calendar.Start(date), r.calendar.Start(date.AddDate(0, 0, 1))
 
-	if err := r.db.QueryRowContext(ctx, query, report.Date).Scan(&report.TotalSales, &report.TransactionCount); err != nil {
+	if err := r.db.QueryRowContext(ctx, query, start, end).Scan(&report.TotalSales, &report.TransactionCount); err != nil {
+		return model.DailyReport{}, err
+	}
+	if err := r.db.QueryRowContext(ctx, chargesQuery, start, end).Scan(&report.TotalTax, &report.ServiceCharge); err != nil {
+		return model.DailyReport{}, err
+	}
+	if err := r.db.QueryRowContext(ctx, refundsQuery, start, end).Scan(&report.TotalRefunds); err != nil {
+		return model.DailyReport{}, err
+	}
+	report.NetSales = report.TotalSales - report.TotalRefunds
+
+	paymentsQuery := `
+		SELECT p.method, COALESCE(SUM(p.amount), 0), COUNT(p.id)
+		FROM payments p
+		JOIN transactions t ON t.id = p.transaction_id
+		WHERE t.created_at >= $1 AND t.created_at < $2 AND t.status <> 'voided'
+		GROUP BY p.method
+		ORDER BY p.method
+	`
+	rows, err := r.db.QueryContext(ctx, paymentsQuery, start, end)
+	if err != nil {
+		return model.DailyReport{}, err
+	}
+	defer rows.Close()
+
+	report.Payments = []model.PaymentMethodTotal{}
+	for rows.Next() {
+		var total model.PaymentMethodTotal
+		if err := rows.Scan(&total.Method, &total.Total, &total.Count); err != nil {
+			return model.DailyReport{}, err
+		}
+		report.Payments = append(report.Payments, total)
+	}
+	if err := rows.Err(); err != nil {
 		return model.DailyReport{}, err
 	}
 
 	return report, nil
 }
+
+func (r *transactionRepository) GetAll(ctx context.Context, filter model.TransactionFilter) ([]model.Transaction, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.From != nil {
+		args = append(args, r.calendar.Start(*filter.From))
+		conditions = append(conditions, fmt.Sprintf("t.created_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, r.calendar.Start(*filter.To))
+		conditions = append(conditions, fmt.Sprintf("t.created_at < $%d", len(args)))
+	}
+	if filter.MinAmount != nil {
+		args = append(args, *filter.MinAmount)
+		conditions = append(conditions, fmt.Sprintf("t.total_amount >= $%d", len(args)))
+	}
+	if filter.MaxAmount != nil {
+		args = append(args, *filter.MaxAmount)
+		conditions = append(conditions, fmt.Sprintf("t.total_amount <= $%d", len(args)))
+	}
+	if filter.ProductID != 0 {
+		args = append(args, filter.ProductID)
+		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM transaction_details d WHERE d.transaction_id = t.id AND d.product_id = $%d)", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM transactions t ` + where
+	if err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.Limit, (filter.Page-1)*filter.Limit)
+	query := fmt.Sprintf(`SELECT t.id, t.shift_id, t.customer_id, t.price_level, t.subtotal, t.tax_amount, t.service_charge, t.total_amount, t.discount_amount, t.status, t.created_at FROM transactions t %s ORDER BY t.created_at DESC, t.id DESC LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args))
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	transactions := []model.Transaction{}
+	for rows.Next() {
+		var t model.Transaction
+		if err := rows.Scan(&t.ID, &t.ShiftID, &t.CustomerID, &t.PriceLevel, &t.Subtotal, &t.TaxAmount, &t.ServiceCharge, &t.TotalAmount, &t.DiscountAmount, &t.Status, &t.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		transactions = append(transactions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return transactions, total, nil
+}
+
+func (r *transactionRepository) GetByID(ctx context.Context, id int) (model.Transaction, error) {
+	query := `SELECT id, shift_id, customer_id, price_level, subtotal, tax_amount, service_charge, total_amount, discount_amount, status, created_at FROM transactions WHERE id = $1`
+	var t model.Transaction
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&t.ID, &t.ShiftID, &t.CustomerID, &t.PriceLevel, &t.Subtotal, &t.TaxAmount, &t.ServiceCharge, &t.TotalAmount, &t.DiscountAmount, &t.Status, &t.CreatedAt); err != nil {
+		return model.Transaction{}, err
+	}
+
+	detailsQuery := `
+		SELECT d.id, d.transaction_id, COALESCE(d.product_id, 0), COALESCE(d.product_name, ''),
+			COALESCE(d.category_id, 0), COALESCE(d.category_name, ''), d.unit, d.quantity, d.base_quantity, COALESCE(d.unit_price, 0),
+			COALESCE(d.price_tier_id, 0), COALESCE(d.price_level, ''), d.tier_min_quantity, d.discount_amount, d.subtotal,
+			d.tax_rate, d.tax_inclusive, d.tax_amount, d.service_charge, d.unit_cost
+		FROM transaction_details d
+		WHERE d.transaction_id = $1
+		ORDER BY d.id
+	`
+	rows, err := r.db.QueryContext(ctx, detailsQuery, id)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d model.TransactionDetail
+		if err := rows.Scan(&d.ID, &d.TransactionID, &d.ProductID, &d.ProductName,
+			&d.CategoryID, &d.CategoryName, &d.Unit, &d.Quantity, &d.BaseQuantity, &d.UnitPrice,
+			&d.PriceTierID, &d.PriceLevel, &d.TierMinQuantity, &d.DiscountAmount, &d.Subtotal,
+			&d.TaxRate, &d.TaxInclusive, &d.TaxAmount, &d.ServiceCharge, &d.UnitCost); err != nil {
+			return model.Transaction{}, err
+		}
+		t.Details = append(t.Details, d)
+	}
+	if err := rows.Err(); err != nil {
+		return model.Transaction{}, err
+	}
+
+	discounts, err := r.getDiscounts(ctx, id)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	for _, discount := range discounts {
+		if discount.TransactionDetailID == 0 {
+			t.Discounts = append(t.Discounts, discount)
+			continue
+		}
+		for i := range t.Details {
+			if t.Details[i].ID == discount.TransactionDetailID {
+				t.Details[i].Discounts = append(t.Details[i].Discounts, discount)
+			}
+		}
+	}
+
+	payments, err := r.getPayments(ctx, id)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	t.Payments = payments
+	for _, p := range payments {
+		t.Change += p.Change
+	}
+
+	refunds, err := r.GetRefunds(ctx, id)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	t.Refunds = refunds
+	return t, nil
+}
+
+func (r *transactionRepository) getDiscounts(ctx context.Context, transactionID int) ([]model.AppliedDiscount, error) {
+	query := `SELECT id, transaction_id, COALESCE(transaction_detail_id, 0), COALESCE(promotion_id, 0), promotion_name, amount FROM transaction_discounts WHERE transaction_id = $1 ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var discounts []model.AppliedDiscount
+	for rows.Next() {
+		var d model.AppliedDiscount
+		if err := rows.Scan(&d.ID, &d.TransactionID, &d.TransactionDetailID, &d.PromotionID, &d.PromotionName, &d.Amount); err != nil {
+			return nil, err
+		}
+		discounts = append(discounts, d)
+	}
+	return discounts, rows.Err()
+}
+
+func (r *transactionRepository) getPayments(ctx context.Context, transactionID int) ([]model.Payment, error) {
+	query := `SELECT id, transaction_id, method, amount, tendered, change_amount, COALESCE(reference, '') FROM payments WHERE transaction_id = $1 ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []model.Payment
+	for rows.Next() {
+		var p model.Payment
+		if err := rows.Scan(&p.ID, &p.TransactionID, &p.Method, &p.Amount, &p.Tendered, &p.Change, &p.Reference); err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+// refundableDetail tracks how much of a sold line is still available to refund.
+// total is what the customer paid for the line, including exclusive tax and
+// its share of the service charge. Quantities are in the unit the line was
+// sold in; baseQuantity is the same line in the product's own unit.
+type refundableDetail struct {
+	productID      int
+	unit           string
+	quantity       model.Quantity
+	baseQuantity   model.Quantity
+	total          model.Money
+	refundedQty    model.Quantity
+	refundedAmount model.Money
+}
+
+func (r *transactionRepository) CreateRefund(ctx context.Context, transactionID int, refundType string, request model.RefundRequest) (model.Refund, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Refund{}, err
+	}
+	defer tx.Rollback()
+
+	// Lock the transaction so concurrent refunds cannot over-refund a line
+	var status string
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM transactions WHERE id = $1 FOR UPDATE`, transactionID).Scan(&status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Refund{}, fmt.Errorf("transaction not found: %d", transactionID)
+		}
+		return model.Refund{}, err
+	}
+	if status == model.TransactionStatusVoided || status == model.TransactionStatusRefunded {
+		return model.Refund{}, fmt.Errorf("transaction is already %s", status)
+	}
+	if refundType == model.RefundTypeVoid && status != model.TransactionStatusCompleted {
+		return model.Refund{}, errors.New("only transactions without refunds can be voided")
+	}
+	if request.ShiftID != 0 {
+		if err := lockOpenShift(ctx, tx, request.ShiftID, "FOR SHARE"); err != nil {
+			return model.Refund{}, err
+		}
+	}
+
+	detailsQuery := `
+		SELECT d.id, COALESCE(d.product_id, 0), d.unit, d.quantity, d.base_quantity,
+			d.subtotal + CASE WHEN d.tax_inclusive THEN 0 ELSE d.tax_amount END + d.service_charge,
+			COALESCE(SUM(ri.quantity), 0), COALESCE(SUM(ri.amount), 0)
+		FROM transaction_details d
+		LEFT JOIN refund_items ri ON ri.transaction_detail_id = d.id
+		WHERE d.transaction_id = $1
+		GROUP BY d.id
+		ORDER BY d.id
+	`
+	rows, err := tx.QueryContext(ctx, detailsQuery, transactionID)
+	if err != nil {
+		return model.Refund{}, err
+	}
+	details := map[int]*refundableDetail{}
+	var detailIDs []int
+	for rows.Next() {
+		var id int
+		d := &refundableDetail{}
+		if err := rows.Scan(&id, &d.productID, &d.unit, &d.quantity, &d.baseQuantity, &d.total, &d.refundedQty, &d.refundedAmount); err != nil {
+			rows.Close()
+			return model.Refund{}, err
+		}
+		details[id] = d
+		detailIDs = append(detailIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return model.Refund{}, err
+	}
+
+	items := request.Items
+	if len(items) == 0 {
+		for _, id := range detailIDs {
+			if remaining := details[id].quantity - details[id].refundedQty; remaining > 0 {
+				items = append(items, model.RefundRequestItem{TransactionDetailID: id, Quantity: remaining})
+			}
+		}
+	}
+	if len(items) == 0 {
+		return model.Refund{}, errors.New("nothing left to refund")
+	}
+
+	method, err := refundMethod(ctx, tx, transactionID, request.Method)
+	if err != nil {
+		return model.Refund{}, err
+	}
+
+	refund := model.Refund{
+		TransactionID: transactionID,
+		ShiftID:       nullableID(request.ShiftID),
+		Type:          refundType,
+		Method:        method,
+		ReasonCode:    request.ReasonCode,
+		Note:          request.Note,
+	}
+	components, err := loadDetailComponents(ctx, tx, transactionID)
+	if err != nil {
+		return model.Refund{}, err
+	}
+
+	var restocks [][]stockDraw
+	for _, item := range items {
+		d, ok := details[item.TransactionDetailID]
+		if !ok {
+			return model.Refund{}, fmt.Errorf("detail %d does not belong to transaction %d", item.TransactionDetailID, transactionID)
+		}
+		remaining := d.quantity - d.refundedQty
+		if item.Quantity > remaining {
+			return model.Refund{}, fmt.Errorf("cannot refund %s of detail %d, only %s remaining", item.Quantity, item.TransactionDetailID, remaining)
+		}
+		if !model.IsFractionalUnit(d.unit) && !item.Quantity.IsWhole() {
+			return model.Refund{}, fmt.Errorf("detail %d is sold in whole units", item.TransactionDetailID)
+		}
+
+		// The last units of a line take whatever amount is left so that
+		// rounding never leaves a few cents unrefunded.
+		amount := d.total.MulDiv(int(item.Quantity), int(d.quantity))
+		if item.Quantity == remaining {
+			amount = d.total - d.refundedAmount
+		}
+		d.refundedQty += item.Quantity
+		d.refundedAmount += amount
+
+		// Packs go back on the shelf as the units they hold, and a composite
+		// product as its share of the components it took
+		restock := item.Quantity.MulDiv(d.baseQuantity, d.quantity)
+		var draws []stockDraw
+		if drawn, ok := components[item.TransactionDetailID]; ok {
+			for _, c := range drawn {
+				draws = append(draws, stockDraw{productID: c.productID, quantity: c.quantity.MulDiv(item.Quantity, d.quantity)})
+			}
+		} else if d.productID != 0 {
+			draws = []stockDraw{{productID: d.productID, quantity: restock}}
+		}
+		restocks = append(restocks, draws)
+
+		refund.TotalAmount += amount
+		refund.Items = append(refund.Items, model.RefundItem{
+			TransactionDetailID: item.TransactionDetailID,
+			ProductID:           d.productID,
+			Quantity:            item.Quantity,
+			Amount:              amount,
+		})
+	}
+
+	query := `INSERT INTO refunds (transaction_id, shift_id, type, method, reason_code, note, total_amount) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
+	if err := tx.QueryRowContext(ctx, query, refund.TransactionID, refund.ShiftID, refund.Type, refund.Method, refund.ReasonCode, refund.Note, refund.TotalAmount).Scan(&refund.ID, &refund.CreatedAt); err != nil {
+		return model.Refund{}, fmt.Errorf("failed to insert refund: %w", err)
+	}
+
+	itemQuery := `INSERT INTO refund_items (refund_id, transaction_detail_id, product_id, quantity, amount) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	for i := range refund.Items {
+		item := &refund.Items[i]
+		item.RefundID = refund.ID
+		if err := tx.QueryRowContext(ctx, itemQuery, item.RefundID, item.TransactionDetailID, nullableID(item.ProductID), item.Quantity, item.Amount).Scan(&item.ID); err != nil {
+			return model.Refund{}, fmt.Errorf("failed to insert refund item: %w", err)
+		}
+		// Products deleted since the sale have nothing to restock
+		for _, draw := range restocks[i] {
+			restock := model.StockMovement{
+				ProductID:     draw.productID,
+				Type:          model.StockMovementRefund,
+				Quantity:      draw.quantity,
+				ReferenceType: model.StockReferenceRefund,
+				ReferenceID:   refund.ID,
+			}
+			if err := applyStockMovement(ctx, tx, &restock); err != nil {
+				return model.Refund{}, fmt.Errorf("failed to restore stock: %w", err)
+			}
+		}
+	}
+
+	newStatus := model.TransactionStatusRefunded
+	if refundType == model.RefundTypeVoid {
+		newStatus = model.TransactionStatusVoided
+	}
+	for _, d := range details {
+		if d.refundedQty < d.quantity {
+			newStatus = model.TransactionStatusPartiallyRefunded
+			break
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE transactions SET status = $1 WHERE id = $2`, newStatus, transactionID); err != nil {
+		return model.Refund{}, fmt.Errorf("failed to update transaction status: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.Refund{}, fmt.Errorf("failed to commit refund: %w", err)
+	}
+	return refund, nil
+}
+
+// refundMethod returns how a refund of the transaction is paid out: the
+// requested method, or else the one method the sale was paid with. A sale
+// without payments is refunded in cash.
+func refundMethod(ctx context.Context, tx *sql.Tx, transactionID int, requested string) (string, error) {
+	if requested != "" {
+		return requested, nil
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT method FROM payments WHERE transaction_id = $1 ORDER BY method`, transactionID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var methods []string
+	for rows.Next() {
+		var method string
+		if err := rows.Scan(&method); err != nil {
+			return "", err
+		}
+		methods = append(methods, method)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	switch len(methods) {
+	case 0:
+		return model.PaymentMethodCash, nil
+	case 1:
+		return methods[0], nil
+	default:
+		return "", fmt.Errorf("the sale was paid by %s; choose a refund method", strings.Join(methods, " and "))
+	}
+}
+
+// loadDetailComponents returns the component stock each composite line of
+// the transaction took, by detail ID. Components deleted since are left out.
+func loadDetailComponents(ctx context.Context, tx *sql.Tx, transactionID int) (map[int][]stockDraw, error) {
+	query := `
+		SELECT c.transaction_detail_id, COALESCE(c.product_id, 0), c.quantity
+		FROM transaction_detail_components c
+		JOIN transaction_details d ON d.id = c.transaction_detail_id
+		WHERE d.transaction_id = $1
+		ORDER BY c.transaction_detail_id, c.product_id
+	`
+	rows, err := tx.QueryContext(ctx, query, transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load detail components: %w", err)
+	}
+	defer rows.Close()
+	components := map[int][]stockDraw{}
+	for rows.Next() {
+		var detailID int
+		var c stockDraw
+		if err := rows.Scan(&detailID, &c.productID, &c.quantity); err != nil {
+			return nil, err
+		}
+		if c.productID != 0 {
+			components[detailID] = append(components[detailID], c)
+		} else if _, ok := components[detailID]; !ok {
+			components[detailID] = nil
+		}
+	}
+	return components, rows.Err()
+}
+
+func (r *transactionRepository) GetRefunds(ctx context.Context, transactionID int) ([]model.Refund, error) {
+	query := `SELECT id, transaction_id, shift_id, type, method, reason_code, COALESCE(note, ''), total_amount, created_at FROM refunds WHERE transaction_id = $1 ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []model.Refund
+	index := map[int]int{}
+	for rows.Next() {
+		var rf model.Refund
+		if err := rows.Scan(&rf.ID, &rf.TransactionID, &rf.ShiftID, &rf.Type, &rf.Method, &rf.ReasonCode, &rf.Note, &rf.TotalAmount, &rf.CreatedAt); err != nil {
+			return nil, err
+		}
+		index[rf.ID] = len(refunds)
+		refunds = append(refunds, rf)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(refunds) == 0 {
+		return refunds, nil
+	}
+
+	itemsQuery := `
+		SELECT ri.id, ri.refund_id, ri.transaction_detail_id, COALESCE(ri.product_id, 0), ri.quantity, ri.amount
+		FROM refund_items ri
+		JOIN refunds rf ON rf.id = ri.refund_id
+		WHERE rf.transaction_id = $1
+		ORDER BY ri.id
+	`
+	itemRows, err := r.db.QueryContext(ctx, itemsQuery, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var item model.RefundItem
+		if err := itemRows.Scan(&item.ID, &item.RefundID, &item.TransactionDetailID, &item.ProductID, &item.Quantity, &item.Amount); err != nil {
+			return nil, err
+		}
+		rf := &refunds[index[item.RefundID]]
+		rf.Items = append(rf.Items, item)
+	}
+	return refunds, itemRows.Err()
+}
+
+// GetTaxReport returns the tax collected per business day for the dates
+// from <= date < to, net of what has since been refunded of each day's
+// sales. Days without taxed sales are omitted.
+func (r *transactionRepository) GetTaxReport(ctx context.Context, from, to time.Time) ([]model.TaxReport, error) {
+	day := businessDate("t.created_at", 3, 4)
+	query := `
+		SELECT
+			TO_CHAR(` + day + `, 'YYYY-MM-DD') as date,
+			COALESCE(SUM(ROUND((d.subtotal - CASE WHEN d.tax_inclusive THEN d.tax_amount ELSE 0 END) * ` + keptShare + `, 2)), 0) as taxable_amount,
+			COALESCE(SUM(ROUND(d.tax_amount * ` + keptShare + `, 2)), 0) as tax_amount
+		FROM transaction_details d
+		JOIN transactions t ON t.id = d.transaction_id
+		` + refundedLines + `
+		WHERE t.created_at >= $1 AND t.created_at < $2 AND d.tax_rate > 0 AND t.status <> 'voided'
+		GROUP BY 1
+		ORDER BY 1
+	`
+	rows, err := r.db.QueryContext(ctx, query, r.calendar.Start(from), r.calendar.Start(to), r.calendar.TimezoneName(), r.calendar.CutoffInterval())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []model.TaxReport{}
+	for rows.Next() {
+		var report model.TaxReport
+		if err := rows.Scan(&report.Date, &report.TaxableAmount, &report.TaxAmount); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// GetSalesByPeriod aggregates sales for the business dates from <= date < to
+// into day, week or month buckets. Every bucket in the range is returned,
+// including those without sales, and the first and last buckets are clipped
+// to the range. Refunds count against the bucket of the business date they
+// were made on, as in the daily report; refunds of voided sales are left out
+// along with the sales.
+func (r *transactionRepository) GetSalesByPeriod(ctx context.Context, from, to time.Time, groupBy string) ([]model.SalesPeriod, error) {
+	query := `
+		WITH periods AS (
+			SELECT generate_series(
+				date_trunc($3::text, $1::date::timestamp),
+				($2::date - 1)::timestamp,
+				('1 ' || $3::text)::interval
+			)::date AS period_start
+		),
+		sales AS (
+			SELECT id, total_amount, ` + businessDate("created_at", 4, 5) + ` AS business_date
+			FROM transactions
+			WHERE created_at >= $6 AND created_at < $7 AND status <> 'voided'
+		),
+		refunded AS (
+			SELECT rf.total_amount, ` + businessDate("rf.created_at", 4, 5) + ` AS business_date
+			FROM refunds rf
+			JOIN transactions t ON t.id = rf.transaction_id
+			WHERE rf.created_at >= $6 AND rf.created_at < $7 AND t.status <> 'voided'
+		)
+		SELECT
+			p.period_start,
+			COALESCE(SUM(s.total_amount), 0) as total_sales,
+			COUNT(s.id) as transaction_count,
+			(
+				SELECT COALESCE(SUM(rf.total_amount), 0)
+				FROM refunded rf
+				WHERE rf.business_date >= p.period_start
+					AND rf.business_date < (p.period_start + ('1 ' || $3::text)::interval)::date
+			) as total_refunds
+		FROM periods p
+		LEFT JOIN sales s
+			ON s.business_date >= p.period_start
+			AND s.business_date < (p.period_start + ('1 ' || $3::text)::interval)::date
+		GROUP BY p.period_start
+		ORDER BY p.period_start
+	`
+	rows, err := r.db.QueryContext(ctx, query,
+		from.Format("2006-01-02"), to.Format("2006-01-02"), groupBy,
+		r.calendar.TimezoneName(), r.calendar.CutoffInterval(),
+		r.calendar.Start(from), r.calendar.Start(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := []model.SalesPeriod{}
+	for rows.Next() {
+		var start time.Time
+		var period model.SalesPeriod
+		if err := rows.Scan(&start, &period.TotalSales, &period.TransactionCount, &period.TotalRefunds); err != nil {
+			return nil, err
+		}
+
+		var end time.Time
+		switch groupBy {
+		case model.GroupByWeek:
+			end = start.AddDate(0, 0, 7)
+		case model.GroupByMonth:
+			end = start.AddDate(0, 1, 0)
+		default:
+			end = start.AddDate(0, 0, 1)
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+
+		period.PeriodStart = start.Format("2006-01-02")
+		period.PeriodEnd = end.AddDate(0, 0, -1).Format("2006-01-02")
+		period.NetSales = period.TotalSales - period.TotalRefunds
+		if period.TransactionCount > 0 {
+			period.AverageBasket = period.TotalSales.MulDiv(1, period.TransactionCount)
+		}
+		periods = append(periods, period)
+	}
+	return periods, rows.Err()
+}
+
+// salesLinesQuery selects the detail lines of non-voided transactions made
+// between $1 and $2, with quantities in each product's own unit. Quantity and
+// subtotal are what is left of each line after refunds.
+const salesLinesQuery = `
+	SELECT d.id, d.product_id, d.product_name, d.category_id,
+		ROUND(d.base_quantity * ` + keptShare + `, 3) AS quantity,
+		ROUND(d.subtotal * ` + keptShare + `, 2) AS subtotal
+	FROM transaction_details d
+	JOIN transactions t ON t.id = d.transaction_id
+	` + refundedLines + `
+	WHERE t.created_at >= $1 AND t.created_at < $2 AND t.status <> 'voided'
+`
+
+// GetProductSales returns quantity and revenue for every product over the
+// business dates from <= date < to, net of what has since been refunded of
+// those sales, including products that did not sell.
+// Products are named as they were last sold, and products deleted since are
+// listed with ID 0 under the name they were sold under. Each item carries
+// the product's unit and the size of each of its packs; the quantity in packs
+// is left for the caller to work out. Variants are listed separately with
+// their ParentID set.
+func (r *transactionRepository) GetProductSales(ctx context.Context, from, to time.Time) ([]model.ItemSales, error) {
+	query := `
+		WITH sold AS (
+			SELECT s.product_id, (ARRAY_AGG(s.product_name ORDER BY s.id DESC))[1] AS name, SUM(s.quantity) AS quantity, SUM(s.subtotal) AS revenue
+			FROM (` + salesLinesQuery + `) s
+			GROUP BY s.product_id, CASE WHEN s.product_id IS NULL THEN s.product_name END
+		)
+		SELECT COALESCE(p.id, 0), COALESCE(p.parent_id, 0), COALESCE(sold.name, p.name, ''), COALESCE(sold.quantity, 0), COALESCE(sold.revenue, 0), COALESCE(p.unit, ''),
+			COALESCE((
+				SELECT json_agg(json_build_object('unit', c.unit, 'factor', c.factor) ORDER BY c.factor, c.id)
+				FROM product_unit_conversions c WHERE c.product_id = p.id
+			), '[]')
+		FROM products p
+		FULL JOIN sold ON sold.product_id = p.id
+		ORDER BY COALESCE(p.id, 0), 3
+	`
+	rows, err := r.db.QueryContext(ctx, query, r.calendar.Start(from), r.calendar.Start(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []model.ItemSales{}
+	for rows.Next() {
+		var item model.ItemSales
+		var packs []byte
+		if err := rows.Scan(&item.ID, &item.ParentID, &item.Name, &item.Quantity, &item.Revenue, &item.Unit, &packs); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(packs, &item.Packs); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetCategorySales is GetProductSales rolled up by the category each line was
+// sold under.
+func (r *transactionRepository) GetCategorySales(ctx context.Context, from, to time.Time) ([]model.ItemSales, error) {
+	query := `
+		SELECT c.id, c.name, COALESCE(SUM(s.quantity), 0), COALESCE(SUM(s.subtotal), 0)
+		FROM categories c
+		LEFT JOIN (` + salesLinesQuery + `) s ON s.category_id = c.id
+		GROUP BY c.id, c.name
+		ORDER BY c.id
+	`
+	return r.itemSales(ctx, query, from, to)
+}
+
+func (r *transactionRepository) itemSales(ctx context.Context, query string, from, to time.Time) ([]model.ItemSales, error) {
+	rows, err := r.db.QueryContext(ctx, query, r.calendar.Start(from), r.calendar.Start(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []model.ItemSales{}
+	for rows.Next() {
+		var item model.ItemSales
+		if err := rows.Scan(&item.ID, &item.Name, &item.Quantity, &item.Revenue); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetProfit returns revenue and cost per product, category or business day
+// for the business dates from <= date < to, net of what has since been
+// refunded of those sales. Groups without sales are omitted. Variants are
+// counted under their parent product.
+func (r *transactionRepository) GetProfit(ctx context.Context, from, to time.Time, groupBy string) ([]model.ProfitRow, error) {
+	// Products and categories are grouped by ID and labelled with the latest
+	// name they were sold under, or the parent's current name for variants
+	var id, label, group string
+	switch groupBy {
+	case model.GroupByProduct:
+		id, label, group = "COALESCE(p.parent_id, d.product_id, 0)", "COALESCE(MAX(parent.name), MAX(d.product_name), '')", "1"
+	case model.GroupByCategory:
+		id, label, group = "COALESCE(d.category_id, 0)", "COALESCE(MAX(d.category_name), '')", "1"
+	case model.GroupByDay:
+		id, label, group = "0", "TO_CHAR("+businessDate("t.created_at", 3, 4)+", 'YYYY-MM-DD')", "1, 2"
+	default:
+		return nil, fmt.Errorf("unsupported profit grouping: %s", groupBy)
+	}
+
+	query := `
+		SELECT ` + id + `, ` + label + `,
+			SUM(ROUND(d.base_quantity * ` + keptShare + `, 3)),
+			SUM(ROUND((d.subtotal - CASE WHEN d.tax_inclusive THEN d.tax_amount ELSE 0 END) * ` + keptShare + `, 2)),
+			SUM(ROUND(d.unit_cost * (d.quantity - COALESCE(ri.quantity, 0)), 2))
+		FROM transaction_details d
+		JOIN transactions t ON t.id = d.transaction_id
+		` + refundedLines + `
+		LEFT JOIN products p ON p.id = d.product_id
+		LEFT JOIN products parent ON parent.id = p.parent_id
+		WHERE t.created_at >= $1 AND t.created_at < $2 AND t.status <> 'voided'
+		GROUP BY ` + group + `
+		ORDER BY ` + group
+	args := []interface{}{r.calendar.Start(from), r.calendar.Start(to)}
+	if groupBy == model.GroupByDay {
+		args = append(args, r.calendar.TimezoneName(), r.calendar.CutoffInterval())
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []model.ProfitRow{}
+	for rows.Next() {
+		var row model.ProfitRow
+		var name string
+		if err := rows.Scan(&row.ID, &name, &row.Quantity, &row.Revenue, &row.Cost); err != nil {
+			return nil, err
+		}
+		if groupBy == model.GroupByDay {
+			row.Date = name
+		} else {
+			row.Name = name
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
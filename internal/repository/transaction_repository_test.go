@@ -0,0 +1,342 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"kasir-api/internal/model"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// openTestDB connects to the database in TEST_DB_SOURCE. Tests that need a
+// real PostgreSQL instance are skipped when it is not set.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	source := os.Getenv("TEST_DB_SOURCE")
+	if source == "" {
+		t.Skip("TEST_DB_SOURCE not set, skipping database test")
+	}
+
+	db, err := sql.Open("postgres", source)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatalf("failed to ping database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestCreateTransactionConcurrentCheckoutsDoNotOversell(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	var categoryID, productID int
+	if err := db.QueryRow(`INSERT INTO categories (name) VALUES ('concurrency test') RETURNING id`).Scan(&categoryID); err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM categories WHERE id = $1`, categoryID) })
+
+	const stock = 10
+	const buyers = 50
+	if err := db.QueryRow(`INSERT INTO products (name, price, stock, category_id) VALUES ('last units', 1000, $1, $2) RETURNING id`, stock, categoryID).Scan(&productID); err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM transactions WHERE id IN (SELECT transaction_id FROM transaction_details WHERE product_id = $1)`, productID)
+	})
+
+	repo := NewTransactionRepository(db, model.BusinessCalendar{})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded, rejected := 0, 0
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			price := model.Money(100000) // Rp 1000.00
+			details := []model.TransactionDetail{{ProductID: productID, Quantity: model.WholeQuantity(1), BaseQuantity: model.WholeQuantity(1), Subtotal: price}}
+			_, err := repo.CreateTransaction(ctx, model.Transaction{TotalAmount: price}, details)
+
+			mu.Lock()
+			defer mu.Unlock()
+			var stockErr *model.InsufficientStockError
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.As(err, &stockErr):
+				rejected++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != stock {
+		t.Errorf("Expected %d successful checkouts, got %d", stock, succeeded)
+	}
+	if rejected != buyers-stock {
+		t.Errorf("Expected %d rejected checkouts, got %d", buyers-stock, rejected)
+	}
+
+	var remaining int
+	if err := db.QueryRow(`SELECT stock FROM products WHERE id = $1`, productID).Scan(&remaining); err != nil {
+		t.Fatalf("failed to read stock: %v", err)
+	}
+	if remaining != 0 {
+		t.Errorf("Expected stock 0, got %d", remaining)
+	}
+}
+
+func TestStockDraws(t *testing.T) {
+	// A hamper of 2 coffees and 500 g of sugar next to a loose coffee
+	details := []model.TransactionDetail{
+		{ProductID: 10, BaseQuantity: model.WholeQuantity(3)},
+		{ProductID: 1, BaseQuantity: model.WholeQuantity(1)},
+	}
+	components := map[int][]stockDraw{10: {
+		{productID: 1, quantity: model.WholeQuantity(2)},
+		{productID: 2, quantity: 500},
+	}}
+
+	draws := stockDraws(details, components)
+
+	if len(draws[0]) != 2 || draws[0][0] != (stockDraw{productID: 1, quantity: model.WholeQuantity(6)}) || draws[0][1] != (stockDraw{productID: 2, quantity: 1500}) {
+		t.Errorf("Unexpected draws for the hamper: %+v", draws[0])
+	}
+	if len(draws[1]) != 1 || draws[1][0] != (stockDraw{productID: 1, quantity: model.WholeQuantity(1)}) {
+		t.Errorf("Unexpected draws for the loose coffee: %+v", draws[1])
+	}
+}
+
+func TestCreateRefundRestocksPacksAsUnits(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	_, productID := createTestProduct(t, db, "cartons", 100)
+	repo := NewTransactionRepository(db, model.BusinessCalendar{})
+
+	// 3 cartons of 12
+	price := model.Money(1200000)
+	details := []model.TransactionDetail{{ProductID: productID, Unit: model.UnitPiece, Quantity: model.WholeQuantity(3), BaseQuantity: model.WholeQuantity(36), UnitPrice: price, Subtotal: price.Mul(3)}}
+	sale, err := repo.CreateTransaction(ctx, model.Transaction{Subtotal: price.Mul(3), TotalAmount: price.Mul(3)}, details)
+	if err != nil {
+		t.Fatalf("failed to sell: %v", err)
+	}
+	if stock := readStock(t, db, productID); stock != model.WholeQuantity(64) {
+		t.Fatalf("Expected stock 64 after the sale, got %v", stock)
+	}
+
+	request := model.RefundRequest{ReasonCode: "customer_return", Method: model.PaymentMethodCash, Items: []model.RefundRequestItem{
+		{TransactionDetailID: sale.Details[0].ID, Quantity: model.WholeQuantity(1)},
+	}}
+	refund, err := repo.CreateRefund(ctx, sale.ID, model.RefundTypeRefund, request)
+	if err != nil {
+		t.Fatalf("failed to refund: %v", err)
+	}
+	if refund.TotalAmount != price {
+		t.Errorf("Expected a refund of %s, got %s", price, refund.TotalAmount)
+	}
+	if stock := readStock(t, db, productID); stock != model.WholeQuantity(76) {
+		t.Errorf("Expected the carton back as 12 units (stock 76), got %v", stock)
+	}
+
+	// Refunding more than is left on the line is refused and restocks nothing
+	request.Items[0].Quantity = model.WholeQuantity(3)
+	if _, err := repo.CreateRefund(ctx, sale.ID, model.RefundTypeRefund, request); err == nil || !strings.Contains(err.Error(), "only 2 remaining") {
+		t.Errorf("Expected refunding 3 of the 2 cartons left to fail, got %v", err)
+	}
+	if stock := readStock(t, db, productID); stock != model.WholeQuantity(76) {
+		t.Errorf("Expected stock to stay 76, got %v", stock)
+	}
+}
+
+func TestCreateTransactionRecordsTierMinimumOnlyForTieredLines(t *testing.T) {
+	db := openTestDB(t)
+	_, productID := createTestProduct(t, db, "tiered", 10)
+	repo := NewTransactionRepository(db, model.BusinessCalendar{})
+
+	untiered := sellTestProduct(t, repo, productID, 1)
+
+	// A level's standing price is a tier with no minimum
+	var standing model.Quantity
+	price := model.Money(90000)
+	details := []model.TransactionDetail{{ProductID: productID, Unit: model.UnitPiece, Quantity: model.WholeQuantity(1), BaseQuantity: model.WholeQuantity(1),
+		UnitPrice: price, Subtotal: price, PriceLevel: model.PriceLevelMember, TierMinQuantity: &standing}}
+	tiered, err := repo.CreateTransaction(context.Background(), model.Transaction{Subtotal: price, TotalAmount: price, PriceLevel: model.PriceLevelMember}, details)
+	if err != nil {
+		t.Fatalf("failed to sell: %v", err)
+	}
+
+	for _, tt := range []struct {
+		detailID int
+		want     *model.Quantity
+	}{
+		{untiered.Details[0].ID, nil},
+		{tiered.Details[0].ID, &standing},
+	} {
+		var minQuantity *model.Quantity
+		if err := db.QueryRow(`SELECT tier_min_quantity FROM transaction_details WHERE id = $1`, tt.detailID).Scan(&minQuantity); err != nil {
+			t.Fatalf("failed to read detail: %v", err)
+		}
+		if (minQuantity == nil) != (tt.want == nil) || (minQuantity != nil && *minQuantity != *tt.want) {
+			t.Errorf("detail %d: expected tier minimum %v, got %v", tt.detailID, tt.want, minQuantity)
+		}
+	}
+}
+
+func TestCreateRefundRestocksCompositeComponents(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	_, coffeeID := createTestProduct(t, db, "kopi sachet", 10)
+	_, hamperID := createTestProduct(t, db, "parsel kopi", 0)
+	if _, err := db.Exec(`INSERT INTO product_components (product_id, component_id, quantity) VALUES ($1, $2, 2)`, hamperID, coffeeID); err != nil {
+		t.Fatalf("failed to add component: %v", err)
+	}
+	repo := NewTransactionRepository(db, model.BusinessCalendar{})
+
+	// 2 hampers of 2 sachets each
+	sale := sellTestProduct(t, repo, hamperID, 2)
+	if stock := readStock(t, db, coffeeID); stock != model.WholeQuantity(6) {
+		t.Fatalf("Expected 4 sachets drawn for the hampers (stock 6), got %v", stock)
+	}
+
+	request := model.RefundRequest{ReasonCode: "customer_return", Method: model.PaymentMethodCash, Items: []model.RefundRequestItem{
+		{TransactionDetailID: sale.Details[0].ID, Quantity: model.WholeQuantity(1)},
+	}}
+	if _, err := repo.CreateRefund(ctx, sale.ID, model.RefundTypeRefund, request); err != nil {
+		t.Fatalf("failed to refund: %v", err)
+	}
+	if stock := readStock(t, db, coffeeID); stock != model.WholeQuantity(8) {
+		t.Errorf("Expected the refunded hamper's 2 sachets back (stock 8), got %v", stock)
+	}
+
+	// The hamper itself keeps no stock to move
+	movements := NewStockMovementRepository(db)
+	adjustment := model.StockMovement{ProductID: hamperID, Type: model.StockMovementAdjustment, Quantity: model.WholeQuantity(1), Note: "found one"}
+	if _, err := movements.Record(ctx, adjustment); !errors.Is(err, model.ErrCompositeStock) {
+		t.Errorf("Expected ErrCompositeStock, got %v", err)
+	}
+}
+
+func TestGetSalesByPeriodNetsPartialRefunds(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	_, productID := createTestProduct(t, db, "teh botol", 10)
+	repo := NewTransactionRepository(db, model.BusinessCalendar{})
+	today := model.BusinessCalendar{}.DateOf(time.Now())
+	period := func() model.SalesPeriod {
+		t.Helper()
+		periods, err := repo.GetSalesByPeriod(ctx, today, today.AddDate(0, 0, 1), model.GroupByDay)
+		if err != nil || len(periods) != 1 {
+			t.Fatalf("failed to get sales: %v, %+v", err, periods)
+		}
+		return periods[0]
+	}
+	before := period()
+
+	sale := sellTestProduct(t, repo, productID, 2)
+	request := model.RefundRequest{ReasonCode: "customer_return", Method: model.PaymentMethodCash, Items: []model.RefundRequestItem{
+		{TransactionDetailID: sale.Details[0].ID, Quantity: model.WholeQuantity(1)},
+	}}
+	if _, err := repo.CreateRefund(ctx, sale.ID, model.RefundTypeRefund, request); err != nil {
+		t.Fatalf("failed to refund: %v", err)
+	}
+
+	after := period()
+	if got := after.TotalSales - before.TotalSales; got != 2000 {
+		t.Errorf("Expected the sale to add Rp 20.00 of sales, got %s", got)
+	}
+	if got := after.TotalRefunds - before.TotalRefunds; got != 1000 {
+		t.Errorf("Expected the refund to add Rp 10.00 of refunds, got %s", got)
+	}
+	if got := after.NetSales - before.NetSales; got != 1000 {
+		t.Errorf("Expected Rp 10.00 of net sales, got %s", got)
+	}
+}
+
+func TestGetProductSalesNetsRefunds(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	_, returnedID := createTestProduct(t, db, "sabun cair", 10)
+	_, keptID := createTestProduct(t, db, "sabun batang", 10)
+	repo := NewTransactionRepository(db, model.BusinessCalendar{})
+	today := model.BusinessCalendar{}.DateOf(time.Now())
+
+	sale := sellTestProduct(t, repo, returnedID, 3)
+	sellTestProduct(t, repo, keptID, 2)
+	request := model.RefundRequest{ReasonCode: "customer_return", Method: model.PaymentMethodCash, Items: []model.RefundRequestItem{
+		{TransactionDetailID: sale.Details[0].ID, Quantity: model.WholeQuantity(2)},
+	}}
+	if _, err := repo.CreateRefund(ctx, sale.ID, model.RefundTypeRefund, request); err != nil {
+		t.Fatalf("failed to refund: %v", err)
+	}
+
+	items, err := repo.GetProductSales(ctx, today, today.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("failed to get product sales: %v", err)
+	}
+	sales := map[int]model.ItemSales{}
+	for _, item := range items {
+		sales[item.ID] = item
+	}
+	returned, kept := sales[returnedID], sales[keptID]
+	if returned.Quantity != model.WholeQuantity(1) || returned.Revenue != 1000 {
+		t.Errorf("Expected 1 unit and Rp 10.00 left after the refund, got %s and %s", returned.Quantity, returned.Revenue)
+	}
+	if kept.Quantity != model.WholeQuantity(2) || kept.Revenue != 2000 {
+		t.Errorf("Expected 2 units and Rp 20.00 for the kept sale, got %s and %s", kept.Quantity, kept.Revenue)
+	}
+	// 3 sold would have outranked 2; after the refund it ranks below
+	if returned.Revenue >= kept.Revenue {
+		t.Errorf("Expected the refunded product to rank below the kept one, got %s and %s", returned.Revenue, kept.Revenue)
+	}
+}
+
+// createTestProduct adds a product with the given whole stock in a category
+// of its own. Its sales and the category are removed when the test ends.
+func createTestProduct(t *testing.T, db *sql.DB, name string, stock int) (categoryID, productID int) {
+	t.Helper()
+	if err := db.QueryRow(`INSERT INTO categories (name) VALUES ($1) RETURNING id`, name+" test").Scan(&categoryID); err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM categories WHERE id = $1`, categoryID) })
+
+	if err := db.QueryRow(`INSERT INTO products (name, price, stock, category_id) VALUES ($1, 1000, $2, $3) RETURNING id`, name, stock, categoryID).Scan(&productID); err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM transactions WHERE id IN (SELECT transaction_id FROM transaction_details WHERE product_id = $1)`, productID)
+	})
+	return categoryID, productID
+}
+
+// sellTestProduct checks out whole units of a product at Rp 10.00 each.
+func sellTestProduct(t *testing.T, repo TransactionRepository, productID, units int) model.Transaction {
+	t.Helper()
+	quantity := model.WholeQuantity(units)
+	subtotal := model.Money(1000).MulQuantity(quantity)
+	details := []model.TransactionDetail{{ProductID: productID, Unit: model.UnitPiece, Quantity: quantity, BaseQuantity: quantity, UnitPrice: 1000, Subtotal: subtotal}}
+	transaction, err := repo.CreateTransaction(context.Background(), model.Transaction{Subtotal: subtotal, TotalAmount: subtotal}, details)
+	if err != nil {
+		t.Fatalf("failed to sell: %v", err)
+	}
+	return transaction
+}
+
+func readStock(t *testing.T, db *sql.DB, productID int) model.Quantity {
+	t.Helper()
+	var stock model.Quantity
+	if err := db.QueryRow(`SELECT stock FROM products WHERE id = $1`, productID).Scan(&stock); err != nil {
+		t.Fatalf("failed to read stock: %v", err)
+	}
+	return stock
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"kasir-api/internal/model"
+	"kasir-api/internal/repository"
+)
+
+type CustomerService interface {
+	Create(customer model.Customer) (model.Customer, error)
+	GetAll() ([]model.Customer, error)
+	GetByID(id int) (model.Customer, error)
+	Update(id int, customer model.Customer) (model.Customer, error)
+	Delete(id int) error
+}
+
+type customerService struct {
+	repo repository.CustomerRepository
+}
+
+func NewCustomerService(repo repository.CustomerRepository) CustomerService {
+	return &customerService{repo: repo}
+}
+
+func (s *customerService) Create(customer model.Customer) (model.Customer, error) {
+	if err := validateCustomer(&customer); err != nil {
+		return model.Customer{}, err
+	}
+	return s.repo.Create(customer)
+}
+
+func (s *customerService) GetAll() ([]model.Customer, error) {
+	return s.repo.GetAll()
+}
+
+func (s *customerService) GetByID(id int) (model.Customer, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *customerService) Update(id int, customer model.Customer) (model.Customer, error) {
+	if err := validateCustomer(&customer); err != nil {
+		return model.Customer{}, err
+	}
+	return s.repo.Update(id, customer)
+}
+
+func (s *customerService) Delete(id int) error {
+	return s.repo.Delete(id)
+}
+
+// validateCustomer requires a name and defaults the price level to retail.
+func validateCustomer(customer *model.Customer) error {
+	if customer.Name == "" {
+		return errors.New("customer name is required")
+	}
+	if customer.PriceLevel == "" {
+		customer.PriceLevel = model.PriceLevelRetail
+	}
+	if !model.IsPriceLevel(customer.PriceLevel) {
+		return fmt.Errorf("invalid price level: %s", customer.PriceLevel)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"kasir-api/internal/model"
+	"kasir-api/internal/repository"
+	"time"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// idempotencyStaleAfter is how long a key stays reserved without a stored
+// response before a retry may take it over. It is well beyond the time any
+// request takes, so only a request lost to a crash or restart is taken over.
+const idempotencyStaleAfter = 5 * time.Minute
+
+type IdempotencyService interface {
+	Begin(ctx context.Context, key, requestHash string) (model.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, key, token string, statusCode int, body []byte) error
+	Release(ctx context.Context, key, token string) error
+}
+
+type idempotencyService struct {
+	repo repository.IdempotencyRepository
+}
+
+func NewIdempotencyService(repo repository.IdempotencyRepository) IdempotencyService {
+	return &idempotencyService{repo: repo}
+}
+
+// Begin claims key for the request identified by requestHash. It returns true
+// when the caller should process the request, or false with the stored record
+// when the original response should be replayed. A claimed record carries the
+// token to complete or release it with.
+func (s *idempotencyService) Begin(ctx context.Context, key, requestHash string) (model.IdempotencyRecord, bool, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		return model.IdempotencyRecord{}, false, errors.New("idempotency key is too long")
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return model.IdempotencyRecord{}, false, err
+	}
+	record, created, err := s.repo.Reserve(ctx, key, requestHash, hex.EncodeToString(token), idempotencyStaleAfter)
+	if err != nil {
+		return model.IdempotencyRecord{}, false, err
+	}
+	if created {
+		return record, true, nil
+	}
+	if record.RequestHash != requestHash {
+		return model.IdempotencyRecord{}, false, model.ErrIdempotencyKeyReused
+	}
+	if record.StatusCode == 0 {
+		return model.IdempotencyRecord{}, false, model.ErrIdempotencyInProgress
+	}
+	return record, false, nil
+}
+
+func (s *idempotencyService) Complete(ctx context.Context, key, token string, statusCode int, body []byte) error {
+	return s.repo.Complete(ctx, key, token, statusCode, body)
+}
+
+func (s *idempotencyService) Release(ctx context.Context, key, token string) error {
+	return s.repo.Release(ctx, key, token)
+}
@@ -2,8 +2,11 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"kasir-api/internal/model"
 	"kasir-api/internal/repository"
+	"slices"
+	"strings"
 )
 
 type ProductService interface {
@@ -13,6 +16,8 @@ type ProductService interface {
 	Update(id int, product model.Product) (model.Product, error)
 	Delete(id int) error
 	SearchByName(name string) ([]model.Product, error)
+	GetByBarcode(code string) (model.BarcodeMatch, error)
+	GetLowStock() ([]model.Product, error)
 }
 
 type productService struct {
@@ -24,6 +29,9 @@ func NewProductService(repo repository.ProductRepository) ProductService {
 }
 
 func (s *productService) Create(product model.Product) (model.Product, error) {
+	if err := s.normalizeVariant(0, &product); err != nil {
+		return model.Product{}, err
+	}
 	if product.Name == "" {
 		return model.Product{}, errors.New("product name is required")
 	}
@@ -33,6 +41,27 @@ func (s *productService) Create(product model.Product) (model.Product, error) {
 	if product.Price < 0 {
 		return model.Product{}, errors.New("price cannot be negative")
 	}
+	if product.CostPrice < 0 {
+		return model.Product{}, errors.New("cost price cannot be negative")
+	}
+	if product.ReorderPoint < 0 || product.ReorderQuantity < 0 {
+		return model.Product{}, errors.New("reorder point and reorder quantity cannot be negative")
+	}
+	if err := normalizeUnit(&product); err != nil {
+		return model.Product{}, err
+	}
+	if err := normalizeConversions(&product); err != nil {
+		return model.Product{}, err
+	}
+	if err := normalizePriceTiers(&product); err != nil {
+		return model.Product{}, err
+	}
+	if err := s.normalizeComponents(0, &product); err != nil {
+		return model.Product{}, err
+	}
+	if err := normalizeProductCodes(&product); err != nil {
+		return model.Product{}, err
+	}
 	return s.repo.Create(product)
 }
 
@@ -45,12 +74,36 @@ func (s *productService) GetByID(id int) (model.Product, error) {
 }
 
 func (s *productService) Update(id int, product model.Product) (model.Product, error) {
+	if err := s.normalizeVariant(id, &product); err != nil {
+		return model.Product{}, err
+	}
 	if product.Name == "" {
 		return model.Product{}, errors.New("product name is required")
 	}
 	if product.Price < 0 {
 		return model.Product{}, errors.New("price cannot be negative")
 	}
+	if product.CostPrice < 0 {
+		return model.Product{}, errors.New("cost price cannot be negative")
+	}
+	if product.ReorderPoint < 0 || product.ReorderQuantity < 0 {
+		return model.Product{}, errors.New("reorder point and reorder quantity cannot be negative")
+	}
+	if err := normalizeUnit(&product); err != nil {
+		return model.Product{}, err
+	}
+	if err := normalizeConversions(&product); err != nil {
+		return model.Product{}, err
+	}
+	if err := normalizePriceTiers(&product); err != nil {
+		return model.Product{}, err
+	}
+	if err := s.normalizeComponents(id, &product); err != nil {
+		return model.Product{}, err
+	}
+	if err := normalizeProductCodes(&product); err != nil {
+		return model.Product{}, err
+	}
 	return s.repo.Update(id, product)
 }
 
@@ -64,3 +117,403 @@ func (s *productService) SearchByName(name string) ([]model.Product, error) {
 	}
 	return s.repo.SearchByName(name)
 }
+
+func (s *productService) GetByBarcode(code string) (model.BarcodeMatch, error) {
+	normalized, err := model.NormalizeBarcode(code)
+	if err != nil {
+		return model.BarcodeMatch{}, err
+	}
+	return s.repo.GetByBarcode(normalized)
+}
+
+func (s *productService) GetLowStock() ([]model.Product, error) {
+	return s.repo.GetLowStock()
+}
+
+// normalizeVariant checks the variant fields of product, which is being
+// saved under id (zero when it is new). A variant is checked against its
+// parent and takes what it inherits from it; a parent is checked with
+// checkVariantParent, and its variant attributes cannot change once it has
+// variants.
+func (s *productService) normalizeVariant(id int, product *model.Product) error {
+	product.Variants = nil
+
+	var current model.Product
+	if id != 0 {
+		var err error
+		if current, err = s.repo.GetByID(id); err != nil {
+			return fmt.Errorf("product not found: %d", id)
+		}
+	}
+
+	if product.ParentID == nil {
+		if len(product.Attributes) > 0 || product.PriceOverride != nil {
+			return errors.New("only variants have attributes and a price override")
+		}
+		if err := normalizeVariantAttributes(product); err != nil {
+			return err
+		}
+		if len(current.Variants) > 0 && !slices.Equal(current.VariantAttributes, product.VariantAttributes) {
+			return errors.New("variant attributes cannot change while the product has variants")
+		}
+		if len(product.VariantAttributes) == 0 {
+			return nil
+		}
+		component := false
+		if id != 0 {
+			var err error
+			if component, err = s.repo.IsComponent(id); err != nil {
+				return err
+			}
+		}
+		return checkVariantParent(product, current, component)
+	}
+
+	if *product.ParentID == id {
+		return errors.New("a product cannot be its own parent")
+	}
+	if len(current.Variants) > 0 {
+		return errors.New("a product with variants cannot become a variant")
+	}
+	if len(product.VariantAttributes) > 0 {
+		return errors.New("a variant cannot have variants of its own")
+	}
+	parent, err := s.repo.GetByID(*product.ParentID)
+	if err != nil {
+		return fmt.Errorf("parent product not found: %d", *product.ParentID)
+	}
+	if parent.ParentID != nil {
+		return errors.New("a variant cannot have variants of its own")
+	}
+	return inheritFromParent(product, parent)
+}
+
+// checkVariantParent checks product, which has variant attributes, can be a
+// parent of variants. current is the product as saved (zero when it is new)
+// and component whether it goes into a composite product. Stock is kept by
+// the variants, which are what is sold, ordered and counted, so a parent
+// keeps none of its own: stock it holds now would be stranded, and so would
+// a composite's share of it.
+func checkVariantParent(product *model.Product, current model.Product, component bool) error {
+	if component {
+		return model.ErrVariantParentComponent
+	}
+	if current.Stock != 0 {
+		return fmt.Errorf("%w; %s still has %s %s in stock, adjust it to zero first", model.ErrVariantParentStock, current.Name, current.Stock, current.Unit)
+	}
+	if product.Stock != 0 {
+		return fmt.Errorf("%w; give its variants the stock instead", model.ErrVariantParentStock)
+	}
+	return nil
+}
+
+// normalizeComponents looks up the components of product, which is being
+// saved under id (zero when it is new), and checks them with
+// checkComponents. A product that goes into another composite cannot become
+// composite itself.
+func (s *productService) normalizeComponents(id int, product *model.Product) error {
+	if !product.IsComposite() {
+		product.Components = []model.Component{}
+		return nil
+	}
+	var stock model.Quantity
+	if id != 0 {
+		used, err := s.repo.IsComponent(id)
+		if err != nil {
+			return err
+		}
+		if used {
+			return errors.New("a component of a composite product cannot be composite itself")
+		}
+		current, err := s.repo.GetByID(id)
+		if err != nil {
+			return fmt.Errorf("product not found: %d", id)
+		}
+		// A composite's stock is worked out from its components
+		if !current.IsComposite() {
+			stock = current.Stock
+		}
+	}
+	components := map[int]model.Product{}
+	for _, c := range product.Components {
+		component, err := s.repo.GetByID(c.ProductID)
+		if err != nil {
+			return fmt.Errorf("component not found: %d", c.ProductID)
+		}
+		components[c.ProductID] = component
+	}
+	return checkComponents(id, product, stock, components)
+}
+
+// checkComponents checks the bill of materials of product, saved under id,
+// against the component products it names. Each component is a distinct,
+// stocked product other than the product itself, taken in a positive
+// quantity that is whole when it is counted in pieces. A composite is sold
+// in whole pieces and has no variants; it keeps no stock, reorder levels or
+// cost of its own. stock is what the product holds now, and a product still
+// holding stock cannot become composite, since that stock would be lost. It
+// must run after normalizeUnit.
+func checkComponents(id int, product *model.Product, stock model.Quantity, components map[int]model.Product) error {
+	if stock != 0 {
+		return fmt.Errorf("%s still has %s %s in stock; adjust it to zero before making it composite", product.Name, stock, product.Unit)
+	}
+	if model.IsFractionalUnit(product.Unit) {
+		return fmt.Errorf("a composite product cannot be sold by %s", product.Unit)
+	}
+	if product.ParentID != nil || len(product.VariantAttributes) > 0 {
+		return errors.New("a composite product cannot have or be a variant")
+	}
+	if product.ReorderPoint != 0 || product.ReorderQuantity != 0 {
+		return errors.New("a composite product has no reorder levels; set them on its components")
+	}
+	product.Stock = 0
+	product.CostPrice = 0
+
+	seen := map[int]bool{}
+	for i := range product.Components {
+		c := &product.Components[i]
+		if id != 0 && c.ProductID == id {
+			return errors.New("a product cannot be its own component")
+		}
+		if seen[c.ProductID] {
+			return fmt.Errorf("component %d is listed more than once", c.ProductID)
+		}
+		seen[c.ProductID] = true
+		component, ok := components[c.ProductID]
+		if !ok {
+			return fmt.Errorf("component not found: %d", c.ProductID)
+		}
+		if component.IsComposite() {
+			return fmt.Errorf("%s is composite and cannot be a component", component.Name)
+		}
+		if len(component.Variants) > 0 {
+			return fmt.Errorf("%s has variants; use one of them as the component", component.Name)
+		}
+		if c.Quantity <= 0 {
+			return fmt.Errorf("quantity of %s must be more than zero", component.Name)
+		}
+		if !model.IsFractionalUnit(component.Unit) && !c.Quantity.IsWhole() {
+			return fmt.Errorf("quantity of %s must be a whole number of %s", component.Name, component.Unit)
+		}
+		c.ProductName = component.Name
+	}
+	return nil
+}
+
+// normalizeVariantAttributes trims a parent's attribute names and checks
+// they are present and distinct.
+func normalizeVariantAttributes(product *model.Product) error {
+	seen := map[string]bool{}
+	for i, name := range product.VariantAttributes {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			return errors.New("variant attribute name is required")
+		}
+		if seen[name] {
+			return fmt.Errorf("variant attribute %s is listed more than once", name)
+		}
+		seen[name] = true
+		product.VariantAttributes[i] = name
+	}
+	return nil
+}
+
+// inheritFromParent checks the variant has a value for each of the parent's
+// attributes and nothing else, and fills in what a variant inherits: the
+// unit and the price unless overridden always, and the name, category and
+// tax class when left out. A missing name is made from the parent's name and
+// the attribute values.
+func inheritFromParent(variant *model.Product, parent model.Product) error {
+	if len(parent.VariantAttributes) == 0 {
+		return fmt.Errorf("%s has no variant attributes", parent.Name)
+	}
+	attributes := map[string]string{}
+	values := []string{}
+	for name, value := range variant.Attributes {
+		attributes[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	for _, name := range parent.VariantAttributes {
+		if attributes[name] == "" {
+			return fmt.Errorf("variant needs a value for %s", name)
+		}
+		values = append(values, attributes[name])
+	}
+	if len(attributes) != len(parent.VariantAttributes) {
+		return fmt.Errorf("variant attributes must be exactly: %s", strings.Join(parent.VariantAttributes, ", "))
+	}
+	variant.Attributes = attributes
+
+	variant.Unit = parent.Unit
+	variant.Price = parent.Price
+	if variant.PriceOverride != nil {
+		if *variant.PriceOverride < 0 {
+			return errors.New("price override cannot be negative")
+		}
+		variant.Price = *variant.PriceOverride
+	}
+	if variant.Name == "" {
+		variant.Name = parent.Name + " " + strings.Join(values, " / ")
+	}
+	if variant.CategoryID == 0 {
+		variant.CategoryID = parent.CategoryID
+	}
+	if variant.TaxClassID == nil {
+		variant.TaxClassID = parent.TaxClassID
+	}
+	return nil
+}
+
+// normalizeProductCodes trims the SKU and checks and normalizes every barcode
+// of the product and its packs, so a UPC-A and its EAN-13 form count as the
+// same code.
+func normalizeProductCodes(product *model.Product) error {
+	product.SKU = strings.TrimSpace(product.SKU)
+
+	seen := map[string]bool{}
+	normalize := func(barcodes []string) ([]string, error) {
+		codes := []string{}
+		for _, code := range barcodes {
+			normalized, err := model.NormalizeBarcode(code)
+			if err != nil {
+				return nil, err
+			}
+			if seen[normalized] {
+				return nil, fmt.Errorf("barcode %s is listed more than once", normalized)
+			}
+			seen[normalized] = true
+			codes = append(codes, normalized)
+		}
+		return codes, nil
+	}
+
+	codes, err := normalize(product.Barcodes)
+	if err != nil {
+		return err
+	}
+	product.Barcodes = codes
+	for i := range product.Conversions {
+		codes, err := normalize(product.Conversions[i].Barcodes)
+		if err != nil {
+			return err
+		}
+		product.Conversions[i].Barcodes = codes
+	}
+	return nil
+}
+
+// normalizeConversions checks the product's packs: each needs a name of its
+// own that is not a unit of measure, a positive size and a price. A pack of a product counted in pieces
+// must hold a whole number of them. Level prices are for the levels other
+// than retail, whose price is the pack price. It must run after normalizeUnit.
+func normalizeConversions(product *model.Product) error {
+	if product.Conversions == nil {
+		product.Conversions = []model.UnitConversion{}
+	}
+	seen := map[string]bool{product.Unit: true}
+	for i := range product.Conversions {
+		c := &product.Conversions[i]
+		c.Unit = strings.ToLower(strings.TrimSpace(c.Unit))
+		if c.Unit == "" {
+			return errors.New("conversion unit is required")
+		}
+		if len(c.Unit) > 20 {
+			return fmt.Errorf("conversion unit is too long: %s", c.Unit)
+		}
+		if seen[c.Unit] {
+			return fmt.Errorf("unit %s is listed more than once", c.Unit)
+		}
+		for _, unit := range model.Units {
+			if c.Unit == unit {
+				return fmt.Errorf("%s is a unit of measure, not a pack", c.Unit)
+			}
+		}
+		seen[c.Unit] = true
+		if c.Factor <= 0 {
+			return fmt.Errorf("%s must hold more than zero %s", c.Unit, product.Unit)
+		}
+		if !model.IsFractionalUnit(product.Unit) && !c.Factor.IsWhole() {
+			return fmt.Errorf("%s must hold a whole number of %s", c.Unit, product.Unit)
+		}
+		if c.Price < 0 {
+			return fmt.Errorf("price of %s cannot be negative", c.Unit)
+		}
+		for level, price := range c.LevelPrices {
+			if !model.IsPriceLevel(level) || level == model.PriceLevelRetail {
+				return fmt.Errorf("invalid price level for %s: %s", c.Unit, level)
+			}
+			if price < 0 {
+				return fmt.Errorf("%s price of %s cannot be negative", level, c.Unit)
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeUnit defaults the unit of measure to pieces and checks that stock
+// and reorder levels of a product counted in pieces are whole numbers.
+func normalizeUnit(product *model.Product) error {
+	if product.Unit == "" {
+		product.Unit = model.UnitPiece
+	}
+	valid := false
+	for _, unit := range model.Units {
+		if product.Unit == unit {
+			valid = true
+		}
+	}
+	if !valid {
+		return fmt.Errorf("invalid unit: %s", product.Unit)
+	}
+
+	if !model.IsFractionalUnit(product.Unit) {
+		for _, q := range []model.Quantity{product.Stock, product.ReorderPoint, product.ReorderQuantity} {
+			if !q.IsWhole() {
+				return fmt.Errorf("stock and reorder levels must be whole numbers of %s", product.Unit)
+			}
+		}
+	}
+	return nil
+}
+
+// normalizePriceTiers checks the product's price tiers. Each level may have
+// one tier per minimum quantity, and a retail tier needs a minimum quantity
+// because the retail price without one is the product's own price. Minimum
+// quantities of a product counted in pieces must be whole. It must run after
+// normalizeUnit.
+func normalizePriceTiers(product *model.Product) error {
+	if product.PriceTiers == nil {
+		product.PriceTiers = []model.PriceTier{}
+	}
+	type tierKey struct {
+		level       string
+		minQuantity model.Quantity
+	}
+	seen := map[tierKey]bool{}
+	for i := range product.PriceTiers {
+		t := &product.PriceTiers[i]
+		if t.Level == "" {
+			t.Level = model.PriceLevelRetail
+		}
+		if !model.IsPriceLevel(t.Level) {
+			return fmt.Errorf("invalid price level: %s", t.Level)
+		}
+		if t.MinQuantity < 0 {
+			return errors.New("tier minimum quantity cannot be negative")
+		}
+		if t.Level == model.PriceLevelRetail && t.MinQuantity == 0 {
+			return errors.New("a retail tier needs a minimum quantity; the product price is the retail price")
+		}
+		if !model.IsFractionalUnit(product.Unit) && !t.MinQuantity.IsWhole() {
+			return fmt.Errorf("tier minimum quantity must be a whole number of %s", product.Unit)
+		}
+		if t.Price < 0 {
+			return errors.New("tier price cannot be negative")
+		}
+		key := tierKey{level: t.Level, minQuantity: t.MinQuantity}
+		if seen[key] {
+			return fmt.Errorf("%s tier from %s is listed more than once", t.Level, t.MinQuantity)
+		}
+		seen[key] = true
+	}
+	return nil
+}
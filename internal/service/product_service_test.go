@@ -0,0 +1,325 @@
+package service
+
+import (
+	"errors"
+	"kasir-api/internal/model"
+	"testing"
+)
+
+func TestNormalizeProductCodes(t *testing.T) {
+	product := model.Product{SKU: "  KOPI-200  ", Barcodes: []string{"036000291452", "8992761136017"}}
+	if err := normalizeProductCodes(&product); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if product.SKU != "KOPI-200" {
+		t.Errorf("Expected trimmed SKU, got %q", product.SKU)
+	}
+	if len(product.Barcodes) != 2 || product.Barcodes[0] != "0036000291452" || product.Barcodes[1] != "8992761136017" {
+		t.Errorf("Unexpected barcodes: %v", product.Barcodes)
+	}
+}
+
+func TestNormalizeProductCodesRejects(t *testing.T) {
+	tests := map[string]struct {
+		codes []string
+		want  string
+	}{
+		"bad check digit":  {[]string{"8992761136018"}, "invalid barcode 8992761136018: check digit should be 7"},
+		"same code twice":  {[]string{"036000291452", "0036000291452"}, "barcode 0036000291452 is listed more than once"},
+		"not a GS1 number": {[]string{"KOPI-200"}, "invalid barcode KOPI-200: only digits are allowed"},
+	}
+	for name, tt := range tests {
+		product := model.Product{Barcodes: tt.codes}
+		if err := normalizeProductCodes(&product); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: expected %q, got %v", name, tt.want, err)
+		}
+	}
+}
+
+func TestNormalizeUnit(t *testing.T) {
+	product := model.Product{Stock: model.WholeQuantity(5)}
+	if err := normalizeUnit(&product); err != nil || product.Unit != model.UnitPiece {
+		t.Errorf("Expected unit to default to pcs, got %q (%v)", product.Unit, err)
+	}
+
+	for _, unit := range []string{model.UnitKilogram, model.UnitGram} {
+		weighed := model.Product{Unit: unit, Stock: 12750, ReorderPoint: 2500}
+		if err := normalizeUnit(&weighed); err != nil {
+			t.Errorf("%s: unexpected error: %v", unit, err)
+		}
+	}
+
+	invalid := map[string]struct {
+		product model.Product
+		want    string
+	}{
+		"unknown unit":          {model.Product{Unit: "dozen"}, "invalid unit: dozen"},
+		"fractional pieces":     {model.Product{Unit: model.UnitPiece, Stock: 1500}, "stock and reorder levels must be whole numbers of pcs"},
+		"fractional reorder pt": {model.Product{ReorderPoint: 500}, "stock and reorder levels must be whole numbers of pcs"},
+	}
+	for name, tt := range invalid {
+		if err := normalizeUnit(&tt.product); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: expected %q, got %v", name, tt.want, err)
+		}
+	}
+}
+
+func TestNormalizeConversions(t *testing.T) {
+	product := model.Product{Unit: model.UnitPiece, Conversions: []model.UnitConversion{
+		{Unit: " Box ", Factor: model.WholeQuantity(12), Price: 6000000},
+		{Unit: "carton", Factor: model.WholeQuantity(144), Price: 70000000},
+	}}
+	if err := normalizeConversions(&product); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if product.Conversions[0].Unit != "box" {
+		t.Errorf("Expected unit name to be normalized, got %q", product.Conversions[0].Unit)
+	}
+
+	invalid := map[string]struct {
+		conversion model.UnitConversion
+		want       string
+	}{
+		"no unit":            {model.UnitConversion{Factor: model.WholeQuantity(12)}, "conversion unit is required"},
+		"product's own unit": {model.UnitConversion{Unit: model.UnitPiece, Factor: model.WholeQuantity(12)}, "unit pcs is listed more than once"},
+		"unit of measure":    {model.UnitConversion{Unit: model.UnitKilogram, Factor: model.WholeQuantity(12)}, "kg is a unit of measure, not a pack"},
+		"empty pack":         {model.UnitConversion{Unit: "pack"}, "pack must hold more than zero pcs"},
+		"part of a piece":    {model.UnitConversion{Unit: "pack", Factor: 1500}, "pack must hold a whole number of pcs"},
+		"negative price":     {model.UnitConversion{Unit: "pack", Factor: model.WholeQuantity(6), Price: -1}, "price of pack cannot be negative"},
+		"retail level price": {model.UnitConversion{Unit: "pack", Factor: model.WholeQuantity(6), LevelPrices: map[string]model.Money{model.PriceLevelRetail: 100}}, "invalid price level for pack: retail"},
+		"unknown level":      {model.UnitConversion{Unit: "pack", Factor: model.WholeQuantity(6), LevelPrices: map[string]model.Money{"vip": 100}}, "invalid price level for pack: vip"},
+		"negative level":     {model.UnitConversion{Unit: "pack", Factor: model.WholeQuantity(6), LevelPrices: map[string]model.Money{model.PriceLevelReseller: -1}}, "reseller price of pack cannot be negative"},
+	}
+	for name, tt := range invalid {
+		p := model.Product{Unit: model.UnitPiece, Conversions: []model.UnitConversion{tt.conversion}}
+		if err := normalizeConversions(&p); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: expected %q, got %v", name, tt.want, err)
+		}
+	}
+
+	twice := model.Product{Unit: model.UnitPiece, Conversions: []model.UnitConversion{
+		{Unit: "box", Factor: model.WholeQuantity(12)},
+		{Unit: "BOX", Factor: model.WholeQuantity(10)},
+	}}
+	if err := normalizeConversions(&twice); err == nil || err.Error() != "unit box is listed more than once" {
+		t.Errorf("Expected error for a unit listed twice, got %v", err)
+	}
+}
+
+func TestNormalizeProductCodesIncludesPacks(t *testing.T) {
+	product := model.Product{
+		Barcodes:    []string{"8992761136017"},
+		Conversions: []model.UnitConversion{{Unit: "box", Barcodes: []string{"036000291452"}}},
+	}
+	if err := normalizeProductCodes(&product); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := product.Conversions[0].Barcodes; len(got) != 1 || got[0] != "0036000291452" {
+		t.Errorf("Unexpected pack barcodes: %v", got)
+	}
+
+	product.Conversions[0].Barcodes = []string{"8992761136017"}
+	if err := normalizeProductCodes(&product); err == nil || err.Error() != "barcode 8992761136017 is listed more than once" {
+		t.Errorf("Expected error for a pack sharing the product's barcode, got %v", err)
+	}
+}
+
+func TestNormalizePriceTiers(t *testing.T) {
+	product := model.Product{Unit: model.UnitPiece, PriceTiers: []model.PriceTier{
+		{MinQuantity: model.WholeQuantity(12), Price: 500000},
+		{Level: model.PriceLevelReseller, Price: 480000},
+	}}
+	if err := normalizePriceTiers(&product); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if product.PriceTiers[0].Level != model.PriceLevelRetail {
+		t.Errorf("Expected level to default to retail, got %q", product.PriceTiers[0].Level)
+	}
+
+	invalid := map[string]struct {
+		tiers []model.PriceTier
+		want  string
+	}{
+		"unknown level":        {[]model.PriceTier{{Level: "vip", Price: 1}}, "invalid price level: vip"},
+		"retail without break": {[]model.PriceTier{{Level: model.PriceLevelRetail, Price: 1}}, "a retail tier needs a minimum quantity; the product price is the retail price"},
+		"negative minimum":     {[]model.PriceTier{{Level: model.PriceLevelMember, MinQuantity: -1000, Price: 1}}, "tier minimum quantity cannot be negative"},
+		"part of a piece":      {[]model.PriceTier{{Level: model.PriceLevelMember, MinQuantity: 1500, Price: 1}}, "tier minimum quantity must be a whole number of pcs"},
+		"negative price":       {[]model.PriceTier{{Level: model.PriceLevelMember, Price: -1}}, "tier price cannot be negative"},
+		"same break twice": {[]model.PriceTier{
+			{Level: model.PriceLevelMember, MinQuantity: model.WholeQuantity(6), Price: 2},
+			{Level: model.PriceLevelMember, MinQuantity: model.WholeQuantity(6), Price: 1},
+		}, "member tier from 6 is listed more than once"},
+	}
+	for name, tt := range invalid {
+		p := model.Product{Unit: model.UnitPiece, PriceTiers: tt.tiers}
+		if err := normalizePriceTiers(&p); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: expected %q, got %v", name, tt.want, err)
+		}
+	}
+}
+
+func TestInheritFromParent(t *testing.T) {
+	taxClass := 2
+	parent := model.Product{Name: "Kaos Polos", Price: 7500000, Unit: model.UnitPiece, CategoryID: 3, TaxClassID: &taxClass, VariantAttributes: []string{"size", "colour"}}
+
+	variant := model.Product{Attributes: map[string]string{"Size": " M ", "colour": "Merah"}}
+	if err := inheritFromParent(&variant, parent); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if variant.Name != "Kaos Polos M / Merah" || variant.Price != 7500000 || variant.CategoryID != 3 || variant.TaxClassID != &taxClass || variant.Unit != model.UnitPiece {
+		t.Errorf("Unexpected inherited fields: %+v", variant)
+	}
+	if variant.Attributes["size"] != "M" {
+		t.Errorf("Expected attributes to be normalized, got %v", variant.Attributes)
+	}
+
+	override := model.Money(8500000)
+	xl := model.Product{Name: "Kaos Polos XL Hitam", Attributes: map[string]string{"size": "XL", "colour": "Hitam"}, PriceOverride: &override}
+	if err := inheritFromParent(&xl, parent); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if xl.Price != 8500000 || xl.Name != "Kaos Polos XL Hitam" {
+		t.Errorf("Expected price override and own name, got %s %q", xl.Price, xl.Name)
+	}
+
+	negative := model.Money(-1)
+	invalid := map[string]struct {
+		variant model.Product
+		want    string
+	}{
+		"missing colour":    {model.Product{Attributes: map[string]string{"size": "M"}}, "variant needs a value for colour"},
+		"empty value":       {model.Product{Attributes: map[string]string{"size": "M", "colour": " "}}, "variant needs a value for colour"},
+		"extra flavour":     {model.Product{Attributes: map[string]string{"size": "M", "colour": "Merah", "flavour": "Coklat"}}, "variant attributes must be exactly: size, colour"},
+		"negative override": {model.Product{Attributes: map[string]string{"size": "M", "colour": "Merah"}, PriceOverride: &negative}, "price override cannot be negative"},
+	}
+	for name, tt := range invalid {
+		if err := inheritFromParent(&tt.variant, parent); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: expected %q, got %v", name, tt.want, err)
+		}
+	}
+
+	plain := model.Product{Name: "Gula Pasir"}
+	if err := inheritFromParent(&model.Product{Attributes: map[string]string{"size": "M"}}, plain); err == nil || err.Error() != "Gula Pasir has no variant attributes" {
+		t.Errorf("Expected error for a parent without variant attributes, got %v", err)
+	}
+}
+
+func TestNormalizeVariantAttributes(t *testing.T) {
+	product := model.Product{VariantAttributes: []string{" Size", "colour "}}
+	if err := normalizeVariantAttributes(&product); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if product.VariantAttributes[0] != "size" || product.VariantAttributes[1] != "colour" {
+		t.Errorf("Unexpected attribute names: %v", product.VariantAttributes)
+	}
+
+	for _, tt := range []struct {
+		names []string
+		want  string
+	}{
+		{[]string{"size", " "}, "variant attribute name is required"},
+		{[]string{"size", "SIZE"}, "variant attribute size is listed more than once"},
+	} {
+		if err := normalizeVariantAttributes(&model.Product{VariantAttributes: tt.names}); err == nil || err.Error() != tt.want {
+			t.Errorf("%v: expected %q, got %v", tt.names, tt.want, err)
+		}
+	}
+}
+
+func TestCheckVariantParent(t *testing.T) {
+	shirt := model.Product{Name: "Kaos Polos", VariantAttributes: []string{"size"}}
+	if err := checkVariantParent(&shirt, model.Product{Name: "Kaos Polos", Unit: model.UnitPiece}, false); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tests := map[string]struct {
+		product   model.Product
+		current   model.Product
+		component bool
+		want      string
+	}{
+		"holds stock": {shirt, model.Product{Name: "Kaos Polos", Unit: model.UnitPiece, Stock: model.WholeQuantity(12)}, false, "a product with variants cannot hold stock; Kaos Polos still has 12 pcs in stock, adjust it to zero first"},
+		"given stock": {model.Product{Name: "Kaos Polos", VariantAttributes: []string{"size"}, Stock: model.WholeQuantity(5)}, model.Product{}, false, "a product with variants cannot hold stock; give its variants the stock instead"},
+		"in a hamper": {shirt, model.Product{Name: "Kaos Polos", Unit: model.UnitPiece}, true, "a component of a composite product cannot have variants"},
+	}
+	for name, tt := range tests {
+		err := checkVariantParent(&tt.product, tt.current, tt.component)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: expected %q, got %v", name, tt.want, err)
+		}
+	}
+	if err := checkVariantParent(&shirt, model.Product{Stock: model.WholeQuantity(1)}, false); !errors.Is(err, model.ErrVariantParentStock) {
+		t.Errorf("Expected ErrVariantParentStock, got %v", err)
+	}
+}
+
+func TestCheckComponents(t *testing.T) {
+	parentID := 5
+	components := map[int]model.Product{
+		1: {ID: 1, Name: "Kopi Sachet", Unit: model.UnitPiece},
+		2: {ID: 2, Name: "Gula Pasir", Unit: model.UnitKilogram},
+		3: {ID: 3, Name: "Parsel Lebaran", Unit: model.UnitPiece, Components: []model.Component{{ProductID: 1, Quantity: model.WholeQuantity(1)}}},
+		4: {ID: 4, Name: "Kaos Polos", Unit: model.UnitPiece, Variants: []model.Product{{ID: 6, ParentID: &parentID}}},
+	}
+
+	hamper := model.Product{Unit: model.UnitPiece, Stock: model.WholeQuantity(4), CostPrice: 100000, Components: []model.Component{
+		{ProductID: 1, Quantity: model.WholeQuantity(2)},
+		{ProductID: 2, Quantity: 500},
+	}}
+	if err := checkComponents(0, &hamper, 0, components); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hamper.Stock != 0 || hamper.CostPrice != 0 {
+		t.Errorf("Expected a composite to keep no stock or cost of its own, got %s and %s", hamper.Stock, hamper.CostPrice)
+	}
+	if hamper.Components[0].ProductName != "Kopi Sachet" {
+		t.Errorf("Expected component name to be filled in, got %q", hamper.Components[0].ProductName)
+	}
+
+	invalid := map[string]struct {
+		component model.Component
+		want      string
+	}{
+		"itself":            {model.Component{ProductID: 7, Quantity: model.WholeQuantity(1)}, "a product cannot be its own component"},
+		"unknown product":   {model.Component{ProductID: 99, Quantity: model.WholeQuantity(1)}, "component not found: 99"},
+		"composite":         {model.Component{ProductID: 3, Quantity: model.WholeQuantity(1)}, "Parsel Lebaran is composite and cannot be a component"},
+		"has variants":      {model.Component{ProductID: 4, Quantity: model.WholeQuantity(1)}, "Kaos Polos has variants; use one of them as the component"},
+		"no quantity":       {model.Component{ProductID: 1}, "quantity of Kopi Sachet must be more than zero"},
+		"part of a piece":   {model.Component{ProductID: 1, Quantity: 500}, "quantity of Kopi Sachet must be a whole number of pcs"},
+		"negative quantity": {model.Component{ProductID: 2, Quantity: -500}, "quantity of Gula Pasir must be more than zero"},
+	}
+	for name, tt := range invalid {
+		p := model.Product{Unit: model.UnitPiece, Components: []model.Component{tt.component}}
+		if err := checkComponents(7, &p, 0, components); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: expected %q, got %v", name, tt.want, err)
+		}
+	}
+
+	twice := model.Product{Unit: model.UnitPiece, Components: []model.Component{
+		{ProductID: 1, Quantity: model.WholeQuantity(1)},
+		{ProductID: 1, Quantity: model.WholeQuantity(2)},
+	}}
+	if err := checkComponents(0, &twice, 0, components); err == nil || err.Error() != "component 1 is listed more than once" {
+		t.Errorf("Expected error for a component listed twice, got %v", err)
+	}
+
+	stocked := model.Product{Name: "Parsel Kopi", Unit: model.UnitPiece, Components: []model.Component{{ProductID: 1, Quantity: model.WholeQuantity(1)}}}
+	want := "Parsel Kopi still has 3 pcs in stock; adjust it to zero before making it composite"
+	if err := checkComponents(7, &stocked, model.WholeQuantity(3), components); err == nil || err.Error() != want {
+		t.Errorf("Expected %q, got %v", want, err)
+	}
+
+	one := []model.Component{{ProductID: 1, Quantity: model.WholeQuantity(1)}}
+	for name, tt := range map[string]struct {
+		product model.Product
+		want    string
+	}{
+		"sold by weight": {model.Product{Unit: model.UnitKilogram, Components: one}, "a composite product cannot be sold by kg"},
+		"variant":        {model.Product{Unit: model.UnitPiece, ParentID: &parentID, Components: one}, "a composite product cannot have or be a variant"},
+		"reorder point":  {model.Product{Unit: model.UnitPiece, ReorderPoint: model.WholeQuantity(5), Components: one}, "a composite product has no reorder levels; set them on its components"},
+	} {
+		if err := checkComponents(0, &tt.product, 0, components); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: expected %q, got %v", name, tt.want, err)
+		}
+	}
+}
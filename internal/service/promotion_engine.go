@@ -0,0 +1,249 @@
+package service
+
+import (
+	"kasir-api/internal/model"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pricedLine is a checkout line before any promotion is applied. Each product
+// appears on at most one line in its own unit, and on at most one line for
+// each of its packs. Packed lines are sold in a pack, so Quantity and
+// UnitPrice are per pack.
+type pricedLine struct {
+	ProductID int
+	Packed    bool
+	Quantity  model.Quantity
+	UnitPrice model.Money
+}
+
+func (l pricedLine) gross() model.Money {
+	return l.UnitPrice.MulQuantity(l.Quantity)
+}
+
+type lineDiscount struct {
+	Amount  model.Money
+	Applied []model.AppliedDiscount
+}
+
+type promotionResult struct {
+	Lines []lineDiscount
+	Cart  []model.AppliedDiscount
+	Total model.Money
+}
+
+// applyPromotions evaluates the active promotions against the cart:
+//
+//  1. Bundles claim complete sets of their component products first.
+//  2. Each line then gets the single best item promotion (percentage, fixed
+//     or buy-X-get-Y) on the units not already in a bundle.
+//  3. Finally the best minimum-spend promotion is applied to what is left and
+//     shared across lines in proportion to their net amount.
+//
+// Item promotions never stack with each other, but a line can be part of a
+// bundle, have an item promotion on its remaining units, and share in a cart
+// discount. Bundles and buy-X-get-Y only count whole units, so a fractional
+// quantity never makes up part of a set.
+//
+// Promotions are set up per unit of a product, so lines sold in packs only
+// take percentage discounts and cart discounts; fixed amounts, buy-X-get-Y and
+// bundles apply to the product's own unit.
+func applyPromotions(lines []pricedLine, promotions []model.Promotion, now time.Time) promotionResult {
+	result := promotionResult{Lines: make([]lineDiscount, len(lines))}
+
+	var active []model.Promotion
+	for _, p := range promotions {
+		if isPromotionActive(p, now) {
+			active = append(active, p)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].ID < active[j].ID })
+
+	lineByProduct := map[int]int{}
+	remaining := make([]model.Quantity, len(lines))
+	for i, l := range lines {
+		if !l.Packed {
+			lineByProduct[l.ProductID] = i
+		}
+		remaining[i] = l.Quantity
+	}
+
+	addLineDiscount := func(i int, p model.Promotion, amount model.Money) {
+		if amount <= 0 {
+			return
+		}
+		result.Lines[i].Amount += amount
+		result.Lines[i].Applied = append(result.Lines[i].Applied, model.AppliedDiscount{
+			PromotionID:   p.ID,
+			PromotionName: p.Name,
+			Amount:        amount,
+		})
+		result.Total += amount
+	}
+
+	// 1. Bundles
+	for _, p := range active {
+		if p.Type != model.PromotionTypeBundle || len(p.ProductIDs) == 0 {
+			continue
+		}
+
+		sets := -1
+		var component []int
+		var setPrice model.Money
+		for _, productID := range p.ProductIDs {
+			i, ok := lineByProduct[productID]
+			if !ok {
+				sets = 0
+				break
+			}
+			if whole := remaining[i].Whole(); sets < 0 || whole < sets {
+				sets = whole
+			}
+			component = append(component, i)
+			setPrice += lines[i].UnitPrice
+		}
+		if sets <= 0 || setPrice <= p.BundlePrice {
+			continue
+		}
+
+		weights := make([]model.Money, len(component))
+		for k, i := range component {
+			weights[k] = lines[i].UnitPrice
+			remaining[i] -= model.WholeQuantity(sets)
+		}
+		shares := (setPrice - p.BundlePrice).Mul(sets).Allocate(weights)
+		for k, i := range component {
+			addLineDiscount(i, p, shares[k])
+		}
+	}
+
+	// 2. Item promotions
+	for i, l := range lines {
+		if remaining[i] == 0 {
+			continue
+		}
+
+		var best model.Promotion
+		var bestAmount model.Money
+		for _, p := range active {
+			if !containsProduct(p.ProductIDs, l.ProductID) {
+				continue
+			}
+			if l.Packed && p.Type != model.PromotionTypePercentage {
+				continue
+			}
+			var amount model.Money
+			switch p.Type {
+			case model.PromotionTypePercentage:
+				amount = l.UnitPrice.MulQuantity(remaining[i]).MulDiv(p.Percent, 100)
+			case model.PromotionTypeFixed:
+				amount = min(p.Amount, l.UnitPrice).MulQuantity(remaining[i])
+			case model.PromotionTypeBuyXGetY:
+				if group := p.BuyQuantity + p.GetQuantity; group > 0 {
+					amount = l.UnitPrice.Mul(remaining[i].Whole() / group * p.GetQuantity)
+				}
+			}
+			if amount > bestAmount {
+				best, bestAmount = p, amount
+			}
+		}
+		addLineDiscount(i, best, bestAmount)
+	}
+
+	// 3. Cart promotions
+	net := make([]model.Money, len(lines))
+	var cartTotal model.Money
+	for i, l := range lines {
+		net[i] = l.gross() - result.Lines[i].Amount
+		cartTotal += net[i]
+	}
+
+	var best model.Promotion
+	var bestAmount model.Money
+	for _, p := range active {
+		if p.Type != model.PromotionTypeMinSpend || cartTotal < p.MinSpend {
+			continue
+		}
+		amount := p.Amount
+		if p.Percent > 0 {
+			amount = cartTotal.MulDiv(p.Percent, 100)
+		}
+		amount = min(amount, cartTotal)
+		if amount > bestAmount {
+			best, bestAmount = p, amount
+		}
+	}
+	if bestAmount > 0 {
+		result.Cart = append(result.Cart, model.AppliedDiscount{
+			PromotionID:   best.ID,
+			PromotionName: best.Name,
+			Amount:        bestAmount,
+		})
+		for i, share := range bestAmount.Allocate(net) {
+			result.Lines[i].Amount += share
+		}
+		result.Total += bestAmount
+	}
+
+	return result
+}
+
+// isPromotionActive reports whether p applies at now, checking the active
+// flag, the date range and the optional daily time window. A window whose end
+// is before its start runs past midnight.
+func isPromotionActive(p model.Promotion, now time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !now.Before(*p.EndsAt) {
+		return false
+	}
+	if p.DailyStart == "" || p.DailyEnd == "" {
+		return true
+	}
+
+	start, err := parseClock(p.DailyStart)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(p.DailyEnd)
+	if err != nil {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// parseClock converts "HH:MM" to minutes since midnight.
+func parseClock(s string) (int, error) {
+	hh, mm, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, strconv.ErrSyntax
+	}
+	h, err := strconv.Atoi(hh)
+	if err != nil || h < 0 || h > 23 {
+		return 0, strconv.ErrSyntax
+	}
+	m, err := strconv.Atoi(mm)
+	if err != nil || m < 0 || m > 59 {
+		return 0, strconv.ErrSyntax
+	}
+	return h*60 + m, nil
+}
+
+func containsProduct(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"kasir-api/internal/model"
+	"testing"
+	"time"
+)
+
+var promoNow = time.Date(2026, 3, 10, 14, 0, 0, 0, time.UTC)
+
+func TestApplyPromotionsPicksBestItemPromotion(t *testing.T) {
+	lines := []pricedLine{{ProductID: 1, Quantity: model.WholeQuantity(3), UnitPrice: 1000000}}
+	promotions := []model.Promotion{
+		{ID: 1, Name: "10% off", Type: model.PromotionTypePercentage, ProductIDs: []int{1}, Percent: 10, Active: true},
+		{ID: 2, Name: "Rp 2000 off", Type: model.PromotionTypeFixed, ProductIDs: []int{1}, Amount: 200000, Active: true},
+		{ID: 3, Name: "Buy 2 get 1", Type: model.PromotionTypeBuyXGetY, ProductIDs: []int{1}, BuyQuantity: 2, GetQuantity: 1, Active: true},
+	}
+
+	result := applyPromotions(lines, promotions, promoNow)
+
+	if result.Lines[0].Amount != 1000000 {
+		t.Errorf("Expected buy-2-get-1 to win with 10000.00 off, got %s", result.Lines[0].Amount)
+	}
+	if len(result.Lines[0].Applied) != 1 || result.Lines[0].Applied[0].PromotionID != 3 {
+		t.Errorf("Expected only promotion 3 applied, got %+v", result.Lines[0].Applied)
+	}
+}
+
+func TestApplyPromotionsBundleThenItemPromotion(t *testing.T) {
+	lines := []pricedLine{
+		{ProductID: 1, Quantity: model.WholeQuantity(3), UnitPrice: 1500000},
+		{ProductID: 2, Quantity: model.WholeQuantity(1), UnitPrice: 500000},
+	}
+	promotions := []model.Promotion{
+		{ID: 1, Name: "Combo", Type: model.PromotionTypeBundle, ProductIDs: []int{1, 2}, BundlePrice: 1800000, Active: true},
+		{ID: 2, Name: "10% off", Type: model.PromotionTypePercentage, ProductIDs: []int{1}, Percent: 10, Active: true},
+	}
+
+	result := applyPromotions(lines, promotions, promoNow)
+
+	// One combo saves 2000.00, split 1500:500. The two remaining units of
+	// product 1 get 10% off (3000.00).
+	if result.Lines[0].Amount != 150000+300000 {
+		t.Errorf("Unexpected discount on line 1: %s", result.Lines[0].Amount)
+	}
+	if result.Lines[1].Amount != 50000 {
+		t.Errorf("Unexpected discount on line 2: %s", result.Lines[1].Amount)
+	}
+	if result.Total != 500000 {
+		t.Errorf("Expected total discount 5000.00, got %s", result.Total)
+	}
+}
+
+func TestApplyPromotionsMinSpendIsSharedAcrossLines(t *testing.T) {
+	lines := []pricedLine{
+		{ProductID: 1, Quantity: model.WholeQuantity(1), UnitPrice: 3000000},
+		{ProductID: 2, Quantity: model.WholeQuantity(1), UnitPrice: 1000000},
+	}
+	promotions := []model.Promotion{
+		{ID: 1, Name: "Spend 30k save 5k", Type: model.PromotionTypeMinSpend, MinSpend: 3000000, Amount: 500000, Active: true},
+		{ID: 2, Name: "Spend 100k save 10%", Type: model.PromotionTypeMinSpend, MinSpend: 10000000, Percent: 10, Active: true},
+	}
+
+	result := applyPromotions(lines, promotions, promoNow)
+
+	if len(result.Cart) != 1 || result.Cart[0].PromotionID != 1 || result.Cart[0].Amount != 500000 {
+		t.Fatalf("Expected only promotion 1 on the cart, got %+v", result.Cart)
+	}
+	if result.Lines[0].Amount != 375000 || result.Lines[1].Amount != 125000 {
+		t.Errorf("Expected cart discount split 3750.00/1250.00, got %s/%s", result.Lines[0].Amount, result.Lines[1].Amount)
+	}
+}
+
+func TestIsPromotionActive(t *testing.T) {
+	yesterday := promoNow.AddDate(0, 0, -1)
+	tomorrow := promoNow.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name     string
+		promo    model.Promotion
+		expected bool
+	}{
+		{"inactive", model.Promotion{Active: false}, false},
+		{"no window", model.Promotion{Active: true}, true},
+		{"in date range", model.Promotion{Active: true, StartsAt: &yesterday, EndsAt: &tomorrow}, true},
+		{"not started", model.Promotion{Active: true, StartsAt: &tomorrow}, false},
+		{"ended", model.Promotion{Active: true, EndsAt: &yesterday}, false},
+		{"in happy hour", model.Promotion{Active: true, DailyStart: "13:00", DailyEnd: "15:00"}, true},
+		{"outside happy hour", model.Promotion{Active: true, DailyStart: "16:00", DailyEnd: "18:00"}, false},
+		{"overnight window", model.Promotion{Active: true, DailyStart: "22:00", DailyEnd: "15:00"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := isPromotionActive(tt.promo, promoNow); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestApplyPromotionsFractionalQuantity(t *testing.T) {
+	// 2.5 kg at Rp 20.000/kg: percentage applies to the whole weight, but
+	// buy-2-get-1 only ever counts whole units.
+	lines := []pricedLine{{ProductID: 1, Quantity: 2500, UnitPrice: 2000000}}
+	percentage := []model.Promotion{{ID: 1, Name: "10% off", Type: model.PromotionTypePercentage, ProductIDs: []int{1}, Percent: 10, Active: true}}
+	if got := applyPromotions(lines, percentage, promoNow).Lines[0].Amount; got != 500000 {
+		t.Errorf("Expected 5000.00 off, got %s", got)
+	}
+
+	buyXGetY := []model.Promotion{{ID: 2, Name: "Buy 2 get 1", Type: model.PromotionTypeBuyXGetY, ProductIDs: []int{1}, BuyQuantity: 2, GetQuantity: 1, Active: true}}
+	if got := applyPromotions(lines, buyXGetY, promoNow).Lines[0].Amount; got != 0 {
+		t.Errorf("Expected no discount on 2.5 units, got %s", got)
+	}
+}
+
+func TestApplyPromotionsPackedLines(t *testing.T) {
+	// A box of 12 at Rp 60.000 next to 2 loose pieces at Rp 5.500
+	lines := []pricedLine{
+		{ProductID: 1, Packed: true, Quantity: model.WholeQuantity(1), UnitPrice: 6000000},
+		{ProductID: 1, Quantity: model.WholeQuantity(2), UnitPrice: 550000},
+	}
+
+	percentage := []model.Promotion{{ID: 1, Name: "10% off", Type: model.PromotionTypePercentage, ProductIDs: []int{1}, Percent: 10, Active: true}}
+	result := applyPromotions(lines, percentage, promoNow)
+	if result.Lines[0].Amount != 600000 || result.Lines[1].Amount != 110000 {
+		t.Errorf("Expected 10%% off both lines, got %s and %s", result.Lines[0].Amount, result.Lines[1].Amount)
+	}
+
+	fixed := []model.Promotion{{ID: 2, Name: "Rp 500 off", Type: model.PromotionTypeFixed, ProductIDs: []int{1}, Amount: 50000, Active: true}}
+	result = applyPromotions(lines, fixed, promoNow)
+	if result.Lines[0].Amount != 0 || result.Lines[1].Amount != 100000 {
+		t.Errorf("Expected fixed discount on loose pieces only, got %s and %s", result.Lines[0].Amount, result.Lines[1].Amount)
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"kasir-api/internal/model"
+	"kasir-api/internal/repository"
+)
+
+type PromotionService interface {
+	Create(promotion model.Promotion) (model.Promotion, error)
+	GetAll() ([]model.Promotion, error)
+	GetByID(id int) (model.Promotion, error)
+	Update(id int, promotion model.Promotion) (model.Promotion, error)
+	Delete(id int) error
+}
+
+type promotionService struct {
+	repo        repository.PromotionRepository
+	productRepo repository.ProductRepository
+}
+
+func NewPromotionService(repo repository.PromotionRepository, productRepo repository.ProductRepository) PromotionService {
+	return &promotionService{repo: repo, productRepo: productRepo}
+}
+
+func (s *promotionService) Create(promotion model.Promotion) (model.Promotion, error) {
+	if err := validatePromotion(promotion, s.products(promotion)); err != nil {
+		return model.Promotion{}, err
+	}
+	return s.repo.Create(promotion)
+}
+
+func (s *promotionService) GetAll() ([]model.Promotion, error) {
+	return s.repo.GetAll()
+}
+
+func (s *promotionService) GetByID(id int) (model.Promotion, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *promotionService) Update(id int, promotion model.Promotion) (model.Promotion, error) {
+	if err := validatePromotion(promotion, s.products(promotion)); err != nil {
+		return model.Promotion{}, err
+	}
+	return s.repo.Update(id, promotion)
+}
+
+func (s *promotionService) Delete(id int) error {
+	return s.repo.Delete(id)
+}
+
+// products looks up the products the promotion names, leaving out any that
+// are not found.
+func (s *promotionService) products(p model.Promotion) map[int]model.Product {
+	products := map[int]model.Product{}
+	for _, id := range p.ProductIDs {
+		if product, err := s.productRepo.GetByID(id); err == nil {
+			products[id] = product
+		}
+	}
+	return products
+}
+
+// validatePromotion checks p against the products it names. Checkout matches
+// promotions on the product sold, which for a product with variants is one of
+// its variants, so such a product cannot be named itself.
+func validatePromotion(p model.Promotion, products map[int]model.Product) error {
+	if p.Name == "" {
+		return errors.New("promotion name is required")
+	}
+	if p.Percent < 0 || p.Percent > 100 {
+		return errors.New("percent must be between 0 and 100")
+	}
+	if p.Amount < 0 || p.BundlePrice < 0 || p.MinSpend < 0 {
+		return errors.New("amounts cannot be negative")
+	}
+
+	seen := map[int]bool{}
+	for _, id := range p.ProductIDs {
+		if seen[id] {
+			return fmt.Errorf("product %d is listed more than once", id)
+		}
+		seen[id] = true
+		if product, ok := products[id]; ok && len(product.Variants) > 0 {
+			return fmt.Errorf("%s has variants; list the variants the promotion applies to", product.Name)
+		}
+	}
+
+	switch p.Type {
+	case model.PromotionTypePercentage:
+		if p.Percent == 0 {
+			return errors.New("percent is required")
+		}
+		if len(p.ProductIDs) == 0 {
+			return errors.New("at least one product is required")
+		}
+	case model.PromotionTypeFixed:
+		if p.Amount == 0 {
+			return errors.New("amount is required")
+		}
+		if len(p.ProductIDs) == 0 {
+			return errors.New("at least one product is required")
+		}
+	case model.PromotionTypeBuyXGetY:
+		if p.BuyQuantity <= 0 || p.GetQuantity <= 0 {
+			return errors.New("buy quantity and get quantity must be greater than zero")
+		}
+		if len(p.ProductIDs) == 0 {
+			return errors.New("at least one product is required")
+		}
+	case model.PromotionTypeBundle:
+		if len(p.ProductIDs) < 2 {
+			return errors.New("a bundle needs at least two products")
+		}
+		if p.BundlePrice == 0 {
+			return errors.New("bundle price is required")
+		}
+	case model.PromotionTypeMinSpend:
+		if p.MinSpend == 0 {
+			return errors.New("minimum spend is required")
+		}
+		if (p.Percent == 0) == (p.Amount == 0) {
+			return errors.New("either percent or amount is required")
+		}
+	default:
+		return fmt.Errorf("invalid promotion type: %s", p.Type)
+	}
+
+	if p.StartsAt != nil && p.EndsAt != nil && !p.StartsAt.Before(*p.EndsAt) {
+		return errors.New("starts_at must be before ends_at")
+	}
+	if (p.DailyStart == "") != (p.DailyEnd == "") {
+		return errors.New("daily_start and daily_end must be set together")
+	}
+	if p.DailyStart != "" {
+		if _, err := parseClock(p.DailyStart); err != nil {
+			return errors.New("daily_start must be in HH:MM format")
+		}
+		if _, err := parseClock(p.DailyEnd); err != nil {
+			return errors.New("daily_end must be in HH:MM format")
+		}
+	}
+	return nil
+}
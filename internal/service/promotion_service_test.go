@@ -0,0 +1,34 @@
+package service
+
+import (
+	"kasir-api/internal/model"
+	"testing"
+)
+
+func TestValidatePromotionProducts(t *testing.T) {
+	parentID := 4
+	products := map[int]model.Product{
+		1: {ID: 1, Name: "Teh Botol"},
+		4: {ID: 4, Name: "Kaos Polos", Variants: []model.Product{{ID: 5, ParentID: &parentID}, {ID: 6, ParentID: &parentID}}},
+		5: {ID: 5, Name: "Kaos Polos M", ParentID: &parentID},
+	}
+
+	variant := model.Promotion{Name: "Diskon Kaos", Type: model.PromotionTypePercentage, Percent: 10, ProductIDs: []int{5}}
+	if err := validatePromotion(variant, products); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tests := map[string]struct {
+		promotion model.Promotion
+		want      string
+	}{
+		"parent":             {model.Promotion{Name: "Diskon Kaos", Type: model.PromotionTypePercentage, Percent: 10, ProductIDs: []int{4}}, "Kaos Polos has variants; list the variants the promotion applies to"},
+		"parent in a bundle": {model.Promotion{Name: "Paket", Type: model.PromotionTypeBundle, BundlePrice: 5000000, ProductIDs: []int{1, 4}}, "Kaos Polos has variants; list the variants the promotion applies to"},
+		"duplicate":          {model.Promotion{Name: "Diskon Teh", Type: model.PromotionTypeFixed, Amount: 50000, ProductIDs: []int{1, 1}}, "product 1 is listed more than once"},
+	}
+	for name, tt := range tests {
+		if err := validatePromotion(tt.promotion, products); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: expected %q, got %v", name, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/internal/model"
+	"kasir-api/internal/repository"
+)
+
+type PurchaseOrderService interface {
+	Create(ctx context.Context, request model.PurchaseOrderRequest) (model.PurchaseOrder, error)
+	GetAll(ctx context.Context, status string, supplierID int) ([]model.PurchaseOrder, error)
+	GetByID(ctx context.Context, id int) (model.PurchaseOrder, error)
+	Receive(ctx context.Context, id int, request model.GoodsReceiptRequest) (model.GoodsReceipt, error)
+	Cancel(ctx context.Context, id int) error
+	Close(ctx context.Context, id int) error
+}
+
+type purchaseOrderService struct {
+	repo         repository.PurchaseOrderRepository
+	supplierRepo repository.SupplierRepository
+	productRepo  repository.ProductRepository
+}
+
+func NewPurchaseOrderService(repo repository.PurchaseOrderRepository, supplierRepo repository.SupplierRepository, productRepo repository.ProductRepository) PurchaseOrderService {
+	return &purchaseOrderService{repo: repo, supplierRepo: supplierRepo, productRepo: productRepo}
+}
+
+func (s *purchaseOrderService) Create(ctx context.Context, request model.PurchaseOrderRequest) (model.PurchaseOrder, error) {
+	products := map[int]model.Product{}
+	for _, item := range request.Items {
+		if product, err := s.productRepo.GetByID(item.ProductID); err == nil {
+			products[item.ProductID] = product
+		}
+	}
+	order, err := buildPurchaseOrder(request, products)
+	if err != nil {
+		return model.PurchaseOrder{}, err
+	}
+	if _, err := s.supplierRepo.GetByID(request.SupplierID); err != nil {
+		return model.PurchaseOrder{}, fmt.Errorf("supplier not found: %d", request.SupplierID)
+	}
+	return s.repo.Create(ctx, order)
+}
+
+func (s *purchaseOrderService) GetAll(ctx context.Context, status string, supplierID int) ([]model.PurchaseOrder, error) {
+	switch status {
+	case "", model.PurchaseOrderStatusOpen, model.PurchaseOrderStatusPartiallyReceived, model.PurchaseOrderStatusReceived, model.PurchaseOrderStatusCancelled,
+		model.PurchaseOrderStatusClosed:
+	default:
+		return nil, fmt.Errorf("invalid purchase order status: %s", status)
+	}
+	return s.repo.GetAll(ctx, status, supplierID)
+}
+
+func (s *purchaseOrderService) GetByID(ctx context.Context, id int) (model.PurchaseOrder, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *purchaseOrderService) Receive(ctx context.Context, id int, request model.GoodsReceiptRequest) (model.GoodsReceipt, error) {
+	order, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.GoodsReceipt{}, fmt.Errorf("purchase order not found: %d", id)
+	} else if err != nil {
+		return model.GoodsReceipt{}, err
+	}
+	products := map[int]model.Product{}
+	for _, item := range order.Items {
+		if product, err := s.productRepo.GetByID(item.ProductID); err == nil {
+			products[item.ID] = product
+		}
+	}
+	if err := validateGoodsReceipt(request, products); err != nil {
+		return model.GoodsReceipt{}, err
+	}
+	return s.repo.Receive(ctx, id, request)
+}
+
+func (s *purchaseOrderService) Cancel(ctx context.Context, id int) error {
+	return s.repo.Cancel(ctx, id)
+}
+
+func (s *purchaseOrderService) Close(ctx context.Context, id int) error {
+	return s.repo.Close(ctx, id)
+}
+
+// buildPurchaseOrder validates a new order against the products it names and
+// works out its total at the expected costs. Only products that keep stock of
+// their own can be ordered: not composites, whose stock is their components',
+// nor products with variants, which are ordered by variant.
+func buildPurchaseOrder(request model.PurchaseOrderRequest, products map[int]model.Product) (model.PurchaseOrder, error) {
+	if request.SupplierID == 0 {
+		return model.PurchaseOrder{}, errors.New("supplier id is required")
+	}
+	if len(request.Items) == 0 {
+		return model.PurchaseOrder{}, errors.New("at least one item is required")
+	}
+
+	order := model.PurchaseOrder{SupplierID: request.SupplierID, Note: request.Note}
+	seen := map[int]bool{}
+	for _, item := range request.Items {
+		if item.Quantity <= 0 {
+			return model.PurchaseOrder{}, errors.New("quantity must be greater than zero")
+		}
+		if item.UnitCost < 0 {
+			return model.PurchaseOrder{}, errors.New("unit cost cannot be negative")
+		}
+		if seen[item.ProductID] {
+			return model.PurchaseOrder{}, fmt.Errorf("product %d is listed more than once", item.ProductID)
+		}
+		seen[item.ProductID] = true
+		product, ok := products[item.ProductID]
+		if !ok {
+			return model.PurchaseOrder{}, fmt.Errorf("product not found: %d", item.ProductID)
+		}
+		if product.IsComposite() {
+			return model.PurchaseOrder{}, fmt.Errorf("%s is composite; order its components instead", product.Name)
+		}
+		if len(product.Variants) > 0 {
+			return model.PurchaseOrder{}, fmt.Errorf("%s has variants; order one of them instead", product.Name)
+		}
+		if !model.IsFractionalUnit(product.Unit) && !item.Quantity.IsWhole() {
+			return model.PurchaseOrder{}, fmt.Errorf("quantity of %s must be a whole number of %s", product.Name, product.Unit)
+		}
+
+		order.Items = append(order.Items, model.PurchaseOrderItem{ProductID: item.ProductID, Quantity: item.Quantity, UnitCost: item.UnitCost})
+		order.TotalAmount += item.UnitCost.MulQuantity(item.Quantity)
+	}
+	return order, nil
+}
+
+// validateGoodsReceipt checks a delivery against the products of the order
+// items it receives, keyed by order item ID. Items whose product has since
+// been deleted are left to the repository.
+func validateGoodsReceipt(request model.GoodsReceiptRequest, products map[int]model.Product) error {
+	if len(request.Items) == 0 {
+		return errors.New("at least one item is required")
+	}
+	seen := map[int]bool{}
+	for _, item := range request.Items {
+		if item.Quantity <= 0 {
+			return errors.New("quantity must be greater than zero")
+		}
+		if item.UnitCost != nil && *item.UnitCost < 0 {
+			return errors.New("unit cost cannot be negative")
+		}
+		if seen[item.PurchaseOrderItemID] {
+			return fmt.Errorf("purchase order item %d is listed more than once", item.PurchaseOrderItemID)
+		}
+		seen[item.PurchaseOrderItemID] = true
+		if product, ok := products[item.PurchaseOrderItemID]; ok && !model.IsFractionalUnit(product.Unit) && !item.Quantity.IsWhole() {
+			return fmt.Errorf("quantity of %s must be a whole number of %s", product.Name, product.Unit)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"kasir-api/internal/model"
+	"testing"
+)
+
+func TestBuildPurchaseOrder(t *testing.T) {
+	order, err := buildPurchaseOrder(model.PurchaseOrderRequest{
+		SupplierID: 3,
+		Items: []model.PurchaseOrderRequestItem{
+			{ProductID: 1, Quantity: model.WholeQuantity(24), UnitCost: 350000},
+			{ProductID: 2, Quantity: model.WholeQuantity(10), UnitCost: 1250000},
+		},
+	}, map[int]model.Product{1: {ID: 1, Name: "Teh Botol", Unit: model.UnitPiece}, 2: {ID: 2, Name: "Kopi Bubuk 200g", Unit: model.UnitPiece}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.TotalAmount != 20900000 {
+		t.Errorf("Expected total 209000.00, got %s", order.TotalAmount)
+	}
+	if len(order.Items) != 2 || order.Items[1].UnitCost != 1250000 {
+		t.Errorf("Unexpected items: %+v", order.Items)
+	}
+}
+
+func TestBuildPurchaseOrderRejects(t *testing.T) {
+	parentID := 4
+	products := map[int]model.Product{
+		1: {ID: 1, Name: "Teh Botol", Unit: model.UnitPiece},
+		2: {ID: 2, Name: "Parsel Lebaran", Components: []model.Component{{ProductID: 1, Quantity: model.WholeQuantity(2)}}},
+		3: {ID: 3, Name: "Beras", Unit: model.UnitKilogram},
+		4: {ID: 4, Name: "Kaos Polos", Variants: []model.Product{{ID: 5, ParentID: &parentID}}},
+	}
+	one := func(productID int) []model.PurchaseOrderRequestItem {
+		return []model.PurchaseOrderRequestItem{{ProductID: productID, Quantity: model.WholeQuantity(1)}}
+	}
+
+	tests := []struct {
+		name    string
+		request model.PurchaseOrderRequest
+		want    string
+	}{
+		{"no supplier", model.PurchaseOrderRequest{Items: one(1)}, "supplier id is required"},
+		{"no items", model.PurchaseOrderRequest{SupplierID: 1}, "at least one item is required"},
+		{"zero quantity", model.PurchaseOrderRequest{SupplierID: 1, Items: []model.PurchaseOrderRequestItem{{ProductID: 1}}}, "quantity must be greater than zero"},
+		{"negative cost", model.PurchaseOrderRequest{SupplierID: 1, Items: []model.PurchaseOrderRequestItem{{ProductID: 1, Quantity: model.WholeQuantity(1), UnitCost: -1}}}, "unit cost cannot be negative"},
+		{"duplicate", model.PurchaseOrderRequest{SupplierID: 1, Items: append(one(1), one(1)...)}, "product 1 is listed more than once"},
+		{"unknown product", model.PurchaseOrderRequest{SupplierID: 1, Items: one(99)}, "product not found: 99"},
+		{"composite", model.PurchaseOrderRequest{SupplierID: 1, Items: one(2)}, "Parsel Lebaran is composite; order its components instead"},
+		{"has variants", model.PurchaseOrderRequest{SupplierID: 1, Items: one(4)}, "Kaos Polos has variants; order one of them instead"},
+		{"part of a piece", model.PurchaseOrderRequest{SupplierID: 1, Items: []model.PurchaseOrderRequestItem{{ProductID: 1, Quantity: 1500}}}, "quantity of Teh Botol must be a whole number of pcs"},
+	}
+	for _, tt := range tests {
+		if _, err := buildPurchaseOrder(tt.request, products); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: expected %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestValidateGoodsReceipt(t *testing.T) {
+	// Keyed by purchase order item
+	products := map[int]model.Product{
+		1: {ID: 7, Name: "Teh Botol", Unit: model.UnitPiece},
+		2: {ID: 8, Name: "Beras", Unit: model.UnitKilogram},
+	}
+	cost := model.Money(300000)
+	valid := model.GoodsReceiptRequest{Items: []model.GoodsReceiptRequestItem{
+		{PurchaseOrderItemID: 1, Quantity: model.WholeQuantity(12), UnitCost: &cost},
+		{PurchaseOrderItemID: 2, Quantity: 24500},
+	}}
+	if err := validateGoodsReceipt(valid, products); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	negative := model.Money(-1)
+	tests := map[string]struct {
+		request model.GoodsReceiptRequest
+		want    string
+	}{
+		"no items":        {model.GoodsReceiptRequest{}, "at least one item is required"},
+		"zero quantity":   {model.GoodsReceiptRequest{Items: []model.GoodsReceiptRequestItem{{PurchaseOrderItemID: 1}}}, "quantity must be greater than zero"},
+		"negative cost":   {model.GoodsReceiptRequest{Items: []model.GoodsReceiptRequestItem{{PurchaseOrderItemID: 1, Quantity: model.WholeQuantity(1), UnitCost: &negative}}}, "unit cost cannot be negative"},
+		"duplicate":       {model.GoodsReceiptRequest{Items: []model.GoodsReceiptRequestItem{{PurchaseOrderItemID: 1, Quantity: model.WholeQuantity(1)}, {PurchaseOrderItemID: 1, Quantity: model.WholeQuantity(1)}}}, "purchase order item 1 is listed more than once"},
+		"part of a piece": {model.GoodsReceiptRequest{Items: []model.GoodsReceiptRequestItem{{PurchaseOrderItemID: 1, Quantity: 11500}}}, "quantity of Teh Botol must be a whole number of pcs"},
+	}
+	for name, tt := range tests {
+		if err := validateGoodsReceipt(tt.request, products); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: expected %q, got %v", name, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kasir-api/internal/model"
+	"kasir-api/internal/repository"
+)
+
+type ShiftService interface {
+	Open(ctx context.Context, request model.OpenShiftRequest) (model.Shift, error)
+	GetAll(ctx context.Context, status string) ([]model.Shift, error)
+	GetReport(ctx context.Context, id int) (model.ShiftReport, error)
+	AddCashMovement(ctx context.Context, shiftID int, movement model.CashMovement) (model.CashMovement, error)
+	Close(ctx context.Context, id int, request model.CloseShiftRequest) (model.ShiftReport, error)
+}
+
+type shiftService struct {
+	repo repository.ShiftRepository
+}
+
+func NewShiftService(repo repository.ShiftRepository) ShiftService {
+	return &shiftService{repo: repo}
+}
+
+func (s *shiftService) Open(ctx context.Context, request model.OpenShiftRequest) (model.Shift, error) {
+	if request.CashierName == "" {
+		return model.Shift{}, errors.New("cashier name is required")
+	}
+	if request.OpeningFloat < 0 {
+		return model.Shift{}, errors.New("opening float cannot be negative")
+	}
+	return s.repo.Open(ctx, model.Shift{CashierName: request.CashierName, OpeningFloat: request.OpeningFloat})
+}
+
+func (s *shiftService) GetAll(ctx context.Context, status string) ([]model.Shift, error) {
+	if status != "" && status != model.ShiftStatusOpen && status != model.ShiftStatusClosed {
+		return nil, fmt.Errorf("invalid shift status: %s", status)
+	}
+	return s.repo.GetAll(ctx, status)
+}
+
+func (s *shiftService) GetReport(ctx context.Context, id int) (model.ShiftReport, error) {
+	return s.repo.GetReport(ctx, id)
+}
+
+func (s *shiftService) AddCashMovement(ctx context.Context, shiftID int, movement model.CashMovement) (model.CashMovement, error) {
+	if movement.Type != model.CashMovementIn && movement.Type != model.CashMovementOut {
+		return model.CashMovement{}, fmt.Errorf("invalid cash movement type: %s", movement.Type)
+	}
+	if movement.Amount <= 0 {
+		return model.CashMovement{}, errors.New("amount must be greater than zero")
+	}
+	if movement.Reason == "" {
+		return model.CashMovement{}, errors.New("reason is required")
+	}
+	movement.ShiftID = shiftID
+	return s.repo.AddCashMovement(ctx, movement)
+}
+
+func (s *shiftService) Close(ctx context.Context, id int, request model.CloseShiftRequest) (model.ShiftReport, error) {
+	counted, err := countCash(request.Denominations)
+	if err != nil {
+		return model.ShiftReport{}, err
+	}
+	return s.repo.Close(ctx, id, request.Denominations, counted, request.Note)
+}
+
+// countCash totals a drawer count, rejecting unknown or repeated
+// denominations.
+func countCash(denominations []model.DenominationCount) (model.Money, error) {
+	valid := map[model.Money]bool{}
+	for _, d := range model.Denominations {
+		valid[d] = true
+	}
+
+	var total model.Money
+	seen := map[model.Money]bool{}
+	for _, d := range denominations {
+		if !valid[d.Denomination] {
+			return 0, fmt.Errorf("invalid denomination: %s", d.Denomination)
+		}
+		if seen[d.Denomination] {
+			return 0, fmt.Errorf("denomination %s is listed more than once", d.Denomination)
+		}
+		if d.Count < 0 {
+			return 0, errors.New("denomination count cannot be negative")
+		}
+		seen[d.Denomination] = true
+		total += d.Denomination.Mul(d.Count)
+	}
+	return total, nil
+}
+
+// resolveShift returns the shift a sale or refund belongs to. An explicit ID
+// is checked by the repository when it locks the shift; otherwise the single
+// open shift is used.
+func resolveShift(ctx context.Context, repo repository.ShiftRepository, requested int) (int, error) {
+	if requested != 0 {
+		return requested, nil
+	}
+
+	shifts, err := repo.GetAll(ctx, model.ShiftStatusOpen)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load open shifts: %w", err)
+	}
+	switch len(shifts) {
+	case 0:
+		return 0, errors.New("no shift is open, open a shift first")
+	case 1:
+		return shifts[0].ID, nil
+	default:
+		return 0, errors.New("more than one shift is open, shift_id is required")
+	}
+}
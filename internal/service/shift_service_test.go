@@ -0,0 +1,34 @@
+package service
+
+import (
+	"kasir-api/internal/model"
+	"testing"
+)
+
+func TestCountCash(t *testing.T) {
+	total, err := countCash([]model.DenominationCount{
+		{Denomination: 10000000, Count: 3},
+		{Denomination: 500000, Count: 4},
+		{Denomination: 50000, Count: 7},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 3 x 100.000 + 4 x 5.000 + 7 x 500
+	if total != 32350000 {
+		t.Errorf("Expected 323500.00, got %s", total)
+	}
+}
+
+func TestCountCashRejectsInvalidCounts(t *testing.T) {
+	tests := map[string][]model.DenominationCount{
+		"unknown denomination":  {{Denomination: 300000, Count: 1}},
+		"repeated denomination": {{Denomination: 500000, Count: 1}, {Denomination: 500000, Count: 2}},
+		"negative count":        {{Denomination: 500000, Count: -1}},
+	}
+	for name, denominations := range tests {
+		if _, err := countCash(denominations); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
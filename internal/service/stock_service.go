@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kasir-api/internal/model"
+	"kasir-api/internal/repository"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	defaultReorderSalesDays = 30
+	defaultReorderCoverDays = 14
+	maxReorderDays          = 365
+)
+
+type StockService interface {
+	GetMovements(ctx context.Context, productID int) ([]model.StockMovement, error)
+	RecordMovement(ctx context.Context, productID int, movement model.StockMovement) (model.StockMovement, error)
+	CheckConsistency(ctx context.Context) ([]model.StockDiscrepancy, error)
+	SuggestReorders(ctx context.Context, salesDays, coverDays int) (model.ReorderReport, error)
+}
+
+type stockService struct {
+	repo        repository.StockMovementRepository
+	productRepo repository.ProductRepository
+	calendar    model.BusinessCalendar
+}
+
+func NewStockService(repo repository.StockMovementRepository, productRepo repository.ProductRepository, calendar model.BusinessCalendar) StockService {
+	return &stockService{repo: repo, productRepo: productRepo, calendar: calendar}
+}
+
+func (s *stockService) GetMovements(ctx context.Context, productID int) ([]model.StockMovement, error) {
+	return s.repo.GetByProduct(ctx, productID)
+}
+
+func (s *stockService) RecordMovement(ctx context.Context, productID int, movement model.StockMovement) (model.StockMovement, error) {
+	movement.ProductID = productID
+	product, err := s.productRepo.GetByID(productID)
+	if err != nil {
+		return model.StockMovement{}, fmt.Errorf("product not found: %d", productID)
+	}
+	if err := validateManualMovement(movement, product); err != nil {
+		return model.StockMovement{}, err
+	}
+	return s.repo.Record(ctx, movement)
+}
+
+func (s *stockService) CheckConsistency(ctx context.Context) ([]model.StockDiscrepancy, error) {
+	return s.repo.CheckConsistency(ctx)
+}
+
+// SuggestReorders proposes purchase quantities per supplier so stock lasts
+// coverDays at the rate it sold over the last salesDays complete business
+// days. Zero means the default for either.
+func (s *stockService) SuggestReorders(ctx context.Context, salesDays, coverDays int) (model.ReorderReport, error) {
+	if salesDays == 0 {
+		salesDays = defaultReorderSalesDays
+	}
+	if coverDays == 0 {
+		coverDays = defaultReorderCoverDays
+	}
+	if salesDays < 1 || salesDays > maxReorderDays || coverDays < 1 || coverDays > maxReorderDays {
+		return model.ReorderReport{}, fmt.Errorf("days and cover_days must be between 1 and %d", maxReorderDays)
+	}
+
+	today := s.calendar.DateOf(time.Now())
+	since, until := s.calendar.Start(today.AddDate(0, 0, -salesDays)), s.calendar.Start(today)
+	candidates, err := s.repo.GetReorderCandidates(ctx, since, until)
+	if err != nil {
+		return model.ReorderReport{}, err
+	}
+	return suggestReorders(candidates, salesDays, coverDays), nil
+}
+
+// suggestReorders picks the products that are at their reorder point or will
+// run out within coverDays, counting what is already on order as stock. Each
+// is ordered up to enough for coverDays of sales on top of its reorder point,
+// rounded up to whole units and never less than its reorder quantity. Suggestions are grouped by supplier, most urgent first, with
+// products that have no supplier yet in a group of their own at the end.
+func suggestReorders(candidates []model.ReorderCandidate, salesDays, coverDays int) model.ReorderReport {
+	report := model.ReorderReport{SalesDays: salesDays, CoverDays: coverDays, Suppliers: []model.SupplierReorder{}}
+	groups := map[int]*model.SupplierReorder{}
+	var order []int
+
+	for _, c := range candidates {
+		suggestion := model.ReorderSuggestion{
+			ProductID:       c.ID,
+			ProductName:     c.Name,
+			Stock:           c.Stock,
+			ReorderPoint:    c.ReorderPoint,
+			ReorderQuantity: c.ReorderQuantity,
+			SoldQuantity:    c.SoldQuantity,
+			OnOrderQuantity: c.OnOrderQuantity,
+			UnitCost:        c.CostPrice,
+		}
+
+		position := c.Stock + c.OnOrderQuantity
+		low := c.ReorderPoint > 0 && position <= c.ReorderPoint
+		if c.SoldQuantity > 0 {
+			suggestion.DailySales = math.Round(float64(c.SoldQuantity)/model.QuantityScale/float64(salesDays)*100) / 100
+			cover := math.Round(float64(c.Stock)*float64(salesDays)/float64(c.SoldQuantity)*10) / 10
+			suggestion.DaysOfCover = &cover
+			if math.Round(float64(position)*float64(salesDays)/float64(c.SoldQuantity)*10)/10 < float64(coverDays) {
+				low = true
+			}
+		}
+		if !low {
+			continue
+		}
+
+		// Demand over the cover period; orders are placed in whole units.
+		demand := (c.SoldQuantity*model.Quantity(coverDays) + model.Quantity(salesDays) - 1) / model.Quantity(salesDays)
+		quantity := (demand + c.ReorderPoint - position).Ceil()
+		if quantity < c.ReorderQuantity {
+			quantity = c.ReorderQuantity
+		}
+		if quantity <= 0 {
+			continue
+		}
+		suggestion.SuggestedQuantity = quantity
+		suggestion.EstimatedCost = c.CostPrice.MulQuantity(quantity)
+
+		group, ok := groups[c.SupplierID]
+		if !ok {
+			group = &model.SupplierReorder{SupplierID: c.SupplierID, SupplierName: c.SupplierName}
+			groups[c.SupplierID] = group
+			order = append(order, c.SupplierID)
+		}
+		group.Items = append(group.Items, suggestion)
+		group.EstimatedCost += suggestion.EstimatedCost
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		a, b := groups[order[i]], groups[order[j]]
+		if (a.SupplierID == 0) != (b.SupplierID == 0) {
+			return b.SupplierID == 0
+		}
+		return a.SupplierName < b.SupplierName
+	})
+	for _, id := range order {
+		group := groups[id]
+		sort.SliceStable(group.Items, func(i, j int) bool {
+			return daysOfCover(group.Items[i]) < daysOfCover(group.Items[j])
+		})
+		report.Suppliers = append(report.Suppliers, *group)
+	}
+	return report
+}
+
+// daysOfCover orders products that did not sell after those that did, since
+// they are only on the list for being at their reorder point.
+func daysOfCover(s model.ReorderSuggestion) float64 {
+	if s.DaysOfCover == nil {
+		return math.Inf(1)
+	}
+	return *s.DaysOfCover
+}
+
+// validateManualMovement checks a movement of product entered by hand. Sales,
+// refunds and purchases are recorded by their own documents, so only
+// adjustments and transfers can be entered directly, and they need a note
+// saying why. Products not sold by weight, volume or length move in whole units.
+func validateManualMovement(movement model.StockMovement, product model.Product) error {
+	if movement.Type != model.StockMovementAdjustment && movement.Type != model.StockMovementTransfer {
+		return fmt.Errorf("invalid stock movement type: %s", movement.Type)
+	}
+	if movement.Quantity == 0 {
+		return errors.New("quantity cannot be zero")
+	}
+	if !model.IsFractionalUnit(product.Unit) && !movement.Quantity.IsWhole() {
+		return fmt.Errorf("quantity of %s must be a whole number of %s", product.Name, product.Unit)
+	}
+	if movement.Note == "" {
+		return errors.New("note is required")
+	}
+	if (movement.ReferenceType == "") != (movement.ReferenceID == 0) {
+		return errors.New("reference_type and reference_id must be given together")
+	}
+	return nil
+}
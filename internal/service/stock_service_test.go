@@ -0,0 +1,134 @@
+package service
+
+import (
+	"kasir-api/internal/model"
+	"testing"
+)
+
+func TestValidateManualMovement(t *testing.T) {
+	tea := model.Product{Name: "Teh Botol", Unit: model.UnitPiece}
+	rice := model.Product{Name: "Beras", Unit: model.UnitKilogram}
+	valid := []model.StockMovement{
+		{Type: model.StockMovementAdjustment, Quantity: model.WholeQuantity(-2), Note: "broken in storage"},
+		{Type: model.StockMovementTransfer, Quantity: model.WholeQuantity(-10), Note: "to branch Dago", ReferenceType: "transfer", ReferenceID: 7},
+		{Type: model.StockMovementTransfer, Quantity: model.WholeQuantity(4), Note: "from warehouse"},
+	}
+	for _, m := range valid {
+		if err := validateManualMovement(m, tea); err != nil {
+			t.Errorf("%+v: unexpected error: %v", m, err)
+		}
+	}
+	spilled := model.StockMovement{Type: model.StockMovementAdjustment, Quantity: -250, Note: "spilled"}
+	if err := validateManualMovement(spilled, rice); err != nil {
+		t.Errorf("Expected part of a kg to be allowed, got %v", err)
+	}
+	want := "quantity of Teh Botol must be a whole number of pcs"
+	if err := validateManualMovement(spilled, tea); err == nil || err.Error() != want {
+		t.Errorf("part of a piece: expected %q, got %v", want, err)
+	}
+
+	invalid := map[string]struct {
+		movement model.StockMovement
+		want     string
+	}{
+		"sale":              {model.StockMovement{Type: model.StockMovementSale, Quantity: model.WholeQuantity(-1), Note: "manual sale"}, "invalid stock movement type: sale"},
+		"purchase":          {model.StockMovement{Type: model.StockMovementPurchase, Quantity: model.WholeQuantity(5), Note: "delivery"}, "invalid stock movement type: purchase"},
+		"zero quantity":     {model.StockMovement{Type: model.StockMovementAdjustment, Note: "recount"}, "quantity cannot be zero"},
+		"missing note":      {model.StockMovement{Type: model.StockMovementAdjustment, Quantity: model.WholeQuantity(1)}, "note is required"},
+		"half a reference":  {model.StockMovement{Type: model.StockMovementTransfer, Quantity: model.WholeQuantity(1), Note: "x", ReferenceType: "transfer"}, "reference_type and reference_id must be given together"},
+		"reference id only": {model.StockMovement{Type: model.StockMovementTransfer, Quantity: model.WholeQuantity(1), Note: "x", ReferenceID: 3}, "reference_type and reference_id must be given together"},
+	}
+	for name, tt := range invalid {
+		if err := validateManualMovement(tt.movement, tea); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: expected %q, got %v", name, tt.want, err)
+		}
+	}
+}
+
+func TestSuggestReorders(t *testing.T) {
+	candidates := []model.ReorderCandidate{
+		// 60 sold in 30 days is 2 a day; 10 in stock lasts 5 days.
+		{Product: model.Product{ID: 1, Name: "Gula Pasir 1kg", Stock: model.WholeQuantity(10), CostPrice: 1400000, ReorderPoint: model.WholeQuantity(5), ReorderQuantity: model.WholeQuantity(12)}, SoldQuantity: model.WholeQuantity(60), SupplierID: 2, SupplierName: "CV Sumber Rejeki"},
+		// Plenty of cover and above its reorder point.
+		{Product: model.Product{ID: 2, Name: "Kopi Bubuk 200g", Stock: model.WholeQuantity(100), CostPrice: 1800000, ReorderPoint: model.WholeQuantity(10)}, SoldQuantity: model.WholeQuantity(30), SupplierID: 2, SupplierName: "CV Sumber Rejeki"},
+		// At its reorder point without sales: ordered in its reorder quantity.
+		{Product: model.Product{ID: 3, Name: "Teh Celup", Stock: model.WholeQuantity(3), CostPrice: 500000, ReorderPoint: model.WholeQuantity(3), ReorderQuantity: model.WholeQuantity(24)}, SupplierID: 2, SupplierName: "CV Sumber Rejeki"},
+		// Never ordered from anyone.
+		{Product: model.Product{ID: 4, Name: "Es Batu", Stock: 0, CostPrice: 100000}, SoldQuantity: model.WholeQuantity(15)},
+		{Product: model.Product{ID: 5, Name: "Minyak Goreng 2L", Stock: model.WholeQuantity(4), CostPrice: 3500000, ReorderPoint: model.WholeQuantity(6)}, SoldQuantity: model.WholeQuantity(9), SupplierID: 1, SupplierName: "PT Aneka Pangan"},
+	}
+
+	report := suggestReorders(candidates, 30, 14)
+	if report.SalesDays != 30 || report.CoverDays != 14 {
+		t.Errorf("Unexpected window: %+v", report)
+	}
+	if len(report.Suppliers) != 3 {
+		t.Fatalf("Expected 3 supplier groups, got %d", len(report.Suppliers))
+	}
+
+	// Alphabetical by supplier name, with the unassigned products last.
+	sumber, aneka, none := report.Suppliers[0], report.Suppliers[1], report.Suppliers[2]
+	if aneka.SupplierID != 1 || sumber.SupplierID != 2 || none.SupplierID != 0 {
+		t.Fatalf("Unexpected supplier order: %d, %d, %d", aneka.SupplierID, sumber.SupplierID, none.SupplierID)
+	}
+
+	// ceil(9*14/30) = 5 for the cover period, plus a reorder point of 6, less 4 in stock.
+	if len(aneka.Items) != 1 || aneka.Items[0].SuggestedQuantity != model.WholeQuantity(7) || aneka.EstimatedCost != 24500000 {
+		t.Errorf("Unexpected order for PT Aneka Pangan: %+v", aneka)
+	}
+
+	if len(sumber.Items) != 2 {
+		t.Fatalf("Expected 2 items for CV Sumber Rejeki, got %+v", sumber.Items)
+	}
+	gula, teh := sumber.Items[0], sumber.Items[1]
+	if gula.ProductID != 1 || teh.ProductID != 3 {
+		t.Fatalf("Expected the selling product first, got %d then %d", gula.ProductID, teh.ProductID)
+	}
+	// 28 for the cover period plus a reorder point of 5, less 10 in stock.
+	if gula.SuggestedQuantity != model.WholeQuantity(23) || gula.DailySales != 2 || gula.DaysOfCover == nil || *gula.DaysOfCover != 5 {
+		t.Errorf("Unexpected suggestion for Gula Pasir: %+v", gula)
+	}
+	if teh.SuggestedQuantity != model.WholeQuantity(24) || teh.DaysOfCover != nil {
+		t.Errorf("Unexpected suggestion for Teh Celup: %+v", teh)
+	}
+	if sumber.EstimatedCost != 1400000*23+500000*24 {
+		t.Errorf("Unexpected estimated cost %s", sumber.EstimatedCost)
+	}
+
+	if len(none.Items) != 1 || none.Items[0].SuggestedQuantity != model.WholeQuantity(7) {
+		t.Errorf("Unexpected order without supplier: %+v", none)
+	}
+}
+
+func TestSuggestReordersNothingLow(t *testing.T) {
+	report := suggestReorders([]model.ReorderCandidate{
+		{Product: model.Product{ID: 1, Stock: model.WholeQuantity(50), ReorderPoint: model.WholeQuantity(10)}, SoldQuantity: model.WholeQuantity(30)},
+		{Product: model.Product{ID: 2, Stock: 0}},
+	}, 30, 14)
+	if len(report.Suppliers) != 0 {
+		t.Errorf("Expected no suggestions, got %+v", report.Suppliers)
+	}
+}
+
+func TestSuggestReordersCountsStockOnOrder(t *testing.T) {
+	report := suggestReorders([]model.ReorderCandidate{
+		// 2 a day with 10 in stock, but 20 more already ordered: 15 days of cover.
+		{Product: model.Product{ID: 1, Stock: model.WholeQuantity(10), ReorderPoint: model.WholeQuantity(5)}, SoldQuantity: model.WholeQuantity(60), OnOrderQuantity: model.WholeQuantity(20), SupplierID: 2},
+		// At its reorder point, with a delivery of 24 on the way.
+		{Product: model.Product{ID: 2, Stock: model.WholeQuantity(3), ReorderPoint: model.WholeQuantity(3), ReorderQuantity: model.WholeQuantity(24)}, OnOrderQuantity: model.WholeQuantity(24), SupplierID: 2},
+		// 28 for the cover period plus a reorder point of 5, less 10 in stock and 8 on order.
+		{Product: model.Product{ID: 3, Stock: model.WholeQuantity(10), ReorderPoint: model.WholeQuantity(5)}, SoldQuantity: model.WholeQuantity(60), OnOrderQuantity: model.WholeQuantity(8), SupplierID: 2},
+	}, 30, 14)
+
+	if len(report.Suppliers) != 1 || len(report.Suppliers[0].Items) != 1 {
+		t.Fatalf("Expected one suggestion, got %+v", report.Suppliers)
+	}
+	item := report.Suppliers[0].Items[0]
+	if item.ProductID != 3 || item.SuggestedQuantity != model.WholeQuantity(15) || item.OnOrderQuantity != model.WholeQuantity(8) {
+		t.Errorf("Unexpected suggestion: %+v", item)
+	}
+	// Days of cover still describes the stock on hand
+	if item.DaysOfCover == nil || *item.DaysOfCover != 5 {
+		t.Errorf("Expected 5 days of cover, got %v", item.DaysOfCover)
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kasir-api/internal/model"
+	"kasir-api/internal/repository"
+)
+
+type StocktakeService interface {
+	Start(ctx context.Context, request model.StartStocktakeRequest) (model.Stocktake, error)
+	GetAll(ctx context.Context) ([]model.Stocktake, error)
+	GetReport(ctx context.Context, id int) (model.StocktakeReport, error)
+	SubmitCounts(ctx context.Context, id int, request model.SubmitCountsRequest) (model.StocktakeReport, error)
+	Post(ctx context.Context, id int) (model.StocktakeReport, error)
+	Cancel(ctx context.Context, id int) error
+}
+
+type stocktakeService struct {
+	repo        repository.StocktakeRepository
+	productRepo repository.ProductRepository
+}
+
+func NewStocktakeService(repo repository.StocktakeRepository, productRepo repository.ProductRepository) StocktakeService {
+	return &stocktakeService{repo: repo, productRepo: productRepo}
+}
+
+func (s *stocktakeService) Start(ctx context.Context, request model.StartStocktakeRequest) (model.Stocktake, error) {
+	if request.CategoryID != nil && *request.CategoryID <= 0 {
+		return model.Stocktake{}, errors.New("invalid category id")
+	}
+	return s.repo.Start(ctx, request)
+}
+
+func (s *stocktakeService) GetAll(ctx context.Context) ([]model.Stocktake, error) {
+	return s.repo.GetAll(ctx)
+}
+
+func (s *stocktakeService) GetReport(ctx context.Context, id int) (model.StocktakeReport, error) {
+	stocktake, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return model.StocktakeReport{}, err
+	}
+	return stocktakeReport(stocktake), nil
+}
+
+func (s *stocktakeService) SubmitCounts(ctx context.Context, id int, request model.SubmitCountsRequest) (model.StocktakeReport, error) {
+	products := map[int]model.Product{}
+	for _, c := range request.Counts {
+		if product, err := s.productRepo.GetByID(c.ProductID); err == nil {
+			products[c.ProductID] = product
+		}
+	}
+	if err := validateCounts(request, products); err != nil {
+		return model.StocktakeReport{}, err
+	}
+	if err := s.repo.SubmitCounts(ctx, id, request); err != nil {
+		return model.StocktakeReport{}, err
+	}
+	return s.GetReport(ctx, id)
+}
+
+func (s *stocktakeService) Post(ctx context.Context, id int) (model.StocktakeReport, error) {
+	stocktake, err := s.repo.Post(ctx, id)
+	if err != nil {
+		return model.StocktakeReport{}, err
+	}
+	return stocktakeReport(stocktake), nil
+}
+
+func (s *stocktakeService) Cancel(ctx context.Context, id int) error {
+	return s.repo.Cancel(ctx, id)
+}
+
+// validateCounts checks counts against the products counted. Products not
+// sold by weight, volume or length are counted in whole units; products not
+// found are left to the repository, which knows what the stocktake covers.
+func validateCounts(request model.SubmitCountsRequest, products map[int]model.Product) error {
+	if request.CounterName == "" {
+		return errors.New("counter name is required")
+	}
+	if len(request.Counts) == 0 {
+		return errors.New("at least one count is required")
+	}
+	seen := map[int]bool{}
+	for _, c := range request.Counts {
+		if c.Quantity < 0 {
+			return errors.New("counted quantity cannot be negative")
+		}
+		if seen[c.ProductID] {
+			return fmt.Errorf("product %d is counted more than once", c.ProductID)
+		}
+		seen[c.ProductID] = true
+		if product, ok := products[c.ProductID]; ok && !model.IsFractionalUnit(product.Unit) && !c.Quantity.IsWhole() {
+			return fmt.Errorf("quantity of %s must be a whole number of %s", product.Name, product.Unit)
+		}
+	}
+	return nil
+}
+
+// stocktakeReport totals the variances of the counted items.
+func stocktakeReport(stocktake model.Stocktake) model.StocktakeReport {
+	report := model.StocktakeReport{Stocktake: stocktake}
+	for _, item := range stocktake.Items {
+		if item.CountedQuantity == nil {
+			report.UncountedItems++
+			continue
+		}
+		report.CountedItems++
+		if item.Variance > 0 {
+			report.SurplusQuantity += item.Variance
+			report.SurplusValue += item.VarianceValue
+		} else {
+			report.ShortageQuantity -= item.Variance
+			report.ShortageValue -= item.VarianceValue
+		}
+	}
+	report.NetVarianceValue = report.SurplusValue - report.ShortageValue
+	return report
+}
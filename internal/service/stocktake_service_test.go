@@ -0,0 +1,63 @@
+package service
+
+import (
+	"kasir-api/internal/model"
+	"testing"
+)
+
+func counted(n int) *model.Quantity {
+	q := model.WholeQuantity(n)
+	return &q
+}
+
+func TestStocktakeReport(t *testing.T) {
+	stocktake := model.Stocktake{Items: []model.StocktakeItem{
+		{ProductID: 1, SystemStock: model.WholeQuantity(10), UnitCost: 500000, CountedQuantity: counted(12), Variance: model.WholeQuantity(2), VarianceValue: 1000000},
+		{ProductID: 2, SystemStock: model.WholeQuantity(20), UnitCost: 250000, CountedQuantity: counted(15), Variance: model.WholeQuantity(-5), VarianceValue: -1250000},
+		{ProductID: 3, SystemStock: model.WholeQuantity(8), UnitCost: 100000, CountedQuantity: counted(8)},
+		{ProductID: 4, SystemStock: model.WholeQuantity(3), UnitCost: 100000},
+	}}
+
+	report := stocktakeReport(stocktake)
+
+	if report.CountedItems != 3 || report.UncountedItems != 1 {
+		t.Errorf("Expected 3 counted and 1 uncounted, got %d and %d", report.CountedItems, report.UncountedItems)
+	}
+	if report.SurplusQuantity != model.WholeQuantity(2) || report.SurplusValue != 1000000 {
+		t.Errorf("Unexpected surplus: %s, %s", report.SurplusQuantity, report.SurplusValue)
+	}
+	if report.ShortageQuantity != model.WholeQuantity(5) || report.ShortageValue != 1250000 {
+		t.Errorf("Unexpected shortage: %s, %s", report.ShortageQuantity, report.ShortageValue)
+	}
+	if report.NetVarianceValue != -250000 {
+		t.Errorf("Expected net variance -2500.00, got %s", report.NetVarianceValue)
+	}
+}
+
+func TestValidateCounts(t *testing.T) {
+	products := map[int]model.Product{
+		1: {ID: 1, Name: "Teh Botol", Unit: model.UnitPiece},
+		2: {ID: 2, Name: "Kopi Bubuk 200g", Unit: model.UnitPiece},
+		3: {ID: 3, Name: "Beras", Unit: model.UnitKilogram},
+	}
+	ok := model.SubmitCountsRequest{CounterName: "Budi", Counts: []model.StocktakeCountItem{{ProductID: 1, Quantity: 0}, {ProductID: 2, Quantity: model.WholeQuantity(7)}, {ProductID: 3, Quantity: 12750}}}
+	if err := validateCounts(ok, products); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	invalid := map[string]struct {
+		request model.SubmitCountsRequest
+		want    string
+	}{
+		"no counter":      {model.SubmitCountsRequest{Counts: []model.StocktakeCountItem{{ProductID: 1, Quantity: model.WholeQuantity(1)}}}, "counter name is required"},
+		"no counts":       {model.SubmitCountsRequest{CounterName: "Budi"}, "at least one count is required"},
+		"negative":        {model.SubmitCountsRequest{CounterName: "Budi", Counts: []model.StocktakeCountItem{{ProductID: 1, Quantity: model.WholeQuantity(-1)}}}, "counted quantity cannot be negative"},
+		"duplicate item":  {model.SubmitCountsRequest{CounterName: "Budi", Counts: []model.StocktakeCountItem{{ProductID: 1, Quantity: model.WholeQuantity(1)}, {ProductID: 1, Quantity: model.WholeQuantity(2)}}}, "product 1 is counted more than once"},
+		"part of a piece": {model.SubmitCountsRequest{CounterName: "Budi", Counts: []model.StocktakeCountItem{{ProductID: 2, Quantity: 6500}}}, "quantity of Kopi Bubuk 200g must be a whole number of pcs"},
+	}
+	for name, tt := range invalid {
+		if err := validateCounts(tt.request, products); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: expected %q, got %v", name, tt.want, err)
+		}
+	}
+}
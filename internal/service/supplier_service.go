@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kasir-api/internal/model"
+	"kasir-api/internal/repository"
+)
+
+type SupplierService interface {
+	Create(supplier model.Supplier) (model.Supplier, error)
+	GetAll() ([]model.Supplier, error)
+	GetByID(id int) (model.Supplier, error)
+	Update(id int, supplier model.Supplier) (model.Supplier, error)
+	Delete(id int) error
+	AddPayment(ctx context.Context, supplierID int, payment model.SupplierPayment) (model.SupplierPayment, error)
+	GetPayments(ctx context.Context, supplierID int) ([]model.SupplierPayment, error)
+}
+
+type supplierService struct {
+	repo repository.SupplierRepository
+}
+
+func NewSupplierService(repo repository.SupplierRepository) SupplierService {
+	return &supplierService{repo: repo}
+}
+
+func (s *supplierService) Create(supplier model.Supplier) (model.Supplier, error) {
+	if supplier.Name == "" {
+		return model.Supplier{}, errors.New("supplier name is required")
+	}
+	return s.repo.Create(supplier)
+}
+
+func (s *supplierService) GetAll() ([]model.Supplier, error) {
+	return s.repo.GetAll()
+}
+
+func (s *supplierService) GetByID(id int) (model.Supplier, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *supplierService) Update(id int, supplier model.Supplier) (model.Supplier, error) {
+	if supplier.Name == "" {
+		return model.Supplier{}, errors.New("supplier name is required")
+	}
+	return s.repo.Update(id, supplier)
+}
+
+func (s *supplierService) Delete(id int) error {
+	return s.repo.Delete(id)
+}
+
+// AddPayment records a payment to the supplier. The repository rejects paying
+// more than is owed.
+func (s *supplierService) AddPayment(ctx context.Context, supplierID int, payment model.SupplierPayment) (model.SupplierPayment, error) {
+	if payment.Amount <= 0 {
+		return model.SupplierPayment{}, errors.New("amount must be greater than zero")
+	}
+	if !isPaymentMethod(payment.Method) {
+		return model.SupplierPayment{}, fmt.Errorf("invalid payment method: %s", payment.Method)
+	}
+
+	payment.SupplierID = supplierID
+	return s.repo.AddPayment(ctx, payment)
+}
+
+func (s *supplierService) GetPayments(ctx context.Context, supplierID int) ([]model.SupplierPayment, error) {
+	return s.repo.GetPayments(ctx, supplierID)
+}
@@ -0,0 +1,56 @@
+package service
+
+import "kasir-api/internal/model"
+
+// taxLine is a checkout line after discounts, with the tax class of its
+// product. A zero Rate means the product is not taxed.
+type taxLine struct {
+	Amount    model.Money
+	Rate      model.Rate
+	Inclusive bool
+}
+
+type lineTax struct {
+	Tax           model.Money
+	ServiceCharge model.Money
+}
+
+type taxBreakdown struct {
+	Lines         []lineTax
+	Subtotal      model.Money
+	Tax           model.Money
+	ServiceCharge model.Money
+	Total         model.Money
+}
+
+// calculateTax works out PPN per line and the service charge for the sale.
+// Inclusive tax is extracted from the line amount, exclusive tax is added on
+// top. The service charge is levied on the pre-tax amount of every line and
+// is not itself taxed.
+func calculateTax(lines []taxLine, serviceChargeRate model.Rate) taxBreakdown {
+	result := taxBreakdown{Lines: make([]lineTax, len(lines))}
+
+	bases := make([]model.Money, len(lines))
+	var addedTax, totalBase model.Money
+	for i, l := range lines {
+		result.Subtotal += l.Amount
+		if l.Inclusive {
+			result.Lines[i].Tax = l.Rate.IncludedIn(l.Amount)
+			bases[i] = l.Amount - result.Lines[i].Tax
+		} else {
+			result.Lines[i].Tax = l.Rate.Of(l.Amount)
+			bases[i] = l.Amount
+			addedTax += result.Lines[i].Tax
+		}
+		result.Tax += result.Lines[i].Tax
+		totalBase += bases[i]
+	}
+
+	result.ServiceCharge = serviceChargeRate.Of(totalBase)
+	for i, share := range result.ServiceCharge.Allocate(bases) {
+		result.Lines[i].ServiceCharge = share
+	}
+
+	result.Total = result.Subtotal + addedTax + result.ServiceCharge
+	return result
+}
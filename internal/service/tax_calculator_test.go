@@ -0,0 +1,51 @@
+package service
+
+import "testing"
+
+func TestCalculateTaxExclusive(t *testing.T) {
+	result := calculateTax([]taxLine{
+		{Amount: 1000000, Rate: 1100},
+		{Amount: 500000},
+	}, 0)
+
+	if result.Lines[0].Tax != 110000 || result.Lines[1].Tax != 0 {
+		t.Errorf("Unexpected line taxes: %+v", result.Lines)
+	}
+	if result.Subtotal != 1500000 || result.Tax != 110000 || result.Total != 1610000 {
+		t.Errorf("Unexpected totals: subtotal %s, tax %s, total %s", result.Subtotal, result.Tax, result.Total)
+	}
+}
+
+func TestCalculateTaxInclusive(t *testing.T) {
+	result := calculateTax([]taxLine{{Amount: 1110000, Rate: 1100, Inclusive: true}}, 0)
+
+	if result.Tax != 110000 {
+		t.Errorf("Expected included tax 1100.00, got %s", result.Tax)
+	}
+	if result.Total != 1110000 {
+		t.Errorf("Inclusive tax must not change the total, got %s", result.Total)
+	}
+}
+
+func TestCalculateTaxWithServiceCharge(t *testing.T) {
+	// A restaurant bill with a 5% service charge on the pre-tax amount
+	result := calculateTax([]taxLine{
+		{Amount: 1110000, Rate: 1100, Inclusive: true},
+		{Amount: 2000000, Rate: 1200},
+	}, 500)
+
+	// Pre-tax bases are 10000.00 and 20000.00
+	if result.ServiceCharge != 150000 {
+		t.Errorf("Expected service charge 1500.00, got %s", result.ServiceCharge)
+	}
+	if result.Lines[0].ServiceCharge != 50000 || result.Lines[1].ServiceCharge != 100000 {
+		t.Errorf("Unexpected service charge split: %+v", result.Lines)
+	}
+	if result.Tax != 110000+240000 {
+		t.Errorf("Expected tax 3500.00, got %s", result.Tax)
+	}
+	// 31100.00 subtotal + 2400.00 exclusive tax + 1500.00 service charge
+	if result.Total != 3500000 {
+		t.Errorf("Expected total 35000.00, got %s", result.Total)
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"errors"
+	"kasir-api/internal/model"
+	"kasir-api/internal/repository"
+)
+
+type TaxClassService interface {
+	Create(taxClass model.TaxClass) (model.TaxClass, error)
+	GetAll() ([]model.TaxClass, error)
+	GetByID(id int) (model.TaxClass, error)
+	Update(id int, taxClass model.TaxClass) (model.TaxClass, error)
+	Delete(id int) error
+}
+
+type taxClassService struct {
+	repo repository.TaxClassRepository
+}
+
+func NewTaxClassService(repo repository.TaxClassRepository) TaxClassService {
+	return &taxClassService{repo: repo}
+}
+
+func (s *taxClassService) Create(taxClass model.TaxClass) (model.TaxClass, error) {
+	if err := validateTaxClass(taxClass); err != nil {
+		return model.TaxClass{}, err
+	}
+	return s.repo.Create(taxClass)
+}
+
+func (s *taxClassService) GetAll() ([]model.TaxClass, error) {
+	return s.repo.GetAll()
+}
+
+func (s *taxClassService) GetByID(id int) (model.TaxClass, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *taxClassService) Update(id int, taxClass model.TaxClass) (model.TaxClass, error) {
+	if err := validateTaxClass(taxClass); err != nil {
+		return model.TaxClass{}, err
+	}
+	return s.repo.Update(id, taxClass)
+}
+
+func (s *taxClassService) Delete(id int) error {
+	return s.repo.Delete(id)
+}
+
+func validateTaxClass(taxClass model.TaxClass) error {
+	if taxClass.Name == "" {
+		return errors.New("tax class name is required")
+	}
+	if taxClass.Rate < 0 || taxClass.Rate > 10000 {
+		return errors.New("rate must be between 0 and 100")
+	}
+	return nil
+}
@@ -2,57 +2,750 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"kasir-api/internal/model"
 	"kasir-api/internal/repository"
+	"sort"
+	"strings"
 	"time"
 )
 
 type TransactionService interface {
 	CreateTransaction(ctx context.Context, request model.TransactionRequest) (model.Transaction, error)
 	GetDailyReport(ctx context.Context) (model.DailyReport, error)
+	GetTransactions(ctx context.Context, filter model.TransactionFilter) ([]model.Transaction, model.Pagination, error)
+	GetTransactionByID(ctx context.Context, id int) (model.Transaction, error)
+	VoidTransaction(ctx context.Context, id int, request model.RefundRequest) (model.Refund, error)
+	RefundTransaction(ctx context.Context, id int, request model.RefundRequest) (model.Refund, error)
+	GetRefunds(ctx context.Context, id int) ([]model.Refund, error)
+	GetTaxReport(ctx context.Context, from, to time.Time) ([]model.TaxReport, error)
+	GetSalesReport(ctx context.Context, from, to time.Time, groupBy string) (model.SalesReport, error)
+	GetProductSalesReport(ctx context.Context, from, to time.Time, limit int) (model.SalesBreakdown, error)
+	GetCategorySalesReport(ctx context.Context, from, to time.Time, limit int) (model.SalesBreakdown, error)
+	GetProfitReport(ctx context.Context, from, to time.Time, groupBy string) (model.ProfitReport, error)
 }
 
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+
+	// maxReportDays bounds report ranges so a typo cannot scan years of data
+	// day by day.
+	maxDailyReportDays = 366
+	maxReportDays      = 366 * 5
+
+	defaultRankingLimit = 10
+	maxRankingLimit     = 100
+)
+
 type transactionService struct {
-	repo        repository.TransactionRepository
-	productRepo repository.ProductRepository
+	repo              repository.TransactionRepository
+	productRepo       repository.ProductRepository
+	promotionRepo     repository.PromotionRepository
+	taxClassRepo      repository.TaxClassRepository
+	shiftRepo         repository.ShiftRepository
+	customerRepo      repository.CustomerRepository
+	serviceChargeRate model.Rate
+	calendar          model.BusinessCalendar
+}
+
+func NewTransactionService(repo repository.TransactionRepository, productRepo repository.ProductRepository, promotionRepo repository.PromotionRepository, taxClassRepo repository.TaxClassRepository, shiftRepo repository.ShiftRepository, customerRepo repository.CustomerRepository, serviceChargeRate model.Rate, calendar model.BusinessCalendar) TransactionService {
+	return &transactionService{
+		repo:              repo,
+		productRepo:       productRepo,
+		promotionRepo:     promotionRepo,
+		taxClassRepo:      taxClassRepo,
+		shiftRepo:         shiftRepo,
+		customerRepo:      customerRepo,
+		serviceChargeRate: serviceChargeRate,
+		calendar:          calendar,
+	}
 }
 
-func NewTransactionService(repo repository.TransactionRepository, productRepo repository.ProductRepository) TransactionService {
-	return &transactionService{repo: repo, productRepo: productRepo}
+// resolvedItem is a checkout item matched to its product. conversion is the
+// pack it is sold in, or nil when it is sold in the product's own unit.
+type resolvedItem struct {
+	product    model.Product
+	conversion *model.UnitConversion
+	quantity   model.Quantity
+}
+
+// resolveItem finds the product for a checkout item, the unit it is sold in
+// and how much of it is bought. A barcode is looked up as registered first,
+// which also picks the pack when it is a pack's barcode; failing that, a
+// scale label resolves to the product registered under its item code, and
+// the quantity comes from the weight or price printed on it. A parent
+// product resolves to the variant with the item's attributes.
+func (s *transactionService) resolveItem(item model.TransactionRequestItem) (resolvedItem, error) {
+	unit := strings.ToLower(strings.TrimSpace(item.Unit))
+	if item.Barcode == "" {
+		product, err := s.productRepo.GetByID(item.ProductID)
+		if err != nil {
+			return resolvedItem{}, fmt.Errorf("product not found: %d", item.ProductID)
+		}
+		if product, err = pickVariant(product, item.Attributes); err != nil {
+			return resolvedItem{}, err
+		}
+		return sellIn(product, unit, item.Quantity)
+	}
+
+	if item.ProductID != 0 {
+		return resolvedItem{}, errors.New("give either product_id or barcode for an item, not both")
+	}
+	code, err := model.NormalizeBarcode(item.Barcode)
+	if err != nil {
+		return resolvedItem{}, err
+	}
+	if match, err := s.productRepo.GetByBarcode(code); err == nil {
+		product, err := pickVariant(match.Product, item.Attributes)
+		if err != nil {
+			return resolvedItem{}, err
+		}
+		if match.Conversion == nil {
+			return sellIn(product, unit, item.Quantity)
+		}
+		if product.ID != match.Product.ID {
+			return resolvedItem{}, fmt.Errorf("barcode %s is for a pack of %s, not of one of its variants", code, match.Product.Name)
+		}
+		if unit != "" && unit != match.Conversion.Unit {
+			return resolvedItem{}, fmt.Errorf("barcode %s is for a %s, not a %s", code, match.Conversion.Unit, unit)
+		}
+		return resolvedItem{product: match.Product, conversion: match.Conversion, quantity: item.Quantity}, nil
+	}
+
+	label, ok := model.ParseScaleBarcode(code)
+	if !ok {
+		return resolvedItem{}, fmt.Errorf("product not found for barcode: %s", code)
+	}
+	match, err := s.productRepo.GetByBarcode(label.ItemCode)
+	if err != nil || match.Conversion != nil {
+		return resolvedItem{}, fmt.Errorf("product not found for barcode: %s", code)
+	}
+	if item.Quantity != 0 {
+		return resolvedItem{}, fmt.Errorf("quantity cannot be given with scale barcode %s", code)
+	}
+	if unit != "" && unit != match.Product.Unit {
+		return resolvedItem{}, fmt.Errorf("unit cannot be given with scale barcode %s", code)
+	}
+	product, err := pickVariant(match.Product, item.Attributes)
+	if err != nil {
+		return resolvedItem{}, err
+	}
+	return resolvedItem{product: product, quantity: label.QuantityAt(product.Price)}, nil
+}
+
+// pickVariant returns the variant of product with the given attributes. A
+// product without variants is returned as it is, provided the attributes are
+// empty or its own.
+func pickVariant(product model.Product, attributes map[string]string) (model.Product, error) {
+	if len(product.Variants) == 0 {
+		if len(attributes) > 0 && !product.HasAttributes(attributes) {
+			return model.Product{}, fmt.Errorf("%s has no variant with those attributes", product.Name)
+		}
+		return product, nil
+	}
+	if len(attributes) == 0 {
+		return model.Product{}, fmt.Errorf("choose a variant of %s by its %s", product.Name, strings.Join(product.VariantAttributes, ", "))
+	}
+	for _, v := range product.Variants {
+		if v.HasAttributes(attributes) {
+			return v, nil
+		}
+	}
+	return model.Product{}, fmt.Errorf("%s has no variant with those attributes", product.Name)
+}
+
+// sellIn resolves unit to one of the product's packs. An empty unit or the
+// product's own unit sells it loose.
+func sellIn(product model.Product, unit string, quantity model.Quantity) (resolvedItem, error) {
+	item := resolvedItem{product: product, quantity: quantity}
+	if unit == "" || unit == product.Unit {
+		return item, nil
+	}
+	c, ok := product.Conversion(unit)
+	if !ok {
+		return resolvedItem{}, fmt.Errorf("%s is not sold by the %s", product.Name, unit)
+	}
+	item.conversion = &c
+	return item, nil
+}
+
+// tieredPrice picks the lowest unit price for a customer on level buying
+// quantity of the product's own unit: the product's price, or any retail or
+// level tier whose minimum quantity is reached. It also returns the tier the
+// price came from, or nil for the product's price.
+func tieredPrice(product model.Product, level string, quantity model.Quantity) (model.Money, *model.PriceTier) {
+	price := product.Price
+	var applied *model.PriceTier
+	for i, t := range product.PriceTiers {
+		if t.Level != model.PriceLevelRetail && t.Level != level {
+			continue
+		}
+		if quantity >= t.MinQuantity && t.Price < price {
+			price, applied = t.Price, &product.PriceTiers[i]
+		}
+	}
+	return price, applied
+}
+
+// packPrice is what a customer on level pays for one pack: the level's pack
+// price when it has one below the pack price, otherwise the pack price. It
+// also reports whether the level's price was used.
+func packPrice(c model.UnitConversion, level string) (model.Money, bool) {
+	if price, ok := c.LevelPrices[level]; ok && price < c.Price {
+		return price, true
+	}
+	return c.Price, false
 }
 
 func (s *transactionService) CreateTransaction(ctx context.Context, request model.TransactionRequest) (model.Transaction, error) {
-	var totalAmount float64
-	var details []model.TransactionDetail
+	if len(request.Items) == 0 {
+		return model.Transaction{}, errors.New("at least one item is required")
+	}
 
+	shiftID, err := resolveShift(ctx, s.shiftRepo, request.ShiftID)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	level := model.PriceLevelRetail
+	var customerID *int
+	if request.CustomerID != 0 {
+		customer, err := s.customerRepo.GetByID(request.CustomerID)
+		if err != nil {
+			return model.Transaction{}, fmt.Errorf("customer not found: %d", request.CustomerID)
+		}
+		level, customerID = customer.PriceLevel, &customer.ID
+	}
+
+	// Merge repeated products into one line per unit so promotions see the
+	// full quantity
+	type lineKey struct {
+		productID int
+		unit      string
+	}
+	var lines []pricedLine
+	var products []model.Product
+	var packs []*model.UnitConversion
+	lineByKey := map[lineKey]int{}
 	for _, item := range request.Items {
-		product, err := s.productRepo.GetByID(item.ProductID)
+		resolved, err := s.resolveItem(item)
 		if err != nil {
-			return model.Transaction{}, fmt.Errorf("product not found: %d", item.ProductID)
+			return model.Transaction{}, err
+		}
+		product, quantity := resolved.product, resolved.quantity
+		if quantity <= 0 {
+			return model.Transaction{}, errors.New("quantity must be greater than zero")
 		}
 
-		if product.Stock < item.Quantity {
-			return model.Transaction{}, fmt.Errorf("insufficient stock for product: %s", product.Name)
+		line := pricedLine{ProductID: product.ID, Quantity: quantity, UnitPrice: product.Price}
+		key := lineKey{productID: product.ID, unit: product.Unit}
+		if c := resolved.conversion; c != nil {
+			if !quantity.IsWhole() {
+				return model.Transaction{}, fmt.Errorf("%s of %s are sold whole", c.Unit, product.Name)
+			}
+			line.UnitPrice, line.Packed = c.Price, true
+			key.unit = c.Unit
+		} else if !model.IsFractionalUnit(product.Unit) && !quantity.IsWhole() {
+			return model.Transaction{}, fmt.Errorf("%s is sold in whole units", product.Name)
 		}
 
-		subtotal := product.Price * float64(item.Quantity)
-		totalAmount += subtotal
+		// Stock is checked by the repository while the product row is locked
+
+		if i, ok := lineByKey[key]; ok {
+			lines[i].Quantity += quantity
+			continue
+		}
+		lineByKey[key] = len(lines)
+		lines = append(lines, line)
+		products = append(products, product)
+		packs = append(packs, resolved.conversion)
+	}
 
+	// Quantity breaks are judged on the merged line. Packs have their own
+	// price per level and are not tiered.
+	tiers := make([]*model.PriceTier, len(lines))
+	levelPriced := make([]bool, len(lines))
+	for i := range lines {
+		if c := packs[i]; c != nil {
+			lines[i].UnitPrice, levelPriced[i] = packPrice(*c, level)
+		} else {
+			lines[i].UnitPrice, tiers[i] = tieredPrice(products[i], level, lines[i].Quantity)
+		}
+	}
+
+	// Promotion time windows are in store time
+	now := time.Now().In(s.calendar.Location)
+	promotions, err := s.promotionRepo.GetActive(now)
+	if err != nil {
+		return model.Transaction{}, fmt.Errorf("failed to load promotions: %w", err)
+	}
+	discounts := applyPromotions(lines, promotions, now)
+
+	taxClasses, err := s.taxClassRepo.GetAll()
+	if err != nil {
+		return model.Transaction{}, fmt.Errorf("failed to load tax classes: %w", err)
+	}
+	taxClassByID := map[int]model.TaxClass{}
+	for _, tc := range taxClasses {
+		taxClassByID[tc.ID] = tc
+	}
+
+	taxLines := make([]taxLine, len(lines))
+	for i, line := range lines {
+		taxLines[i].Amount = line.gross() - discounts.Lines[i].Amount
+		if id := products[i].TaxClassID; id != nil {
+			tc := taxClassByID[*id]
+			taxLines[i].Rate = tc.Rate
+			taxLines[i].Inclusive = tc.Inclusive
+		}
+	}
+	taxes := calculateTax(taxLines, s.serviceChargeRate)
+
+	var details []model.TransactionDetail
+	for i, line := range lines {
+		unit, baseQuantity, unitCost := products[i].Unit, line.Quantity, products[i].CostPrice
+		if c := packs[i]; c != nil {
+			unit, baseQuantity, unitCost = c.Unit, line.Quantity.Mul(c.Factor), unitCost.MulQuantity(c.Factor)
+		}
+		var tierID int
+		var tierLevel string
+		var tierMinQuantity *model.Quantity
+		if t := tiers[i]; t != nil {
+			minQuantity := t.MinQuantity
+			tierID, tierLevel, tierMinQuantity = t.ID, t.Level, &minQuantity
+		} else if levelPriced[i] {
+			tierLevel = level
+		}
 		details = append(details, model.TransactionDetail{
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			Subtotal:  subtotal,
+			ProductID:       line.ProductID,
+			ProductName:     products[i].Name,
+			CategoryID:      products[i].CategoryID,
+			Unit:            unit,
+			Quantity:        line.Quantity,
+			BaseQuantity:    baseQuantity,
+			UnitPrice:       line.UnitPrice,
+			PriceTierID:     tierID,
+			PriceLevel:      tierLevel,
+			TierMinQuantity: tierMinQuantity,
+			DiscountAmount:  discounts.Lines[i].Amount,
+			Subtotal:        taxLines[i].Amount,
+			UnitCost:        unitCost,
+			TaxRate:         taxLines[i].Rate,
+			TaxInclusive:    taxLines[i].Inclusive,
+			TaxAmount:       taxes.Lines[i].Tax,
+			ServiceCharge:   taxes.Lines[i].ServiceCharge,
+			Discounts:       discounts.Lines[i].Applied,
 		})
 	}
 
+	payments, change, err := allocatePayments(taxes.Total, request.Payments)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
 	transaction := model.Transaction{
-		TotalAmount: totalAmount,
+		ShiftID:        &shiftID,
+		CustomerID:     customerID,
+		PriceLevel:     level,
+		Subtotal:       taxes.Subtotal,
+		TaxAmount:      taxes.Tax,
+		ServiceCharge:  taxes.ServiceCharge,
+		TotalAmount:    taxes.Total,
+		DiscountAmount: discounts.Total,
+		Discounts:      discounts.Cart,
+		Payments:       payments,
+		Change:         change,
 	}
 
 	return s.repo.CreateTransaction(ctx, transaction, details)
 }
 
 func (s *transactionService) GetDailyReport(ctx context.Context) (model.DailyReport, error) {
-	return s.repo.GetDailyReport(ctx, time.Now())
+	return s.repo.GetDailyReport(ctx, s.calendar.DateOf(time.Now()))
+}
+
+// reportRange fills in a missing report range with the current business day.
+// from and to are business dates and to is exclusive.
+func (s *transactionService) reportRange(from, to time.Time) (time.Time, time.Time) {
+	today := s.calendar.DateOf(time.Now())
+	if from.IsZero() {
+		from = today
+	}
+	if to.IsZero() {
+		to = today.AddDate(0, 0, 1)
+	}
+	return from, to
+}
+
+func (s *transactionService) GetTaxReport(ctx context.Context, from, to time.Time) ([]model.TaxReport, error) {
+	from, to = s.reportRange(from, to)
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", model.ErrInvalidReportRange)
+	}
+	return s.repo.GetTaxReport(ctx, from, to)
+}
+
+func (s *transactionService) GetSalesReport(ctx context.Context, from, to time.Time, groupBy string) (model.SalesReport, error) {
+	if groupBy == "" {
+		groupBy = model.GroupByDay
+	}
+	if groupBy != model.GroupByDay && groupBy != model.GroupByWeek && groupBy != model.GroupByMonth {
+		return model.SalesReport{}, fmt.Errorf("%w: %s", model.ErrInvalidReportGrouping, groupBy)
+	}
+	from, to = s.reportRange(from, to)
+	if !from.Before(to) {
+		return model.SalesReport{}, fmt.Errorf("%w: from must be before to", model.ErrInvalidReportRange)
+	}
+	days := int(to.Sub(from).Hours() / 24)
+	if groupBy == model.GroupByDay && days > maxDailyReportDays {
+		return model.SalesReport{}, fmt.Errorf("%w: daily reports are limited to %d days", model.ErrInvalidReportRange, maxDailyReportDays)
+	}
+	if days > maxReportDays {
+		return model.SalesReport{}, fmt.Errorf("%w: reports are limited to %d days", model.ErrInvalidReportRange, maxReportDays)
+	}
+
+	periods, err := s.repo.GetSalesByPeriod(ctx, from, to, groupBy)
+	if err != nil {
+		return model.SalesReport{}, err
+	}
+	return model.SalesReport{
+		From:    from.Format("2006-01-02"),
+		To:      to.AddDate(0, 0, -1).Format("2006-01-02"),
+		GroupBy: groupBy,
+		Periods: periods,
+	}, nil
+}
+
+func (s *transactionService) GetProductSalesReport(ctx context.Context, from, to time.Time, limit int) (model.SalesBreakdown, error) {
+	from, to = s.reportRange(from, to)
+	if err := checkBreakdownRange(from, to); err != nil {
+		return model.SalesBreakdown{}, err
+	}
+	items, err := s.repo.GetProductSales(ctx, from, to)
+	if err != nil {
+		return model.SalesBreakdown{}, err
+	}
+	return rankSales(from, to, rollUpVariants(items), limit), nil
+}
+
+func (s *transactionService) GetCategorySalesReport(ctx context.Context, from, to time.Time, limit int) (model.SalesBreakdown, error) {
+	from, to = s.reportRange(from, to)
+	if err := checkBreakdownRange(from, to); err != nil {
+		return model.SalesBreakdown{}, err
+	}
+	items, err := s.repo.GetCategorySales(ctx, from, to)
+	if err != nil {
+		return model.SalesBreakdown{}, err
+	}
+	return rankSales(from, to, items, limit), nil
+}
+
+// checkBreakdownRange rejects an empty range and one longer than
+// maxReportDays with ErrInvalidReportRange.
+func checkBreakdownRange(from, to time.Time) error {
+	if !from.Before(to) {
+		return fmt.Errorf("%w: from must be before to", model.ErrInvalidReportRange)
+	}
+	if int(to.Sub(from).Hours()/24) > maxReportDays {
+		return fmt.Errorf("%w: reports are limited to %d days", model.ErrInvalidReportRange, maxReportDays)
+	}
+	return nil
+}
+
+// rankSales works out each item's share of revenue and quantity in packs, and
+// picks the limit best and worst sellers. Ties are broken by quantity and then by name so the
+// ranking is stable. Only items that sold are eligible for Top.
+func rankSales(from, to time.Time, items []model.ItemSales, limit int) model.SalesBreakdown {
+	if limit < 1 {
+		limit = defaultRankingLimit
+	}
+	if limit > maxRankingLimit {
+		limit = maxRankingLimit
+	}
+
+	breakdown := model.SalesBreakdown{
+		From:   from.Format("2006-01-02"),
+		To:     to.AddDate(0, 0, -1).Format("2006-01-02"),
+		Top:    []model.ItemSales{},
+		Bottom: []model.ItemSales{},
+	}
+	for _, item := range items {
+		breakdown.TotalQuantity += item.Quantity
+		breakdown.TotalRevenue += item.Revenue
+	}
+
+	ranked := make([]model.ItemSales, len(items))
+	for i, item := range items {
+		item.Share = model.ShareOf(item.Revenue, breakdown.TotalRevenue)
+		item.Packs = inPacks(item.Quantity, item.Packs)
+		if len(item.Variants) > 0 {
+			variants := make([]model.ItemSales, len(item.Variants))
+			for k, v := range item.Variants {
+				v.Share = model.ShareOf(v.Revenue, breakdown.TotalRevenue)
+				v.Packs = inPacks(v.Quantity, v.Packs)
+				variants[k] = v
+			}
+			item.Variants = variants
+		}
+		ranked[i] = item
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Revenue != b.Revenue {
+			return a.Revenue > b.Revenue
+		}
+		if a.Quantity != b.Quantity {
+			return a.Quantity > b.Quantity
+		}
+		return a.Name < b.Name
+	})
+
+	for _, item := range ranked {
+		if len(breakdown.Top) == limit || item.Quantity == 0 {
+			break
+		}
+		breakdown.Top = append(breakdown.Top, item)
+	}
+	for i := len(ranked) - 1; i >= 0 && len(breakdown.Bottom) < limit; i-- {
+		breakdown.Bottom = append(breakdown.Bottom, ranked[i])
+	}
+	return breakdown
+}
+
+// rollUpVariants adds the sales of each variant into its parent product and
+// lists the variant under the parent's Variants.
+func rollUpVariants(items []model.ItemSales) []model.ItemSales {
+	rolled := []model.ItemSales{}
+	index := map[int]int{}
+	for _, item := range items {
+		if item.ParentID == 0 {
+			index[item.ID] = len(rolled)
+			rolled = append(rolled, item)
+		}
+	}
+	for _, item := range items {
+		if item.ParentID == 0 {
+			continue
+		}
+		i, ok := index[item.ParentID]
+		if !ok {
+			rolled = append(rolled, item)
+			continue
+		}
+		rolled[i].Quantity += item.Quantity
+		rolled[i].Revenue += item.Revenue
+		rolled[i].Variants = append(rolled[i].Variants, item)
+	}
+	return rolled
+}
+
+// inPacks expresses quantity in each of the packs, rounded to the nearest
+// thousandth of a pack.
+func inPacks(quantity model.Quantity, packs []model.PackQuantity) []model.PackQuantity {
+	if len(packs) == 0 {
+		return nil
+	}
+	converted := make([]model.PackQuantity, len(packs))
+	for i, p := range packs {
+		p.Quantity = quantity.Div(p.Factor)
+		converted[i] = p
+	}
+	return converted
+}
+
+func (s *transactionService) GetProfitReport(ctx context.Context, from, to time.Time, groupBy string) (model.ProfitReport, error) {
+	if groupBy == "" {
+		groupBy = model.GroupByProduct
+	}
+	if groupBy != model.GroupByProduct && groupBy != model.GroupByCategory && groupBy != model.GroupByDay {
+		return model.ProfitReport{}, fmt.Errorf("%w: %s", model.ErrInvalidReportGrouping, groupBy)
+	}
+	from, to = s.reportRange(from, to)
+	if err := checkBreakdownRange(from, to); err != nil {
+		return model.ProfitReport{}, err
+	}
+
+	rows, err := s.repo.GetProfit(ctx, from, to, groupBy)
+	if err != nil {
+		return model.ProfitReport{}, err
+	}
+	return summarizeProfit(from, to, groupBy, rows), nil
+}
+
+// summarizeProfit fills in the gross profit and margin of each row and the
+// report totals.
+func summarizeProfit(from, to time.Time, groupBy string, rows []model.ProfitRow) model.ProfitReport {
+	report := model.ProfitReport{
+		From:    from.Format("2006-01-02"),
+		To:      to.AddDate(0, 0, -1).Format("2006-01-02"),
+		GroupBy: groupBy,
+		Rows:    rows,
+	}
+	for i := range report.Rows {
+		row := &report.Rows[i]
+		row.GrossProfit = row.Revenue - row.Cost
+		row.Margin = model.ShareOf(row.GrossProfit, row.Revenue)
+		report.Revenue += row.Revenue
+		report.Cost += row.Cost
+	}
+	report.GrossProfit = report.Revenue - report.Cost
+	report.Margin = model.ShareOf(report.GrossProfit, report.Revenue)
+	return report
+}
+
+func (s *transactionService) GetTransactions(ctx context.Context, filter model.TransactionFilter) ([]model.Transaction, model.Pagination, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = defaultPageLimit
+	}
+	if filter.Limit > maxPageLimit {
+		filter.Limit = maxPageLimit
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, model.Pagination{}, errors.New("from must be before to")
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return nil, model.Pagination{}, errors.New("min_amount cannot be greater than max_amount")
+	}
+
+	transactions, total, err := s.repo.GetAll(ctx, filter)
+	if err != nil {
+		return nil, model.Pagination{}, err
+	}
+
+	pagination := model.Pagination{
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+		Total:      total,
+		TotalPages: (total + filter.Limit - 1) / filter.Limit,
+	}
+	return transactions, pagination, nil
+}
+
+func (s *transactionService) GetTransactionByID(ctx context.Context, id int) (model.Transaction, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *transactionService) VoidTransaction(ctx context.Context, id int, request model.RefundRequest) (model.Refund, error) {
+	if err := validateReasonCode(request.ReasonCode); err != nil {
+		return model.Refund{}, err
+	}
+	if request.Method != "" && !isPaymentMethod(request.Method) {
+		return model.Refund{}, fmt.Errorf("invalid refund method: %s", request.Method)
+	}
+	shiftID, err := resolveShift(ctx, s.shiftRepo, request.ShiftID)
+	if err != nil {
+		return model.Refund{}, err
+	}
+	request.ShiftID = shiftID
+
+	// A void always reverses the whole sale
+	request.Items = nil
+	return s.repo.CreateRefund(ctx, id, model.RefundTypeVoid, request)
+}
+
+func (s *transactionService) RefundTransaction(ctx context.Context, id int, request model.RefundRequest) (model.Refund, error) {
+	if err := validateReasonCode(request.ReasonCode); err != nil {
+		return model.Refund{}, err
+	}
+	if request.Method != "" && !isPaymentMethod(request.Method) {
+		return model.Refund{}, fmt.Errorf("invalid refund method: %s", request.Method)
+	}
+	for _, item := range request.Items {
+		if item.TransactionDetailID == 0 {
+			return model.Refund{}, errors.New("transaction detail id is required")
+		}
+		if item.Quantity <= 0 {
+			return model.Refund{}, errors.New("refund quantity must be greater than zero")
+		}
+	}
+
+	shiftID, err := resolveShift(ctx, s.shiftRepo, request.ShiftID)
+	if err != nil {
+		return model.Refund{}, err
+	}
+	request.ShiftID = shiftID
+	return s.repo.CreateRefund(ctx, id, model.RefundTypeRefund, request)
+}
+
+func (s *transactionService) GetRefunds(ctx context.Context, id int) ([]model.Refund, error) {
+	return s.repo.GetRefunds(ctx, id)
+}
+
+// allocatePayments settles total with the requested tenders. Non-cash tenders
+// are applied first and must not exceed what is owed, since they cannot give
+// change. Cash covers the remainder and any excess is returned as change.
+func allocatePayments(total model.Money, requests []model.PaymentRequest) ([]model.Payment, model.Money, error) {
+	if len(requests) == 0 {
+		if total == 0 {
+			return nil, 0, nil
+		}
+		return nil, 0, errors.New("at least one payment is required")
+	}
+
+	var payments []model.Payment
+	var nonCash, cash model.Money
+	for _, req := range requests {
+		if !isPaymentMethod(req.Method) {
+			return nil, 0, fmt.Errorf("invalid payment method: %s", req.Method)
+		}
+		if req.Tendered <= 0 {
+			return nil, 0, errors.New("tendered amount must be greater than zero")
+		}
+		if req.Method == model.PaymentMethodCash {
+			cash += req.Tendered
+		} else {
+			nonCash += req.Tendered
+		}
+	}
+
+	if nonCash > total {
+		return nil, 0, errors.New("non-cash payments exceed the total amount")
+	}
+	if nonCash+cash < total {
+		return nil, 0, fmt.Errorf("insufficient payment: total %s, paid %s", total, nonCash+cash)
+	}
+
+	remaining := total - nonCash
+	for _, req := range requests {
+		payment := model.Payment{
+			Method:    req.Method,
+			Amount:    req.Tendered,
+			Tendered:  req.Tendered,
+			Reference: req.Reference,
+		}
+		if req.Method == model.PaymentMethodCash {
+			payment.Amount = min(req.Tendered, remaining)
+			payment.Change = req.Tendered - payment.Amount
+			remaining -= payment.Amount
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, cash - (total - nonCash), nil
+}
+
+func isPaymentMethod(method string) bool {
+	for _, m := range model.PaymentMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func validateReasonCode(code string) error {
+	if code == "" {
+		return errors.New("reason code is required")
+	}
+	for _, c := range model.RefundReasonCodes {
+		if c == code {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid reason code: %s", code)
 }
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"errors"
+	"kasir-api/internal/model"
+	"testing"
+	"time"
+)
+
+func TestAllocatePaymentsCashChange(t *testing.T) {
+	payments, change, err := allocatePayments(4250000, []model.PaymentRequest{
+		{Method: model.PaymentMethodCash, Tendered: 5000000},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if change != 750000 {
+		t.Errorf("Expected change 7500.00, got %s", change)
+	}
+	if payments[0].Amount != 4250000 || payments[0].Change != 750000 {
+		t.Errorf("Unexpected payment: %+v", payments[0])
+	}
+}
+
+func TestAllocatePaymentsSplitTender(t *testing.T) {
+	payments, change, err := allocatePayments(10000000, []model.PaymentRequest{
+		{Method: model.PaymentMethodCash, Tendered: 5000000},
+		{Method: model.PaymentMethodQRIS, Tendered: 6000000, Reference: "QR-1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// QRIS is applied first, so cash only has to cover 40000.00
+	if change != 1000000 {
+		t.Errorf("Expected change 10000.00, got %s", change)
+	}
+	if payments[0].Amount != 4000000 {
+		t.Errorf("Expected cash amount 40000.00, got %s", payments[0].Amount)
+	}
+	if payments[1].Amount != 6000000 || payments[1].Change != 0 {
+		t.Errorf("Unexpected QRIS payment: %+v", payments[1])
+	}
+}
+
+func TestAllocatePaymentsRejects(t *testing.T) {
+	tests := map[string][]model.PaymentRequest{
+		"no payments":        nil,
+		"underpayment":       {{Method: model.PaymentMethodCash, Tendered: 100}},
+		"card overpayment":   {{Method: model.PaymentMethodDebitCard, Tendered: 2000}},
+		"unknown method":     {{Method: "cheque", Tendered: 1000}},
+		"zero tendered":      {{Method: model.PaymentMethodCash, Tendered: 0}},
+		"split underpayment": {{Method: model.PaymentMethodEWallet, Tendered: 500}, {Method: model.PaymentMethodCash, Tendered: 400}},
+	}
+	for name, requests := range tests {
+		if _, _, err := allocatePayments(1000, requests); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestRankSales(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	items := []model.ItemSales{
+		{ID: 1, Name: "Kopi", Quantity: model.WholeQuantity(10), Revenue: 5000000},
+		{ID: 2, Name: "Teh", Quantity: model.WholeQuantity(20), Revenue: 3000000},
+		{ID: 3, Name: "Roti", Quantity: model.WholeQuantity(5), Revenue: 2000000},
+		{ID: 4, Name: "Susu", Quantity: 0, Revenue: 0},
+	}
+
+	breakdown := rankSales(from, from.AddDate(0, 0, 7), items, 2)
+
+	if breakdown.From != "2026-03-01" || breakdown.To != "2026-03-07" {
+		t.Errorf("Unexpected range %s..%s", breakdown.From, breakdown.To)
+	}
+	if breakdown.TotalQuantity != model.WholeQuantity(35) || breakdown.TotalRevenue != 10000000 {
+		t.Errorf("Unexpected totals: %s, %s", breakdown.TotalQuantity, breakdown.TotalRevenue)
+	}
+	if len(breakdown.Top) != 2 || breakdown.Top[0].ID != 1 || breakdown.Top[1].ID != 2 {
+		t.Fatalf("Unexpected top: %+v", breakdown.Top)
+	}
+	if breakdown.Top[0].Share != 5000 {
+		t.Errorf("Expected share 50.00, got %s", breakdown.Top[0].Share)
+	}
+	if len(breakdown.Bottom) != 2 || breakdown.Bottom[0].ID != 4 || breakdown.Bottom[1].ID != 3 {
+		t.Errorf("Unexpected bottom: %+v", breakdown.Bottom)
+	}
+}
+
+func TestRankSalesInPacks(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	items := []model.ItemSales{{
+		ID: 1, Name: "Kopi", Unit: model.UnitPiece, Quantity: model.WholeQuantity(30), Revenue: 15000000,
+		Packs: []model.PackQuantity{{Unit: "box", Factor: model.WholeQuantity(12)}, {Unit: "carton", Factor: model.WholeQuantity(144)}},
+	}}
+
+	breakdown := rankSales(from, from.AddDate(0, 0, 1), items, 0)
+
+	packs := breakdown.Top[0].Packs
+	if len(packs) != 2 || packs[0].Quantity != 2500 || packs[1].Quantity != 208 {
+		t.Errorf("Unexpected pack quantities: %+v", packs)
+	}
+	if items[0].Packs[0].Quantity != 0 {
+		t.Error("rankSales modified its input")
+	}
+}
+
+func TestRankSalesNoSales(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	items := []model.ItemSales{{ID: 1, Name: "Kopi"}, {ID: 2, Name: "Teh"}}
+
+	breakdown := rankSales(from, from.AddDate(0, 0, 1), items, 0)
+
+	if len(breakdown.Top) != 0 {
+		t.Errorf("Expected no top sellers, got %+v", breakdown.Top)
+	}
+	if len(breakdown.Bottom) != 2 || breakdown.Bottom[0].Share != 0 {
+		t.Errorf("Unexpected bottom: %+v", breakdown.Bottom)
+	}
+}
+
+func TestSummarizeProfit(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	rows := []model.ProfitRow{
+		{ID: 1, Name: "Kopi", Quantity: model.WholeQuantity(10), Revenue: 5000000, Cost: 2000000},
+		{ID: 2, Name: "Promo", Quantity: model.WholeQuantity(1), Revenue: 100000, Cost: 150000},
+	}
+
+	report := summarizeProfit(from, from.AddDate(0, 0, 1), model.GroupByProduct, rows)
+
+	if report.Rows[0].GrossProfit != 3000000 || report.Rows[0].Margin != 6000 {
+		t.Errorf("Unexpected first row: %+v", report.Rows[0])
+	}
+	// Selling below cost gives a negative margin
+	if report.Rows[1].GrossProfit != -50000 || report.Rows[1].Margin != -5000 {
+		t.Errorf("Unexpected second row: %+v", report.Rows[1])
+	}
+	if report.Revenue != 5100000 || report.Cost != 2150000 || report.GrossProfit != 2950000 {
+		t.Errorf("Unexpected totals: %+v", report)
+	}
+	if report.Margin != 5784 {
+		t.Errorf("Expected margin 57.84, got %s", report.Margin)
+	}
+}
+
+func TestSummarizeProfitNoSales(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	report := summarizeProfit(from, from.AddDate(0, 0, 1), model.GroupByDay, []model.ProfitRow{})
+	if report.Margin != 0 || report.GrossProfit != 0 {
+		t.Errorf("Expected empty report, got %+v", report)
+	}
+}
+
+func TestTieredPrice(t *testing.T) {
+	product := model.Product{Price: 550000, PriceTiers: []model.PriceTier{
+		{ID: 1, Level: model.PriceLevelRetail, MinQuantity: model.WholeQuantity(12), Price: 500000},
+		{ID: 2, Level: model.PriceLevelReseller, Price: 480000},
+		{ID: 3, Level: model.PriceLevelReseller, MinQuantity: model.WholeQuantity(48), Price: 450000},
+		{ID: 4, Level: model.PriceLevelMember, Price: 530000},
+	}}
+
+	tests := []struct {
+		name     string
+		level    string
+		quantity model.Quantity
+		price    model.Money
+		tierID   int
+	}{
+		{"retail single", model.PriceLevelRetail, model.WholeQuantity(1), 550000, 0},
+		{"retail dozen", model.PriceLevelRetail, model.WholeQuantity(12), 500000, 1},
+		{"member single", model.PriceLevelMember, model.WholeQuantity(1), 530000, 4},
+		{"member gets the retail break", model.PriceLevelMember, model.WholeQuantity(12), 500000, 1},
+		{"reseller standing price", model.PriceLevelReseller, model.WholeQuantity(1), 480000, 2},
+		{"reseller break", model.PriceLevelReseller, model.WholeQuantity(50), 450000, 3},
+	}
+	for _, tt := range tests {
+		price, tier := tieredPrice(product, tt.level, tt.quantity)
+		tierID := 0
+		if tier != nil {
+			tierID = tier.ID
+		}
+		if price != tt.price || tierID != tt.tierID {
+			t.Errorf("%s: expected %s from tier %d, got %s from tier %d", tt.name, tt.price, tt.tierID, price, tierID)
+		}
+	}
+}
+
+func TestPackPrice(t *testing.T) {
+	box := model.UnitConversion{Unit: "box", Factor: model.WholeQuantity(12), Price: 6000000, LevelPrices: map[string]model.Money{
+		model.PriceLevelReseller: 5400000,
+		model.PriceLevelMember:   6200000,
+	}}
+
+	tests := []struct {
+		name        string
+		level       string
+		price       model.Money
+		levelPriced bool
+	}{
+		{"retail", model.PriceLevelRetail, 6000000, false},
+		{"reseller", model.PriceLevelReseller, 5400000, true},
+		{"member price above the pack price", model.PriceLevelMember, 6000000, false},
+	}
+	for _, tt := range tests {
+		price, levelPriced := packPrice(box, tt.level)
+		if price != tt.price || levelPriced != tt.levelPriced {
+			t.Errorf("%s: expected %s (level priced %v), got %s (%v)", tt.name, tt.price, tt.levelPriced, price, levelPriced)
+		}
+	}
+}
+
+func TestPickVariant(t *testing.T) {
+	parentID := 1
+	medium := model.Product{ID: 2, ParentID: &parentID, Attributes: map[string]string{"size": "M", "colour": "Merah"}}
+	large := model.Product{ID: 3, ParentID: &parentID, Attributes: map[string]string{"size": "L", "colour": "Merah"}}
+	parent := model.Product{ID: 1, Name: "Kaos Polos", VariantAttributes: []string{"size", "colour"}, Variants: []model.Product{medium, large}}
+
+	got, err := pickVariant(parent, map[string]string{"size": "L", "colour": "Merah"})
+	if err != nil || got.ID != 3 {
+		t.Errorf("Expected variant 3, got %d (%v)", got.ID, err)
+	}
+	if _, err := pickVariant(parent, nil); err == nil || err.Error() != "choose a variant of Kaos Polos by its size, colour" {
+		t.Errorf("Expected error when no variant is chosen, got %v", err)
+	}
+	if _, err := pickVariant(parent, map[string]string{"size": "XL", "colour": "Merah"}); err == nil || err.Error() != "Kaos Polos has no variant with those attributes" {
+		t.Errorf("Expected error for an unknown variant, got %v", err)
+	}
+
+	if got, err := pickVariant(medium, map[string]string{"size": "M", "colour": "Merah"}); err != nil || got.ID != 2 {
+		t.Errorf("Expected a variant to match its own attributes, got %d (%v)", got.ID, err)
+	}
+	plain := model.Product{ID: 4, Name: "Gula Pasir"}
+	if got, err := pickVariant(plain, nil); err != nil || got.ID != 4 {
+		t.Errorf("Expected product without variants as is, got %d (%v)", got.ID, err)
+	}
+	if _, err := pickVariant(plain, map[string]string{"size": "M"}); err == nil || err.Error() != "Gula Pasir has no variant with those attributes" {
+		t.Errorf("Expected error for attributes on a product without variants, got %v", err)
+	}
+}
+
+func TestRollUpVariants(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	items := []model.ItemSales{
+		{ID: 1, Name: "Kaos Polos", Quantity: 0, Revenue: 0},
+		{ID: 2, ParentID: 1, Name: "Kaos Polos M", Quantity: model.WholeQuantity(3), Revenue: 22500000},
+		{ID: 3, ParentID: 1, Name: "Kaos Polos L", Quantity: model.WholeQuantity(1), Revenue: 7500000},
+		{ID: 4, Name: "Gula Pasir", Quantity: model.WholeQuantity(10), Revenue: 20000000},
+	}
+
+	breakdown := rankSales(from, from.AddDate(0, 0, 1), rollUpVariants(items), 10)
+
+	if len(breakdown.Top) != 2 || breakdown.Top[0].ID != 1 {
+		t.Fatalf("Expected the parent to lead with its variants' sales, got %+v", breakdown.Top)
+	}
+	parent := breakdown.Top[0]
+	if parent.Quantity != model.WholeQuantity(4) || parent.Revenue != 30000000 || parent.Share != 6000 {
+		t.Errorf("Unexpected rolled-up figures: %+v", parent)
+	}
+	if len(parent.Variants) != 2 || parent.Variants[0].ID != 2 || parent.Variants[0].Share != 4500 {
+		t.Errorf("Unexpected variants: %+v", parent.Variants)
+	}
+	if breakdown.TotalQuantity != model.WholeQuantity(14) {
+		t.Errorf("Variants counted twice in total: %s", breakdown.TotalQuantity)
+	}
+}
+
+func TestCheckBreakdownRange(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := checkBreakdownRange(from, from.AddDate(0, 0, 7)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for name, to := range map[string]time.Time{
+		"reversed": from.AddDate(0, 0, -1),
+		"too long": from.AddDate(0, 0, maxReportDays+1),
+	} {
+		if err := checkBreakdownRange(from, to); !errors.Is(err, model.ErrInvalidReportRange) {
+			t.Errorf("%s: expected ErrInvalidReportRange, got %v", name, err)
+		}
+	}
+}
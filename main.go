@@ -6,11 +6,14 @@ import (
 	"kasir-api/config"
 	"kasir-api/internal/database"
 	"kasir-api/internal/handler"
+	"kasir-api/internal/model"
+	"kasir-api/internal/receipt"
 	"kasir-api/internal/repository"
 	"kasir-api/internal/service"
 	"log"
 	"net/http"
 	"os"
+	_ "time/tzdata"
 )
 
 func main() {
@@ -29,6 +32,29 @@ func main() {
 		fmt.Println("Debug DB Source (Masked):", cfg.DBSource[:15]+"...")
 	}
 
+	var serviceChargeRate model.Rate
+	if cfg.ServiceChargePercent != "" {
+		serviceChargeRate, err = model.ParseRate(cfg.ServiceChargePercent)
+		if err != nil {
+			log.Fatalf("invalid SERVICE_CHARGE_PERCENT: %v", err)
+		}
+	}
+
+	calendar, err := model.NewBusinessCalendar(cfg.StoreTimezone, cfg.BusinessDayCutoff)
+	if err != nil {
+		log.Fatalf("invalid store timezone or business day cutoff: %v", err)
+	}
+
+	receipts, err := receipt.NewRenderer(receipt.Store{
+		Name:    cfg.StoreName,
+		Address: cfg.StoreAddress,
+		Phone:   cfg.StorePhone,
+		Footer:  cfg.ReceiptFooter,
+	}, cfg.ReceiptTemplate)
+	if err != nil {
+		log.Fatalf("cannot load receipt template: %v", err)
+	}
+
 	// Initialize Database
 	db, err := database.NewPostgresDB(cfg.DBDriver, cfg.DBSource)
 	if err != nil {
@@ -43,11 +69,45 @@ func main() {
 
 	productRepo := repository.NewProductRepository(db)
 	productSvc := service.NewProductService(productRepo)
-	productHandler := handler.NewProductHandler(productSvc)
+	stockRepo := repository.NewStockMovementRepository(db)
+	stockSvc := service.NewStockService(stockRepo, productRepo, calendar)
+	productHandler := handler.NewProductHandler(productSvc, stockSvc)
+
+	promotionRepo := repository.NewPromotionRepository(db)
+	promotionSvc := service.NewPromotionService(promotionRepo, productRepo)
+	promotionHandler := handler.NewPromotionHandler(promotionSvc)
+
+	taxClassRepo := repository.NewTaxClassRepository(db)
+	taxClassSvc := service.NewTaxClassService(taxClassRepo)
+	taxClassHandler := handler.NewTaxClassHandler(taxClassSvc)
+
+	shiftRepo := repository.NewShiftRepository(db)
+	shiftSvc := service.NewShiftService(shiftRepo)
+	shiftHandler := handler.NewShiftHandler(shiftSvc)
 
-	transactionRepo := repository.NewTransactionRepository(db)
-	transactionSvc := service.NewTransactionService(transactionRepo, productRepo)
-	transactionHandler := handler.NewTransactionHandler(transactionSvc)
+	stocktakeRepo := repository.NewStocktakeRepository(db)
+	stocktakeSvc := service.NewStocktakeService(stocktakeRepo, productRepo)
+	stocktakeHandler := handler.NewStocktakeHandler(stocktakeSvc)
+
+	supplierRepo := repository.NewSupplierRepository(db)
+	supplierSvc := service.NewSupplierService(supplierRepo)
+	supplierHandler := handler.NewSupplierHandler(supplierSvc)
+
+	purchaseOrderRepo := repository.NewPurchaseOrderRepository(db)
+	purchaseOrderSvc := service.NewPurchaseOrderService(purchaseOrderRepo, supplierRepo, productRepo)
+	purchaseOrderHandler := handler.NewPurchaseOrderHandler(purchaseOrderSvc)
+
+	customerRepo := repository.NewCustomerRepository(db)
+	customerSvc := service.NewCustomerService(customerRepo)
+	customerHandler := handler.NewCustomerHandler(customerSvc)
+
+	transactionRepo := repository.NewTransactionRepository(db, calendar)
+	transactionSvc := service.NewTransactionService(transactionRepo, productRepo, promotionRepo, taxClassRepo, shiftRepo, customerRepo, serviceChargeRate, calendar)
+	transactionHandler := handler.NewTransactionHandler(transactionSvc, receipts)
+
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	idempotencySvc := service.NewIdempotencyService(idempotencyRepo)
+	idempotencyHandler := handler.NewIdempotencyHandler(idempotencySvc)
 
 	// Routes
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -63,9 +123,38 @@ func main() {
 
 	http.HandleFunc("/products", productHandler.HandleProducts)
 	http.HandleFunc("/products/", productHandler.HandleProductByID)
+	http.HandleFunc("/products/stock-check", productHandler.CheckStock)
+	http.HandleFunc("/products/low-stock", productHandler.GetLowStock)
+	http.HandleFunc("/products/barcode/", productHandler.GetByBarcode)
+	http.HandleFunc("/products/reorder-suggestions", productHandler.GetReorderSuggestions)
+
+	http.HandleFunc("/promotions", promotionHandler.HandlePromotions)
+	http.HandleFunc("/promotions/", promotionHandler.HandlePromotionByID)
+
+	http.HandleFunc("/tax-classes", taxClassHandler.HandleTaxClasses)
+	http.HandleFunc("/tax-classes/", taxClassHandler.HandleTaxClassByID)
+
+	http.HandleFunc("/shifts", shiftHandler.HandleShifts)
+	http.HandleFunc("/shifts/", shiftHandler.HandleShiftByID)
+	http.HandleFunc("/stocktakes", stocktakeHandler.HandleStocktakes)
+	http.HandleFunc("/stocktakes/", stocktakeHandler.HandleStocktakeByID)
+
+	http.HandleFunc("/suppliers", supplierHandler.HandleSuppliers)
+	http.HandleFunc("/suppliers/", supplierHandler.HandleSupplierByID)
+	http.HandleFunc("/purchase-orders", purchaseOrderHandler.HandlePurchaseOrders)
+	http.HandleFunc("/purchase-orders/", purchaseOrderHandler.HandlePurchaseOrderByID)
+
+	http.HandleFunc("/customers", customerHandler.HandleCustomers)
+	http.HandleFunc("/customers/", customerHandler.HandleCustomerByID)
 
-	http.HandleFunc("/transactions", transactionHandler.CreateTransaction)
+	http.HandleFunc("/transactions", idempotencyHandler.Wrap(transactionHandler.HandleTransactions))
+	http.HandleFunc("/transactions/", idempotencyHandler.Wrap(transactionHandler.HandleTransactionByID))
 	http.HandleFunc("/api/report/hari-ini", transactionHandler.GetDailyReport)
+	http.HandleFunc("/api/report/tax", transactionHandler.GetTaxReport)
+	http.HandleFunc("/api/report/sales", transactionHandler.GetSalesReport)
+	http.HandleFunc("/api/report/products", transactionHandler.GetProductSalesReport)
+	http.HandleFunc("/api/report/categories", transactionHandler.GetCategorySalesReport)
+	http.HandleFunc("/api/report/profit", transactionHandler.GetProfitReport)
 
 	// Start Server
 	fmt.Printf("Server running on port %s\n", cfg.ServerAddress)
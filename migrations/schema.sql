@@ -28,3 +28,451 @@ CREATE TABLE IF NOT EXISTS transaction_details (
     FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
     FOREIGN KEY (product_id) REFERENCES products(id)
 );
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'completed';
+
+CREATE TABLE IF NOT EXISTS refunds (
+    id SERIAL PRIMARY KEY,
+    transaction_id INT NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    reason_code VARCHAR(50) NOT NULL,
+    note TEXT,
+    total_amount DECIMAL(10, 2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS refund_items (
+    id SERIAL PRIMARY KEY,
+    refund_id INT NOT NULL,
+    transaction_detail_id INT NOT NULL,
+    product_id INT NOT NULL,
+    quantity INT NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    FOREIGN KEY (refund_id) REFERENCES refunds(id) ON DELETE CASCADE,
+    FOREIGN KEY (transaction_detail_id) REFERENCES transaction_details(id),
+    FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+CREATE TABLE IF NOT EXISTS payments (
+    id SERIAL PRIMARY KEY,
+    transaction_id INT NOT NULL,
+    method VARCHAR(20) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    tendered DECIMAL(10, 2) NOT NULL,
+    change_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    reference VARCHAR(100),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key VARCHAR(255) PRIMARY KEY,
+    request_hash VARCHAR(64) NOT NULL,
+    status_code INT,
+    response_body TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS promotions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    percent INT NOT NULL DEFAULT 0,
+    amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    buy_quantity INT NOT NULL DEFAULT 0,
+    get_quantity INT NOT NULL DEFAULT 0,
+    bundle_price DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    min_spend DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    daily_start VARCHAR(5),
+    daily_end VARCHAR(5),
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE IF NOT EXISTS promotion_products (
+    promotion_id INT NOT NULL,
+    product_id INT NOT NULL,
+    PRIMARY KEY (promotion_id, product_id),
+    FOREIGN KEY (promotion_id) REFERENCES promotions(id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS discount_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS discount_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS transaction_discounts (
+    id SERIAL PRIMARY KEY,
+    transaction_id INT NOT NULL,
+    transaction_detail_id INT,
+    promotion_id INT,
+    promotion_name VARCHAR(100) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+    FOREIGN KEY (transaction_detail_id) REFERENCES transaction_details(id) ON DELETE CASCADE,
+    FOREIGN KEY (promotion_id) REFERENCES promotions(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS tax_classes (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    rate DECIMAL(5, 2) NOT NULL,
+    inclusive BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+INSERT INTO tax_classes (name, rate, inclusive) VALUES
+    ('PPN 11%', 11.00, FALSE),
+    ('PPN 12%', 12.00, FALSE)
+ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tax_class_id INT REFERENCES tax_classes(id) ON DELETE SET NULL;
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS subtotal DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS service_charge DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(5, 2) NOT NULL DEFAULT 0;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS tax_inclusive BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS service_charge DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS shifts (
+    id SERIAL PRIMARY KEY,
+    cashier_name VARCHAR(100) NOT NULL,
+    opening_float DECIMAL(10, 2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    opened_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMP,
+    expected_cash DECIMAL(10, 2),
+    counted_cash DECIMAL(10, 2),
+    variance DECIMAL(10, 2),
+    note TEXT
+);
+
+CREATE TABLE IF NOT EXISTS shift_cash_movements (
+    id SERIAL PRIMARY KEY,
+    shift_id INT NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (shift_id) REFERENCES shifts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS shift_denominations (
+    shift_id INT NOT NULL,
+    denomination DECIMAL(10, 2) NOT NULL,
+    count INT NOT NULL,
+    PRIMARY KEY (shift_id, denomination),
+    FOREIGN KEY (shift_id) REFERENCES shifts(id) ON DELETE CASCADE
+);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS shift_id INT REFERENCES shifts(id);
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS shift_id INT REFERENCES shifts(id);
+
+-- Report boundaries are computed as instants in the store timezone, so sale
+-- times must be absolute. Existing values are read in the session timezone,
+-- which is the zone CURRENT_TIMESTAMP wrote them in.
+ALTER TABLE transactions ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+ALTER TABLE refunds ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS cost_price DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS unit_cost DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+-- Sold lines keep their own copy of the product details so history survives
+-- renames, price changes and deleted products
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS unit_price DECIMAL(10, 2);
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS product_name VARCHAR(100);
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS category_id INT;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS category_name VARCHAR(100);
+
+UPDATE transaction_details d SET
+    unit_price = ROUND((d.subtotal + d.discount_amount) / d.quantity, 2),
+    product_name = p.name,
+    category_id = p.category_id,
+    category_name = c.name
+FROM products p
+LEFT JOIN categories c ON c.id = p.category_id
+WHERE p.id = d.product_id AND d.product_name IS NULL AND d.quantity > 0;
+
+ALTER TABLE transaction_details ALTER COLUMN product_id DROP NOT NULL;
+ALTER TABLE transaction_details DROP CONSTRAINT IF EXISTS transaction_details_product_id_fkey;
+ALTER TABLE transaction_details ADD CONSTRAINT transaction_details_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE SET NULL;
+ALTER TABLE refund_items ALTER COLUMN product_id DROP NOT NULL;
+ALTER TABLE refund_items DROP CONSTRAINT IF EXISTS refund_items_product_id_fkey;
+ALTER TABLE refund_items ADD CONSTRAINT refund_items_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    quantity INT NOT NULL,
+    reference_type VARCHAR(30),
+    reference_id INT,
+    note TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS stock_movements_product_id_idx ON stock_movements (product_id, created_at);
+
+-- Open the ledger for products that existed before it
+INSERT INTO stock_movements (product_id, type, quantity, note)
+SELECT p.id, 'adjustment', p.stock, 'opening balance'
+FROM products p
+WHERE NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.id);
+
+CREATE TABLE IF NOT EXISTS stocktakes (
+    id SERIAL PRIMARY KEY,
+    category_id INT REFERENCES categories(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    note TEXT,
+    started_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    posted_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS stocktake_items (
+    stocktake_id INT NOT NULL,
+    product_id INT NOT NULL,
+    product_name VARCHAR(100) NOT NULL,
+    system_stock INT NOT NULL,
+    unit_cost DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (stocktake_id, product_id),
+    FOREIGN KEY (stocktake_id) REFERENCES stocktakes(id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS stocktake_counts (
+    stocktake_id INT NOT NULL,
+    product_id INT NOT NULL,
+    counter_name VARCHAR(100) NOT NULL,
+    quantity INT NOT NULL,
+    counted_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (stocktake_id, product_id, counter_name),
+    FOREIGN KEY (stocktake_id, product_id) REFERENCES stocktake_items(stocktake_id, product_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS suppliers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    phone VARCHAR(30),
+    email VARCHAR(100),
+    address TEXT
+);
+
+CREATE TABLE IF NOT EXISTS purchase_orders (
+    id SERIAL PRIMARY KEY,
+    supplier_id INT NOT NULL REFERENCES suppliers(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    note TEXT,
+    total_amount DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS purchase_order_items (
+    id SERIAL PRIMARY KEY,
+    purchase_order_id INT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    product_name VARCHAR(100),
+    quantity INT NOT NULL,
+    unit_cost DECIMAL(10, 2) NOT NULL,
+    received_quantity INT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS goods_receipts (
+    id SERIAL PRIMARY KEY,
+    purchase_order_id INT NOT NULL REFERENCES purchase_orders(id),
+    supplier_id INT NOT NULL REFERENCES suppliers(id),
+    note TEXT,
+    total_amount DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    received_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS goods_receipt_items (
+    id SERIAL PRIMARY KEY,
+    goods_receipt_id INT NOT NULL REFERENCES goods_receipts(id) ON DELETE CASCADE,
+    purchase_order_item_id INT NOT NULL REFERENCES purchase_order_items(id),
+    product_id INT NOT NULL REFERENCES products(id),
+    quantity INT NOT NULL,
+    unit_cost DECIMAL(10, 2) NOT NULL,
+    amount DECIMAL(12, 2) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS supplier_payments (
+    id SERIAL PRIMARY KEY,
+    supplier_id INT NOT NULL REFERENCES suppliers(id),
+    amount DECIMAL(12, 2) NOT NULL,
+    method VARCHAR(20) NOT NULL,
+    reference TEXT,
+    paid_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reorder_point INT NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reorder_quantity INT NOT NULL DEFAULT 0;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS sku VARCHAR(64);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku ON products(sku);
+
+CREATE TABLE IF NOT EXISTS product_barcodes (
+    code VARCHAR(14) PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_barcodes_product ON product_barcodes(product_id);
+
+-- Quantities may be fractional for products sold by weight, volume or length
+ALTER TABLE products ADD COLUMN IF NOT EXISTS unit VARCHAR(10) NOT NULL DEFAULT 'pcs';
+ALTER TABLE products ALTER COLUMN stock TYPE DECIMAL(12, 3);
+ALTER TABLE products ALTER COLUMN reorder_point TYPE DECIMAL(12, 3);
+ALTER TABLE products ALTER COLUMN reorder_quantity TYPE DECIMAL(12, 3);
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS unit VARCHAR(10) NOT NULL DEFAULT 'pcs';
+ALTER TABLE transaction_details ALTER COLUMN quantity TYPE DECIMAL(12, 3);
+ALTER TABLE refund_items ALTER COLUMN quantity TYPE DECIMAL(12, 3);
+ALTER TABLE stock_movements ALTER COLUMN quantity TYPE DECIMAL(12, 3);
+ALTER TABLE stocktake_items ALTER COLUMN system_stock TYPE DECIMAL(12, 3);
+ALTER TABLE stocktake_counts ALTER COLUMN quantity TYPE DECIMAL(12, 3);
+ALTER TABLE purchase_order_items ALTER COLUMN quantity TYPE DECIMAL(12, 3);
+ALTER TABLE purchase_order_items ALTER COLUMN received_quantity TYPE DECIMAL(12, 3);
+ALTER TABLE goods_receipt_items ALTER COLUMN quantity TYPE DECIMAL(12, 3);
+
+-- Packs a product is also sold in, e.g. a box of 12 pieces. Stock stays in
+-- the product's own unit.
+CREATE TABLE IF NOT EXISTS product_unit_conversions (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    unit VARCHAR(20) NOT NULL,
+    factor DECIMAL(12, 3) NOT NULL CHECK (factor > 0),
+    price DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    UNIQUE (product_id, unit)
+);
+
+ALTER TABLE product_barcodes ADD COLUMN IF NOT EXISTS conversion_id INT REFERENCES product_unit_conversions(id) ON DELETE CASCADE;
+ALTER TABLE transaction_details ALTER COLUMN unit TYPE VARCHAR(20);
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS base_quantity DECIMAL(12, 3);
+UPDATE transaction_details SET base_quantity = quantity WHERE base_quantity IS NULL;
+ALTER TABLE transaction_details ALTER COLUMN base_quantity SET NOT NULL;
+
+-- Registered customers and the price level they buy at
+CREATE TABLE IF NOT EXISTS customers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    phone VARCHAR(30),
+    email VARCHAR(100),
+    price_level VARCHAR(20) NOT NULL DEFAULT 'retail'
+);
+
+-- Prices per level and quantity break. The product's own price is the retail
+-- price with no minimum quantity.
+CREATE TABLE IF NOT EXISTS product_price_tiers (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    level VARCHAR(20) NOT NULL,
+    min_quantity DECIMAL(12, 3) NOT NULL DEFAULT 0,
+    price DECIMAL(10, 2) NOT NULL,
+    UNIQUE (product_id, level, min_quantity)
+);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS customer_id INT REFERENCES customers(id) ON DELETE SET NULL;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS price_level VARCHAR(20) NOT NULL DEFAULT 'retail';
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS price_tier_id INT REFERENCES product_price_tiers(id) ON DELETE SET NULL;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS price_level VARCHAR(20);
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS tier_min_quantity DECIMAL(12, 3);
+
+-- Variants are products of their own under a parent that lists the
+-- attributes they differ by. A variant without a price override sells at its
+-- parent's price.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES products(id) ON DELETE CASCADE;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS variant_attributes TEXT[];
+ALTER TABLE products ADD COLUMN IF NOT EXISTS attributes JSONB;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS price_override DECIMAL(10, 2);
+CREATE INDEX IF NOT EXISTS idx_products_parent ON products(parent_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_variant_attributes ON products(parent_id, attributes) WHERE parent_id IS NOT NULL;
+
+-- Bills of materials. A composite product's stock is worked out from its
+-- components, and each sale records what it took from them so refunds put
+-- back exactly that.
+CREATE TABLE IF NOT EXISTS product_components (
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    component_id INT NOT NULL REFERENCES products(id),
+    quantity DECIMAL(12, 3) NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (product_id, component_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_components_component ON product_components(component_id);
+
+CREATE TABLE IF NOT EXISTS transaction_detail_components (
+    transaction_detail_id INT NOT NULL REFERENCES transaction_details(id) ON DELETE CASCADE,
+    product_id INT REFERENCES products(id) ON DELETE SET NULL,
+    quantity DECIMAL(12, 3) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_detail_components_detail ON transaction_detail_components(transaction_detail_id);
+
+-- A key is reserved while its request runs. A reservation that never
+-- completes, because the process died mid-request, can be claimed again once
+-- it is stale.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS reserved_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- How each refund was paid out. Refunds made before this was recorded take
+-- the method their sale was paid with, or cash for a split sale.
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS method VARCHAR(20);
+UPDATE refunds rf SET method = COALESCE((
+    SELECT MIN(p.method) FROM payments p WHERE p.transaction_id = rf.transaction_id HAVING COUNT(DISTINCT p.method) = 1
+), 'cash')
+WHERE rf.method IS NULL;
+ALTER TABLE refunds ALTER COLUMN method SET NOT NULL;
+
+-- The stock ledger and stocktake history outlive the products they record.
+-- Ledger entries keep the product's name; a stocktake item already does and
+-- keeps its product ID, which is part of its key, with no reference to it.
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS product_name VARCHAR(100);
+UPDATE stock_movements m SET product_name = p.name FROM products p WHERE p.id = m.product_id AND m.product_name IS NULL;
+ALTER TABLE stock_movements ALTER COLUMN product_id DROP NOT NULL;
+ALTER TABLE stock_movements DROP CONSTRAINT IF EXISTS stock_movements_product_id_fkey;
+ALTER TABLE stock_movements ADD CONSTRAINT stock_movements_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE SET NULL;
+ALTER TABLE stocktake_items DROP CONSTRAINT IF EXISTS stocktake_items_product_id_fkey;
+
+-- Each count remembers how far the product's ledger had got, so posting can
+-- carry forward whatever moved after the count. Counts made before this are
+-- placed by time. Posting records the adjustment it made to each product.
+ALTER TABLE stocktake_counts ADD COLUMN IF NOT EXISTS ledger_position INT;
+UPDATE stocktake_counts c SET ledger_position = COALESCE((
+    SELECT MAX(m.id) FROM stock_movements m WHERE m.product_id = c.product_id AND m.created_at <= c.counted_at
+), 0)
+WHERE c.ledger_position IS NULL;
+ALTER TABLE stocktake_counts ALTER COLUMN ledger_position SET NOT NULL;
+ALTER TABLE stocktake_items ADD COLUMN IF NOT EXISTS adjustment DECIMAL(12, 3);
+
+-- Purchase orders and goods receipts outlive the products on them. Both keep
+-- the product's name; order lines already do.
+ALTER TABLE goods_receipt_items ADD COLUMN IF NOT EXISTS product_name VARCHAR(100);
+UPDATE goods_receipt_items gi SET product_name = p.name FROM products p WHERE p.id = gi.product_id AND gi.product_name IS NULL;
+ALTER TABLE purchase_order_items ALTER COLUMN product_id DROP NOT NULL;
+ALTER TABLE purchase_order_items DROP CONSTRAINT IF EXISTS purchase_order_items_product_id_fkey;
+ALTER TABLE purchase_order_items ADD CONSTRAINT purchase_order_items_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE SET NULL;
+ALTER TABLE goods_receipt_items ALTER COLUMN product_id DROP NOT NULL;
+ALTER TABLE goods_receipt_items DROP CONSTRAINT IF EXISTS goods_receipt_items_product_id_fkey;
+ALTER TABLE goods_receipt_items ADD CONSTRAINT goods_receipt_items_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE SET NULL;
+
+-- Packs may be priced per price level; levels without a price of their own
+-- pay the pack price. Lines that were not priced from a tier record no tier
+-- minimum.
+ALTER TABLE product_unit_conversions ADD COLUMN IF NOT EXISTS level_prices JSONB NOT NULL DEFAULT '{}';
+UPDATE transaction_details SET tier_min_quantity = NULL WHERE price_level IS NULL AND tier_min_quantity = 0;
+
+-- A product with variants cannot be deleted until its variants are. The
+-- check waits for the end of the statement, so deleting a category still
+-- takes a parent and its variants together.
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_parent_id_fkey;
+ALTER TABLE products ADD CONSTRAINT products_parent_id_fkey
+    FOREIGN KEY (parent_id) REFERENCES products(id) ON DELETE NO ACTION;
+
+-- Each reservation carries a token for the request holding it, so a request
+-- whose stale reservation was taken over cannot complete or release the key
+-- for the retry that took it.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS owner_token VARCHAR(64);